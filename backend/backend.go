@@ -8,6 +8,8 @@ import (
 	"github.com/XrXr/alang/parsing"
 	"github.com/XrXr/alang/typing"
 	"io"
+	"math"
+	"sort"
 )
 
 type outputBlock struct {
@@ -67,11 +69,33 @@ const (
 	numRegisters
 )
 
+// SSE registers come right after the general purpose ones
+const (
+	xmm0 registerId = numRegisters + iota
+	xmm1
+	xmm2
+	xmm3
+	xmm4
+	xmm5
+	xmm6
+	xmm7
+	xmm8
+	xmm9
+	xmm10
+	xmm11
+	xmm12
+	xmm13
+	xmm14
+	xmm15
+	numAllRegisters
+)
+
 const invalidVn int = -1
 const invalidRegister registerId = -1
 const zombieRevival string = "ice: trying to revive a decommissioned variable"
 
 var paramPassingRegOrder = [...]registerId{rdi, rsi, rdx, rcx, r8, r9}
+var floatParamPassingRegOrder = [...]registerId{xmm0, xmm1, xmm2, xmm3, xmm4, xmm5, xmm6, xmm7}
 var preservedRegisters = [...]registerId{rbx, r15, r14, r13, r12}
 
 type registerInfo struct {
@@ -98,15 +122,30 @@ func (r *registerInfo) nameForSize(size int) string {
 }
 
 type registerBucket struct {
-	all       [numRegisters]registerInfo
+	all       [numAllRegisters]registerInfo
 	available []registerId
 }
 
-func (r *registerBucket) nextAvailable() (registerId, bool) {
-	if len(r.available) == 0 {
-		return 0, false
+func isXmm(register registerId) bool {
+	return register >= xmm0
+}
+
+func (r *registerBucket) nextAvailableOfKind(xmm bool) (registerId, bool) {
+	for i := len(r.available) - 1; i >= 0; i-- {
+		if isXmm(r.available[i]) == xmm {
+			return r.available[i], true
+		}
 	}
-	return r.available[len(r.available)-1], true
+	return 0, false
+}
+
+// next available general purpose register
+func (r *registerBucket) nextAvailable() (registerId, bool) {
+	return r.nextAvailableOfKind(false)
+}
+
+func (r *registerBucket) nextAvailableXmm() (registerId, bool) {
+	return r.nextAvailableOfKind(true)
 }
 
 func (r *registerBucket) allInUse() bool {
@@ -122,7 +161,7 @@ type varStorageInfo struct {
 type fullVarState struct {
 	varStorage         []varStorageInfo
 	registers          registerBucket
-	dontSwap           [numRegisters]bool
+	dontSwap           [numAllRegisters]bool
 	nextRegToBeSwapped registerId
 }
 
@@ -143,7 +182,7 @@ func newFullVarState(numVars int) *fullVarState {
 	}
 	bucket.all[rsi].byteName = "sil"
 	bucket.all[rdi].byteName = "dil"
-	for i := len(baseNames); i < len(bucket.all); i++ {
+	for i := len(baseNames); i < int(numRegisters); i++ {
 		qwordName := fmt.Sprintf("r%d", i-len(baseNames)+8)
 		bucket.all[i].qwordName = qwordName
 		bucket.all[i].wordName = qwordName + "w"
@@ -151,11 +190,36 @@ func newFullVarState(numVars int) *fullVarState {
 		bucket.all[i].byteName = qwordName + "b"
 	}
 
+	for i := xmm0; i < numAllRegisters; i++ {
+		name := fmt.Sprintf("xmm%d", i-xmm0)
+		bucket.all[i].qwordName = name
+		bucket.all[i].dwordName = name
+		bucket.all[i].wordName = name
+		bucket.all[i].byteName = name
+	}
+
 	for i := range bucket.all {
 		bucket.all[i].occupiedBy = invalidVn
 	}
 
 	bucket.available = []registerId{
+		// none of the SSE registers are preserved across calls
+		xmm7,
+		xmm6,
+		xmm5,
+		xmm4,
+		xmm3,
+		xmm2,
+		xmm1,
+		xmm0,
+		xmm15,
+		xmm14,
+		xmm13,
+		xmm12,
+		xmm11,
+		xmm10,
+		xmm9,
+		xmm8,
 		rax,
 		rcx,
 		rdx,
@@ -195,19 +259,21 @@ func (f *fullVarState) varInfoString(vn int) string {
 }
 
 type preJumpState struct {
-	out    *outputBlock
-	state  *fullVarState
-	optIdx int
+	out   *outputBlock
+	state *fullVarState
+	inst  ir.Inst
 }
 
 type procGen struct {
 	*fullVarState
 	block                     frontend.OptBlock
+	procName                  string
 	out                       *outputBlock
 	firstOutputBlock          *outputBlock
 	staticDataBuf             *bytes.Buffer
 	env                       *typing.EnvRecord
 	procRecord                typing.ProcRecord
+	overload                  int
 	typer                     *typing.Typer
 	callerProvidesReturnSpace bool
 	noNewStackStorage         bool // checked by loadRegisterWithVar
@@ -304,6 +370,15 @@ func (p *procGen) sizeof(vn int) int {
 	return p.typeTable[vn].Size()
 }
 
+// floats live in SSE registers
+func (p *procGen) isFloat(vn int) bool {
+	return p.typer.IsFloat(p.typeTable[vn])
+}
+
+func (p *procGen) nextAvailableFor(vn int) (registerId, bool) {
+	return p.registers.nextAvailableOfKind(p.isFloat(vn))
+}
+
 func (p *procGen) fittingRegisterName(vn int) string {
 	reg := p.registerOf(vn)
 	size := p.sizeof(vn)
@@ -370,13 +445,30 @@ func (p *procGen) rwInfoSizedToMem(memVar int, regVar int) (string, string, int)
 	return prefix, register, offset
 }
 
+// SSE registers need their own kind of mov
+func (p *procGen) movFlavorFor(command string, regVar int, memVar int) string {
+	if command == "mov" && isXmm(p.varStorage[regVar].currentRegister) {
+		return floatMovMnemonic(p.sizeof(memVar))
+	}
+	return command
+}
+
+func floatMovMnemonic(size int) string {
+	if size == 4 {
+		return "movss"
+	}
+	return "movsd"
+}
+
 func (p *procGen) memRegCommand(command string, memVar int, regVar int) {
 	prefix, register, offset := p.rwInfoSizedToMem(memVar, regVar)
+	command = p.movFlavorFor(command, regVar, memVar)
 	p.issueCommand(fmt.Sprintf("%s %s[rbp-%d], %s", command, prefix, offset, register))
 }
 
 func (p *procGen) regMemCommand(command string, regVar int, memVar int) {
 	prefix, register, offset := p.rwInfoSizedToMem(memVar, regVar)
+	command = p.movFlavorFor(command, regVar, memVar)
 	p.issueCommand(fmt.Sprintf("%s %s, %s[rbp-%d]", command, register, prefix, offset))
 }
 
@@ -391,7 +483,22 @@ func (p *procGen) regRegCommandSizedToFirst(command string, varA int, varB int)
 }
 
 func (p *procGen) movRegReg(regA registerId, regB registerId) {
-	p.issueCommand(fmt.Sprintf("mov %s, %s", p.registers.all[regA].qwordName, p.registers.all[regB].qwordName))
+	mnemonic := "mov"
+	if isXmm(regA) {
+		mnemonic = "movaps"
+	}
+	p.issueCommand(fmt.Sprintf("%s %s, %s", mnemonic, p.registers.all[regA].qwordName, p.registers.all[regB].qwordName))
+}
+
+func (p *procGen) swapRegisters(a int, b int) {
+	if isXmm(p.varStorage[a].currentRegister) {
+		// no xchg for SSE registers
+		p.regRegCommand("xorps", a, b)
+		p.regRegCommand("xorps", b, a)
+		p.regRegCommand("xorps", a, b)
+	} else {
+		p.regRegCommand("xchg", a, b)
+	}
 }
 
 func (p *procGen) loadVarOffsetIntoReg(vn int, reg registerId) {
@@ -441,16 +548,16 @@ func (p *procGen) loadRegisterWithVar(register registerId, vn int) {
 		}
 		if vnAlreadyInRegister {
 			// both are in regiser. do a swap
-			p.regRegCommand("xchg", vn, currentTenant)
+			p.swapRegisters(vn, currentTenant)
 			p.changeRegisterBookKeepking(currentTenant, vnRegister)
 			p.changeRegisterBookKeepking(vn, register)
 			return
 		}
 
-		newReg, freeRegExists := p.registers.nextAvailable()
+		newReg, freeRegExists := p.registers.nextAvailableOfKind(isXmm(register))
 		if freeRegExists {
 			// swap currentTenant to a new register
-			p.issueCommand(fmt.Sprintf("mov %s, %s", p.registers.all[newReg].qwordName, p.registers.all[register].qwordName))
+			p.movRegReg(newReg, register)
 			p.allocateRegToVar(newReg, currentTenant)
 			p.changeRegisterBookKeepking(vn, register)
 		} else {
@@ -475,17 +582,18 @@ func (p *procGen) ensureInRegister(vn int) registerId {
 	if reg = p.varStorage[vn].currentRegister; reg != invalidRegister {
 		return reg
 	}
-	reg, freeRegExists := p.registers.nextAvailable()
+	reg, freeRegExists := p.nextAvailableFor(vn)
 	if freeRegExists {
 		p.loadRegisterWithVar(reg, vn)
 		return reg
 	} else {
+		wantXmm := p.isFloat(vn)
 		reg = p.nextRegToBeSwapped
-		for p.dontSwap[reg] {
-			reg = (reg + 1) % numRegisters
+		for p.dontSwap[reg] || isXmm(reg) != wantXmm {
+			reg = (reg + 1) % numAllRegisters
 		}
 		p.loadRegisterWithVar(reg, vn)
-		p.nextRegToBeSwapped = (reg + 1) % numRegisters
+		p.nextRegToBeSwapped = (reg + 1) % numAllRegisters
 		return reg
 	}
 }
@@ -505,7 +613,7 @@ func (p *procGen) allocateRuntimeStorage(vn int) {
 	if p.inRegister(vn) || p.hasStackStorage(vn) {
 		return
 	}
-	reg, available := p.registers.nextAvailable()
+	reg, available := p.nextAvailableFor(vn)
 	if available {
 		p.loadRegisterWithVar(reg, vn)
 	} else {
@@ -574,6 +682,9 @@ func (p *procGen) freeUpRegisters(allocateNewStackStorage bool, targetList ...re
 		foundDifferentRegister := false
 	searchForRegister:
 		for _, reg := range p.registers.available {
+			if isXmm(reg) != isXmm(target) {
+				continue
+			}
 			for _, otherTarget := range targetList {
 				if otherTarget == reg {
 					continue searchForRegister
@@ -603,13 +714,68 @@ func (p *procGen) zeroOutVarOnStack(vn int) {
 	p.issueCommand("call _intrinsic_zero_mem")
 }
 
+// Procs that return two values that each fit in a register use rax and rdx, or xmm0 and xmm1 for floats.
+// Other procs that return more than one value and procs that return a struct or an array
+// write to storage the caller provides.
+func returnsThroughMemory(typer *typing.Typer, returnType typing.TypeRecord) bool {
+	if tuple, isTuple := returnType.(typing.Tuple); isTuple {
+		return tupleReturnRegisters(typer, tuple) == nil
+	}
+	return returnType.Size() > 0 && !fitsInRegister(returnType)
+}
+
+// returns nil when the values don't all fit in registers
+func tupleReturnRegisters(typer *typing.Typer, tuple typing.Tuple) []registerId {
+	if len(tuple.Types) != 2 {
+		return nil
+	}
+	intRegs := []registerId{rax, rdx}
+	floatRegs := []registerId{xmm0, xmm1}
+	var regs []registerId
+	for _, record := range tuple.Types {
+		if !fitsInRegister(record) {
+			return nil
+		}
+		if typer.IsFloat(record) {
+			regs = append(regs, floatRegs[0])
+			floatRegs = floatRegs[1:]
+		} else {
+			regs = append(regs, intRegs[0])
+			intRegs = intRegs[1:]
+		}
+	}
+	return regs
+}
+
+// :structinreg structs and arrays never go in registers even if they are small enough
+// The first proc with a name gets the plain label since the builtins and the prologue refer to procs
+// by name. Overloads that come after it are numbered. Names can't have dots in them and none of the
+// local labels are just a number, so this doesn't collide with anything.
+func procSymbol(name string, overload int) string {
+	if overload == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s.%d", name, overload)
+}
+
+func fitsInRegister(record typing.TypeRecord) bool {
+	switch record.(type) {
+	case *typing.StructRecord, typing.StructRecord, typing.Array:
+		return false
+	}
+	return isPerfectSize(record.Size())
+}
+
+func roundToEightbytes(size int) int {
+	return (size + 7) / 8 * 8
+}
+
 func isPerfectSize(size int) bool {
 	return size == 8 || size == 4 || size == 2 || size == 1
 }
 
-func (p *procGen) varPerfectRegSize(vn int) bool {
-	size := p.sizeof(vn)
-	return isPerfectSize(size)
+func (p *procGen) varFitsInRegister(vn int) bool {
+	return fitsInRegister(p.typeTable[vn])
 }
 
 // return the mnemonic to use and the register sizing for dest
@@ -641,8 +807,13 @@ func (p *procGen) signOrZeroExtendMov(dest int, source int) {
 	p.signOrZeroExtendMovToReg(p.varStorage[dest].currentRegister, source)
 }
 
+// :morecopies structs and arrays are copied with memcpy
 func (p *procGen) varVarCopy(dest int, source int) {
-	if p.varPerfectRegSize(source) {
+	if p.isFloat(dest) && p.isFloat(source) {
+		p.floatVarVarCopy(dest, source)
+		return
+	}
+	if p.varFitsInRegister(source) {
 		p.ensureInRegister(source)
 		if p.inRegister(dest) {
 			if p.typeTable[dest].IsNumber() && p.typeTable[source].IsNumber() && p.sizeof(dest) > p.sizeof(source) {
@@ -715,16 +886,16 @@ func (p *procGen) jump(jumpInst *ir.Inst) {
 	p.issueCommand(fmt.Sprintf("jmp .%s", label))
 }
 
-func (p *procGen) jumpOrDelayedJump(optIdx int, opt *ir.Inst) {
+func (p *procGen) jumpOrDelayedJump(opt *ir.Inst) {
 	label := opt.Extra.(string)
 	_, labelSeen := p.labelToState[label]
 	if labelSeen {
 		p.jump(opt)
 	} else {
 		p.jumps = append(p.jumps, preJumpState{
-			out:    p.out,
-			state:  p.copyVarState(),
-			optIdx: optIdx,
+			out:   p.out,
+			state: p.copyVarState(),
+			inst:  *opt,
 		})
 		p.switchToNewOutBlock()
 	}
@@ -746,6 +917,323 @@ func (p *procGen) findOrMakeFreeReg() registerId {
 	return reg
 }
 
+func (p *procGen) findOrMakeFreeXmm() registerId {
+	reg, freeRegExists := p.registers.nextAvailableXmm()
+	if freeRegExists {
+		return reg
+	}
+	for reg = xmm15; p.dontSwap[reg]; reg-- {
+	}
+	currentTenant := p.registers.all[reg].occupiedBy
+	p.ensureStackOffsetValid(currentTenant)
+	p.memRegCommand("mov", currentTenant, currentTenant)
+	p.releaseRegister(reg)
+	return reg
+}
+
+func floatOpSuffix(size int) string {
+	if size == 4 {
+		return "ss"
+	}
+	return "sd"
+}
+
+func floatConversionMnemonic(fromSize int, toSize int) string {
+	if fromSize == 4 && toSize == 8 {
+		return "cvtss2sd"
+	}
+	return "cvtsd2ss"
+}
+
+// return an operand that has the value of vn with a float width of size.
+// Might convert into a scratch register when the sizes don't match.
+func (p *procGen) floatOperandSizedTo(vn int, size int) string {
+	if p.sizeof(vn) == size {
+		return p.varOperand(vn)
+	}
+	scratch := p.findOrMakeFreeXmm()
+	scratchName := p.registers.all[scratch].qwordName
+	p.issueCommand(fmt.Sprintf("%s %s, %s", floatConversionMnemonic(p.sizeof(vn), size), scratchName, p.varOperand(vn)))
+	return scratchName
+}
+
+// same as floatOperandSizedTo but the operand is always a register
+func (p *procGen) floatRegisterSizedTo(vn int, size int) string {
+	if p.sizeof(vn) == size {
+		p.ensureInRegister(vn)
+		return p.registerOf(vn).qwordName
+	}
+	return p.floatOperandSizedTo(vn, size)
+}
+
+func (p *procGen) floatVarVarCopy(dest int, source int) {
+	p.ensureInRegister(source)
+	if p.sizeof(dest) != p.sizeof(source) {
+		p.ensureInRegister(dest)
+		mnemonic := floatConversionMnemonic(p.sizeof(source), p.sizeof(dest))
+		p.regRegCommand(mnemonic, dest, source)
+	} else if p.inRegister(dest) {
+		p.regRegCommand("movaps", dest, source)
+	} else {
+		p.ensureStackOffsetValid(dest)
+		p.memRegCommand("mov", dest, source)
+	}
+}
+
+// there is no immediate form for loading floats so they go into static data
+func (p *procGen) loadFloatConstant(vn int, value float64) {
+	reg := p.ensureInRegister(vn)
+	size := p.sizeof(vn)
+	labelName := p.genLabel(fmt.Sprintf("static_float_%p", p.block.Opts))
+	p.staticDataBuf.WriteString(fmt.Sprintf("%s:\n", labelName))
+	if size == 4 {
+		p.staticDataBuf.WriteString(fmt.Sprintf("\tdd\t0x%x\n", math.Float32bits(float32(value))))
+	} else {
+		p.staticDataBuf.WriteString(fmt.Sprintf("\tdq\t0x%x\n", math.Float64bits(value)))
+	}
+	p.issueCommand(fmt.Sprintf("%s %s, %s [%s]", floatMovMnemonic(size), p.registers.all[reg].qwordName, prefixForSize(size), labelName))
+}
+
+// Integers are sign or zero extended according to the type they come from. Narrowing keeps the low bytes.
+func (p *procGen) genCast(out int, in int) {
+	_, outIsBool := p.typeTable[out].(typing.Boolean)
+	_, inIsBool := p.typeTable[in].(typing.Boolean)
+	if p.valueKnown(in) && p.precompute[in].valueType != integer {
+		// a pointer into the stack
+		p.endPrecomputingAndMaterialize(in)
+	}
+	switch {
+	case p.isFloat(out) || p.isFloat(in):
+		p.genFloatConversion(out, in)
+	case outIsBool && !inIsBool:
+		// anything that isn't zero is true
+		if p.valueKnown(in) {
+			p.precompute[out].valueType = integer
+			p.precompute[out].value = 0
+			if p.getPrecomputedValue(in) != 0 {
+				p.precompute[out].value = 1
+			}
+			return
+		}
+		p.ensureInRegister(in)
+		p.ensureInRegister(out)
+		p.issueCommand(fmt.Sprintf("cmp %s, 0", p.varOperand(in)))
+		p.issueCommand(fmt.Sprintf("setnz %s", p.fittingRegisterName(out)))
+	case p.valueKnown(in):
+		p.precompute[out] = p.precompute[in]
+		p.precompute[out].value = p.wrapToVarSize(out, p.wrapToVarSize(in, p.precompute[in].value))
+	case p.sizeof(out) > p.sizeof(in):
+		p.ensureInRegister(in)
+		p.ensureInRegister(out)
+		p.signOrZeroExtendMov(out, in)
+	default:
+		p.varVarCopy(out, in)
+	}
+}
+
+// casts where at least one side is a float
+func (p *procGen) genFloatConversion(out int, in int) {
+	outSize := p.sizeof(out)
+	switch {
+	case p.isFloat(out) && p.isFloat(in):
+		p.varVarCopy(out, in)
+	case p.isFloat(out):
+		if p.valueKnown(in) {
+			value := p.wrapToVarSize(in, p.getPrecomputedValue(in))
+			if p.typer.IsUnsigned(p.typeTable[in]) {
+				p.loadFloatConstant(out, float64(uint64(value)))
+			} else {
+				p.loadFloatConstant(out, float64(value))
+			}
+			return
+		}
+		inReg := p.ensureInRegister(in)
+		if p.sizeof(in) < 8 {
+			p.signOrZeroExtendMov(in, in)
+		}
+		outReg := p.ensureInRegister(out)
+		inName := p.registers.all[inReg].qwordName
+		outName := p.registers.all[outReg].qwordName
+		suffix := floatOpSuffix(outSize)
+		p.issueCommand(fmt.Sprintf("cvtsi2%s %s, %s", suffix, outName, inName))
+		if p.sizeof(in) < 8 || !p.typer.IsUnsigned(p.typeTable[in]) {
+			return
+		}
+		// cvtsi2sd only takes signed values. When the top bit is set, convert half the value
+		// and double it. The low bit is kept in the halved value so it still rounds correctly.
+		scratch := rax
+		if inReg == rax {
+			scratch = rcx
+		}
+		p.freeUpRegisters(true, scratch)
+		scratchName := p.registers.all[scratch].qwordName
+		done := p.genLabel(".converted")
+		p.issueCommand(fmt.Sprintf("test %s, %s", inName, inName))
+		p.issueCommand(fmt.Sprintf("jns %s", done))
+		p.issueCommand(fmt.Sprintf("mov %s, %s", scratchName, inName))
+		p.issueCommand(fmt.Sprintf("and %s, 1", scratchName))
+		p.issueCommand(fmt.Sprintf("add %s, %s", scratchName, scratchName))
+		p.issueCommand(fmt.Sprintf("or %s, %s", scratchName, inName))
+		p.issueCommand(fmt.Sprintf("shr %s, 1", scratchName))
+		p.issueCommand(fmt.Sprintf("cvtsi2%s %s, %s", suffix, outName, scratchName))
+		p.issueCommand(fmt.Sprintf("add%s %s, %s", suffix, outName, outName))
+		fmt.Fprintf(p.out.buffer, "%s:\n", done)
+	default:
+		// truncates towards zero
+		inSize := p.sizeof(in)
+		suffix := floatOpSuffix(inSize)
+		outReg := p.ensureInRegister(out)
+		outName := p.registers.all[outReg].qwordName
+		if outSize < 8 || !p.typer.IsUnsigned(p.typeTable[out]) {
+			p.issueCommand(fmt.Sprintf("cvtt%s2si %s, %s", suffix, outName, p.varOperand(in)))
+			return
+		}
+		// cvttsd2si only gives signed values. From 2^63 up, take 2^63 off before converting
+		// and put the top bit back after.
+		inReg := p.ensureInRegister(in)
+		inName := p.registers.all[inReg].qwordName
+		twoTo63 := p.genLabel(fmt.Sprintf("static_float_%p", p.block.Opts))
+		p.staticDataBuf.WriteString(fmt.Sprintf("%s:\n", twoTo63))
+		if inSize == 4 {
+			p.staticDataBuf.WriteString(fmt.Sprintf("\tdd\t0x%x\n", math.Float32bits(1<<63)))
+		} else {
+			p.staticDataBuf.WriteString(fmt.Sprintf("\tdq\t0x%x\n", math.Float64bits(1<<63)))
+		}
+		twoTo63Operand := fmt.Sprintf("%s [%s]", prefixForSize(inSize), twoTo63)
+		small := p.genLabel(".in_signed_range")
+		done := p.genLabel(".converted")
+		p.issueCommand(fmt.Sprintf("ucomi%s %s, %s", suffix, inName, twoTo63Operand))
+		p.issueCommand(fmt.Sprintf("jb %s", small))
+		scratch := p.findOrMakeFreeXmm()
+		scratchName := p.registers.all[scratch].qwordName
+		p.issueCommand(fmt.Sprintf("%s %s, %s", floatMovMnemonic(inSize), scratchName, inName))
+		p.issueCommand(fmt.Sprintf("sub%s %s, %s", suffix, scratchName, twoTo63Operand))
+		p.issueCommand(fmt.Sprintf("cvtt%s2si %s, %s", suffix, outName, scratchName))
+		p.issueCommand(fmt.Sprintf("btc %s, 63", outName))
+		p.issueCommand(fmt.Sprintf("jmp %s", done))
+		fmt.Fprintf(p.out.buffer, "%s:\n", small)
+		p.issueCommand(fmt.Sprintf("cvtt%s2si %s, %s", suffix, outName, inName))
+		fmt.Fprintf(p.out.buffer, "%s:\n", done)
+	}
+}
+
+func (p *procGen) genFloatArithmetic(opt ir.Inst) {
+	l := opt.Left()
+	r := opt.Right()
+	var mnemonic string
+	switch opt.Type {
+	case ir.Add:
+		mnemonic = "add"
+	case ir.Sub:
+		mnemonic = "sub"
+	case ir.Mult:
+		mnemonic = "mul"
+	case ir.Div:
+		mnemonic = "div"
+	}
+	size := p.sizeof(l)
+	lReg := p.ensureInRegister(l)
+	rOperand := p.floatOperandSizedTo(r, size)
+	p.issueCommand(fmt.Sprintf("%s%s %s, %s", mnemonic, floatOpSuffix(size), p.registers.all[lReg].qwordName, rOperand))
+}
+
+func (p *procGen) genFloatCompare(opt ir.Inst) {
+	extra := opt.Extra.(ir.CompareExtra)
+	out := extra.Out
+	first := opt.In()
+	second := extra.Right
+	how := extra.How
+	// unordered results look like "below", so flip these around to make comparisons with NaN false
+	switch how {
+	case ir.Lesser:
+		first, second, how = second, first, ir.Greater
+	case ir.LesserOrEqual:
+		first, second, how = second, first, ir.GreaterOrEqual
+	}
+	size := p.sizeof(first)
+	if p.sizeof(second) > size {
+		size = p.sizeof(second)
+	}
+	p.allocateRuntimeStorage(out)
+	firstReg := p.floatRegisterSizedTo(first, size)
+	secondOperand := p.floatOperandSizedTo(second, size)
+	p.issueCommand(fmt.Sprintf("ucomi%s %s, %s", floatOpSuffix(size), firstReg, secondOperand))
+	switch how {
+	case ir.Greater:
+		p.issueCommand(fmt.Sprintf("seta %s", p.varOperand(out)))
+	case ir.GreaterOrEqual:
+		p.issueCommand(fmt.Sprintf("setae %s", p.varOperand(out)))
+	case ir.AreEqual, ir.NotEqual:
+		orderedLabel := p.genLabel(".ordered")
+		unorderedResult := 0
+		mnemonic := "sete"
+		if how == ir.NotEqual {
+			unorderedResult = 1
+			mnemonic = "setne"
+		}
+		p.issueCommand(fmt.Sprintf("%s %s", mnemonic, p.varOperand(out)))
+		p.issueCommand(fmt.Sprintf("jnp %s", orderedLabel))
+		p.issueCommand(fmt.Sprintf("mov %s, %d", p.varOperand(out), unorderedResult))
+		fmt.Fprintf(p.out.buffer, "%s:\n", orderedLabel)
+	default:
+		panic("ice: passed a unknown method of comparison")
+	}
+}
+
+// where each argument goes according to the SystemV calling convention.
+// invalidRegister for arguments passed on the stack
+func argRegisters(typer *typing.Typer, args []typing.TypeRecord, hiddenFirstArg bool) []registerId {
+	regs := make([]registerId, len(args))
+	nextReg := 0
+	if hiddenFirstArg {
+		nextReg = 1
+	}
+	nextXmm := 0
+	for i, arg := range args {
+		regs[i] = invalidRegister
+		if typer.IsFloat(arg) {
+			if nextXmm < len(floatParamPassingRegOrder) {
+				regs[i] = floatParamPassingRegOrder[nextXmm]
+				nextXmm++
+			}
+		} else if _, isSlice := arg.(typing.Slice); isSlice {
+			// the data pointer goes in this register and the length goes in the next one
+			if nextReg+1 < len(paramPassingRegOrder) {
+				regs[i] = paramPassingRegOrder[nextReg]
+				nextReg += 2
+			}
+		} else if fitsInRegister(arg) && nextReg < len(paramPassingRegOrder) {
+			// structs and arrays are copied onto the stack
+			regs[i] = paramPassingRegOrder[nextReg]
+			nextReg++
+		}
+	}
+	return regs
+}
+
+// the register that has the length of a slice passed starting at dataReg
+func sliceLengthRegister(dataReg registerId) registerId {
+	for i, reg := range paramPassingRegOrder[:len(paramPassingRegOrder)-1] {
+		if reg == dataReg {
+			return paramPassingRegOrder[i+1]
+		}
+	}
+	panic("ice: slice passed starting at a register that can't fit it")
+}
+
+// slices come in two registers but live on the stack like structs
+func (p *procGen) spillSliceArgs() {
+	argRegs := argRegisters(p.typer, p.typeTable[:p.block.NumberOfArgs], p.callerProvidesReturnSpace)
+	for i, reg := range argRegs {
+		if _, isSlice := p.typeTable[i].(typing.Slice); !isSlice || reg == invalidRegister {
+			continue
+		}
+		p.ensureStackOffsetValid(i)
+		p.issueCommand(fmt.Sprintf("mov qword [rbp-%d], %s", p.varStorage[i].rbpOffset, p.registers.all[reg].qwordName))
+		p.issueCommand(fmt.Sprintf("mov qword [rbp-%d+8], %s", p.varStorage[i].rbpOffset, p.registers.all[sliceLengthRegister(reg)].qwordName))
+	}
+}
+
 func (p *procGen) startOptionSelect(optIdx int, opt ir.Inst) {
 	outOfScopeMutations := *opt.Extra.(*[]int)
 	precompStates := make([]varPrecomputeInfo, 0, len(outOfScopeMutations))
@@ -783,12 +1271,152 @@ func (p *procGen) genOptionEnd(optIdx int, opt ir.Inst) {
 	}
 }
 
+// The dispatch code for a switch jumps to a stub for each target and each stub jumps to a case label.
+// Every stub starts out with the same var state, so going through them lets each case morph the
+// var state in its own way, the same way it works for jumps to the else branch of an if.
+func (p *procGen) genSwitch(optIdx int, opt ir.Inst) {
+	extra := opt.Extra.(ir.SwitchExtra)
+	in := opt.In()
+	jumpToLabel := func(label string) {
+		jump := ir.MakePlainInst(ir.Jump, label)
+		p.jumpOrDelayedJump(&jump)
+	}
+
+	if p.valueKnown(in) {
+		value := p.wrapToVarSize(in, p.getPrecomputedValue(in))
+		target := extra.Default
+		for _, switchCase := range extra.Cases {
+			for _, caseValue := range switchCase.Values {
+				if caseValue.Value == value {
+					target = switchCase.Label
+				}
+			}
+		}
+		jumpToLabel(target)
+		return
+	}
+
+	defaultStub := p.genLabel("switch_default")
+	caseStubs := make([]string, len(extra.Cases))
+	valueCount := 0
+	var min, max int64
+	for i, switchCase := range extra.Cases {
+		caseStubs[i] = p.genLabel("switch_case")
+		for _, caseValue := range switchCase.Values {
+			if valueCount == 0 || caseValue.Value < min {
+				min = caseValue.Value
+			}
+			if valueCount == 0 || caseValue.Value > max {
+				max = caseValue.Value
+			}
+			valueCount++
+		}
+	}
+
+	// use a jump table when the case values are close together
+	useTable := valueCount >= 4 && min >= math.MinInt32 && max <= math.MaxInt32 && max-min < int64(2*valueCount)
+	if useTable {
+		span := max - min + 1
+		table := make([]string, span)
+		for i := range table {
+			table[i] = defaultStub
+		}
+		for i, switchCase := range extra.Cases {
+			for _, caseValue := range switchCase.Values {
+				table[caseValue.Value-min] = caseStubs[i]
+			}
+		}
+		tableLabel := p.genLabel(fmt.Sprintf("switch_table_%p", p.block.Opts))
+		p.staticDataBuf.WriteString(fmt.Sprintf("%s:\n", tableLabel))
+		for _, stub := range table {
+			p.staticDataBuf.WriteString(fmt.Sprintf("\tdq\tproc_%s.%s\n", p.procName, stub))
+		}
+
+		indexReg := p.findOrMakeFreeReg()
+		indexRegName := p.registers.all[indexReg].qwordName
+		p.signOrZeroExtendMovToReg(indexReg, in)
+		if min != 0 {
+			p.issueCommand(fmt.Sprintf("sub %s, %d", indexRegName, min))
+		}
+		// values below min wrap around and become big, so one unsigned compare checks both ends
+		p.issueCommand(fmt.Sprintf("cmp %s, %d", indexRegName, span-1))
+		p.issueCommand(fmt.Sprintf("ja .%s", defaultStub))
+		p.issueCommand(fmt.Sprintf("jmp [%s + %s*8]", tableLabel, indexRegName))
+	} else {
+		for i, switchCase := range extra.Cases {
+			for _, caseValue := range switchCase.Values {
+				if p.sizeof(in) == 8 && (caseValue.Value > math.MaxInt32 || caseValue.Value < math.MinInt32) {
+					// no 64 bit immediate for cmp
+					tmpReg := p.findOrMakeFreeReg()
+					p.issueCommand(fmt.Sprintf("mov %s, %d", p.registers.all[tmpReg].qwordName, caseValue.Value))
+					p.issueCommand(fmt.Sprintf("cmp %s, %s", p.varOperand(in), p.registers.all[tmpReg].qwordName))
+				} else {
+					p.issueCommand(fmt.Sprintf("cmp %s, %d", p.varOperand(in), caseValue.Value))
+				}
+				p.issueCommand(fmt.Sprintf("je .%s", caseStubs[i]))
+			}
+		}
+		p.issueCommand(fmt.Sprintf("jmp .%s", defaultStub))
+	}
+
+	for i, switchCase := range extra.Cases {
+		fmt.Fprintf(p.out.buffer, ".%s:\n", caseStubs[i])
+		jumpToLabel(switchCase.Label)
+	}
+	fmt.Fprintf(p.out.buffer, ".%s:\n", defaultStub)
+	jumpToLabel(extra.Default)
+}
+
 func (p *procGen) genLabel(prefix string) string {
 	label := fmt.Sprintf("%s_%d", prefix, p.nextLabelId)
 	p.nextLabelId++
 	return label
 }
 
+// strings are a pointer to the length followed by the data
+func writeStringData(staticDataBuf *bytes.Buffer, labelName string, value string) {
+	var buf bytes.Buffer
+	buf.WriteString("\tdb\t")
+	inQuote := false
+	for i := 0; i < len(value); i++ {
+		char := value[i]
+		// nasm doesn't do escapes inside double quotes, so anything unprintable goes out as a number
+		printable := char >= ' ' && char <= '~' && char != '"'
+		if printable && !inQuote {
+			if i > 0 {
+				buf.WriteRune(',')
+			}
+			buf.WriteRune('"')
+			inQuote = true
+		} else if !printable {
+			if inQuote {
+				buf.WriteRune('"')
+				inQuote = false
+			}
+			if i > 0 {
+				buf.WriteRune(',')
+			}
+			fmt.Fprintf(&buf, "%d", char)
+		}
+		if printable {
+			buf.WriteByte(char)
+		}
+	}
+	// end the string
+	if inQuote {
+		buf.WriteRune('"')
+	}
+	if len(value) > 0 {
+		buf.WriteRune(',')
+	}
+	buf.WriteRune('0')
+
+	staticDataBuf.WriteString(fmt.Sprintf("%s:\n", labelName))
+	staticDataBuf.WriteString(fmt.Sprintf("\tdq\t%d\n", len(value)))
+	staticDataBuf.ReadFrom(&buf)
+	staticDataBuf.WriteRune('\n')
+}
+
 func (p *procGen) genAssignImm(optIdx int, opt ir.Inst) {
 	out := opt.Out()
 
@@ -799,6 +1427,11 @@ func (p *procGen) genAssignImm(optIdx int, opt ir.Inst) {
 			p.precompute[out].value = value
 			p.precompute[out].precomputedOnce = true
 			return
+		case uint8:
+			p.precompute[out].valueType = integer
+			p.precompute[out].value = int64(value)
+			p.precompute[out].precomputedOnce = true
+			return
 		case bool:
 			var val int64 = 0
 			if value == true {
@@ -821,55 +1454,26 @@ func (p *procGen) genAssignImm(optIdx int, opt ir.Inst) {
 		}
 		p.allocateRuntimeStorage(out)
 		p.issueCommand(fmt.Sprintf("mov %s, %d", p.varOperand(out), val))
-	case int64, uint64:
+	case int64, uint64, uint8:
 		p.ensureInRegister(out)
 		p.issueCommand(fmt.Sprintf("mov %s, %d", p.registerOf(out).qwordName, value))
+	case float64:
+		p.loadFloatConstant(out, value)
 	case string:
 		destReg := p.ensureInRegister(out)
 		labelName := p.genLabel(fmt.Sprintf("static_string_%p", p.block.Opts))
 		p.issueCommand(fmt.Sprintf("mov %s, %s", p.registers.all[destReg].qwordName, labelName))
-
-		var buf bytes.Buffer
-		buf.WriteString("\tdb\t")
-		byteCount := 0
-		i := 0
-		needToStartQuote := true
-		for ; i < len(value); i++ {
-			if needToStartQuote {
-				buf.WriteRune('"')
-				needToStartQuote = false
-			}
-			if value[i] == '\\' && value[i+1] == 'n' {
-				buf.WriteString(`",10,`)
-				needToStartQuote = true
-				i++
-			} else {
-				buf.WriteString(string(value[i]))
-			}
-			byteCount++
-		}
-		// end the string
-		if !needToStartQuote {
-			buf.WriteString(`",0`)
-		} else {
-			// it's a string that ends with \n
-			buf.WriteRune('0')
-		}
-
-		p.staticDataBuf.WriteString(fmt.Sprintf("%s:\n", labelName))
-		p.staticDataBuf.WriteString(fmt.Sprintf("\tdq\t%d\n", byteCount))
-		p.staticDataBuf.ReadFrom(&buf)
-		p.staticDataBuf.WriteRune('\n')
+		writeStringData(p.staticDataBuf, labelName, value)
 	case parsing.TypeDecl, parsing.LiteralType:
 		// :structinreg
 		out := opt.Out()
-		_, isStruct := p.typeTable[out].(typing.StructRecord)
-		_, isArray := p.typeTable[out].(typing.Array)
-		freeReg, freeRegExists := p.registers.nextAvailable()
-		if !isStruct && !isArray && p.varPerfectRegSize(out) && freeRegExists {
+		freeReg, freeRegExists := p.nextAvailableFor(out)
+		if p.varFitsInRegister(out) && freeRegExists {
 			p.loadRegisterWithVar(freeReg, out)
 		}
-		if p.inRegister(out) {
+		if p.inRegister(out) && p.isFloat(out) {
+			p.regRegCommand("xorps", out, out)
+		} else if p.inRegister(out) {
 			p.issueCommand(fmt.Sprintf("mov %s, 0", p.registerOf(out).qwordName))
 		} else {
 			p.zeroOutVarOnStack(out)
@@ -883,53 +1487,87 @@ func (p *procGen) genAssignImm(optIdx int, opt ir.Inst) {
 func (p *procGen) genCall(optIdx int, opt ir.Inst) {
 	p.swapStackBoundVars()
 	extra := opt.Extra.(ir.CallExtra)
-	if typeRecord, callToType := p.env.Types[extra.Name]; callToType {
+	if typeRecord, callToType := p.env.Types[extra.Name]; callToType && !extra.Indirect {
 		switch typeRecord.(type) {
 		case *typing.StructRecord:
 			// making a struct. We never put structs in registers even if they fit
 			// :structinreg
 			p.zeroOutVarOnStack(opt.Out())
 		default:
-			// cast
-			firstArg := extra.ArgVars[0]
-			if p.valueKnown(firstArg) {
-				p.precompute[opt.Out()] = p.precompute[firstArg]
-			} else {
-				p.varVarCopy(opt.Out(), extra.ArgVars[0])
-			}
+			p.genCast(opt.Out(), extra.ArgVars[0])
 		}
 	} else {
 		retVar := opt.Out()
-		procRecord := p.env.Procs[extra.Name]
-		var numStackVars int
-		numArgs := len(extra.ArgVars)
-		provideReturnStorage := p.sizeof(retVar) > 16
-		if provideReturnStorage {
-			numArgs += 1
-		}
-
-		visibleArgsInReg := len(extra.ArgVars)
-		if len(paramPassingRegOrder) <= visibleArgsInReg {
-			visibleArgsInReg = len(paramPassingRegOrder)
-			if provideReturnStorage {
-				visibleArgsInReg--
+		var procRecord typing.ProcRecord
+		if extra.Indirect {
+			pointer := p.typeTable[extra.ProcVar].(typing.ProcPointer)
+			procRecord = typing.ProcRecord{Return: pointer.Return, Args: pointer.Args}
+			// the argument registers are all spoken for at the call, so we call through the copy in memory
+			p.endPrecomputingAndMaterialize(extra.ProcVar)
+			p.ensureStackOffsetValid(extra.ProcVar)
+			if p.inRegister(extra.ProcVar) {
+				p.memRegCommand("mov", extra.ProcVar, extra.ProcVar)
+			}
+		} else {
+			procRecord = p.env.Procs[extra.Name][extra.Overload]
+		}
+		returnTuple, returnsTuple := (*procRecord.Return).(typing.Tuple)
+		provideReturnStorage := returnsThroughMemory(p.typer, *procRecord.Return)
+		argRegs := argRegisters(p.typer, procRecord.Args, provideReturnStorage)
+		var stackArgs []int
+		numFloatArgsInReg := 0
+		for i, reg := range argRegs {
+			if reg == invalidRegister {
+				stackArgs = append(stackArgs, i)
+			} else if isXmm(reg) {
+				numFloatArgsInReg++
 			}
 		}
+		stackArgsSize := 0
+		for _, i := range stackArgs {
+			stackArgsSize += roundToEightbytes(procRecord.Args[i].Size())
+		}
+		stackPadding := stackArgsSize % 16
 
-		if visibleArgsInReg < len(extra.ArgVars) {
-			tmpReg := p.findOrMakeFreeReg()
-			tmpRegInfo := &p.registers.all[tmpReg]
-			numStackVars = len(extra.ArgVars) - visibleArgsInReg
-			if numStackVars%2 == 1 {
+		tupleBufferSize := 0
+		if returnsTuple && provideReturnStorage {
+			// the callee writes the values to a buffer that sits right above the stack args
+			tupleBufferSize = (returnTuple.Size() + 15) / 16 * 16
+			p.issueCommand(fmt.Sprintf("sub rsp, %d", tupleBufferSize))
+		}
+		if stackArgsSize > 0 {
+			if stackPadding > 0 {
 				// Make sure we are aligned to 16
-				p.issueCommand("sub rsp, 8")
+				p.issueCommand(fmt.Sprintf("sub rsp, %d", stackPadding))
 			}
-			for i := len(extra.ArgVars) - 1; i >= len(extra.ArgVars)-numStackVars; i-- {
+			for j := len(stackArgs) - 1; j >= 0; j-- {
+				i := stackArgs[j]
 				arg := extra.ArgVars[i]
 				argSize := p.typeTable[arg].Size()
+				if !fitsInRegister(procRecord.Args[i]) {
+					// :morecopies passing by value makes a copy
+					p.issueCommand(fmt.Sprintf("sub rsp, %d", roundToEightbytes(argSize)))
+					p.freeUpRegisters(true, rsi, rdi, rcx)
+					p.ensureStackOffsetValid(arg)
+					p.issueCommand("mov rdi, rsp")
+					p.loadVarOffsetIntoReg(arg, rsi)
+					p.issueCommand(fmt.Sprintf("mov rcx, %d", argSize))
+					p.issueCommand("call _intrinsic_memcpy")
+					continue
+				}
+				tmpReg := p.findOrMakeFreeReg()
+				tmpRegInfo := &p.registers.all[tmpReg]
 				switch argSize {
 				case 8, 4, 2, 1:
-					if p.valueKnown(arg) {
+					if p.isFloat(arg) {
+						paramSize := procRecord.Args[i].Size()
+						valueReg := p.floatRegisterSizedTo(arg, paramSize)
+						mnemonic := "movq"
+						if paramSize == 4 {
+							mnemonic = "movd"
+						}
+						p.issueCommand(fmt.Sprintf("%s %s, %s", mnemonic, tmpRegInfo.nameForSize(paramSize), valueReg))
+					} else if p.valueKnown(arg) {
 						p.loadKnownValueIntoRegSized(arg, procRecord.Args[i], tmpReg)
 					} else {
 						p.signOrZeroExtendMovToReg(tmpReg, arg)
@@ -941,16 +1579,27 @@ func (p *procGen) genCall(optIdx int, opt ir.Inst) {
 			}
 		}
 
+		var convertedFloatArgs []int
+		var sliceArgs []int
 		for i, arg := range extra.ArgVars {
-			if provideReturnStorage {
-				i += 1
+			reg := argRegs[i]
+			if reg == invalidRegister {
+				continue
 			}
-			if i >= len(paramPassingRegOrder) {
-				break
+			if _, isSlice := procRecord.Args[i].(typing.Slice); isSlice {
+				sliceArgs = append(sliceArgs, i)
+				continue
+			}
+			if isXmm(reg) {
+				if p.sizeof(arg) == procRecord.Args[i].Size() {
+					p.loadRegisterWithVar(reg, arg)
+				} else {
+					convertedFloatArgs = append(convertedFloatArgs, i)
+				}
+				continue
 			}
 			switch valueSize := p.typeTable[arg].Size(); valueSize {
 			case 8, 4, 2, 1:
-				reg := paramPassingRegOrder[i]
 				p.loadRegisterWithVar(reg, arg)
 				if p.valueKnown(arg) {
 					p.loadKnownValueIntoRegSized(arg, procRecord.Args[i], reg)
@@ -964,9 +1613,35 @@ func (p *procGen) genCall(optIdx int, opt ir.Inst) {
 			}
 		}
 
-		// the first part of this array is the same as paramPassingRegOrder
-		regsThatGetDestroyed := [...]registerId{rdi, rsi, rdx, rcx, r8, r9, rax, r10, r11}
-		for _, reg := range regsThatGetDestroyed {
+		if len(convertedFloatArgs) > 0 {
+			// these go in last so the conversions don't clobber args that are already in place
+			targets := make([]registerId, len(convertedFloatArgs))
+			for j, i := range convertedFloatArgs {
+				targets[j] = argRegs[i]
+			}
+			p.freeUpRegisters(true, targets...)
+			for j, i := range convertedFloatArgs {
+				arg := extra.ArgVars[i]
+				mnemonic := floatConversionMnemonic(p.sizeof(arg), procRecord.Args[i].Size())
+				p.issueCommand(fmt.Sprintf("%s %s, %s", mnemonic, p.registers.all[targets[j]].qwordName, p.varOperand(arg)))
+			}
+		}
+		if len(sliceArgs) > 0 {
+			// same as the conversions, nothing owns these registers so they go in last
+			var targets []registerId
+			for _, i := range sliceArgs {
+				targets = append(targets, argRegs[i], sliceLengthRegister(argRegs[i]))
+			}
+			p.freeUpRegisters(true, targets...)
+			for _, i := range sliceArgs {
+				arg := extra.ArgVars[i]
+				p.ensureStackOffsetValid(arg)
+				p.issueCommand(fmt.Sprintf("mov %s, qword [rbp-%d]", p.registers.all[argRegs[i]].qwordName, p.varStorage[arg].rbpOffset))
+				p.issueCommand(fmt.Sprintf("mov %s, qword [rbp-%d+8]", p.registers.all[sliceLengthRegister(argRegs[i])].qwordName, p.varStorage[arg].rbpOffset))
+			}
+		}
+
+		spillDestroyed := func(reg registerId) {
 			owner := p.registers.all[reg].occupiedBy
 			if owner != invalidVn {
 				if !(p.lastUsage[owner] == optIdx || p.valueKnown(owner)) {
@@ -976,44 +1651,185 @@ func (p *procGen) genCall(optIdx int, opt ir.Inst) {
 				p.releaseRegister(reg)
 			}
 		}
+		// the first part of this array is the same as paramPassingRegOrder
+		regsThatGetDestroyed := [...]registerId{rdi, rsi, rdx, rcx, r8, r9, rax, r10, r11}
+		for _, reg := range regsThatGetDestroyed {
+			spillDestroyed(reg)
+		}
+		// none of the xmm registers are preserved across calls
+		for reg := xmm0; reg < numAllRegisters; reg++ {
+			spillDestroyed(reg)
+		}
 
-		if provideReturnStorage {
+		if provideReturnStorage && returnsTuple {
+			p.issueCommand(fmt.Sprintf("lea rdi, [rsp+%d]", stackArgsSize+stackPadding))
+		} else if provideReturnStorage {
 			p.ensureStackOffsetValid(retVar)
 			p.loadVarOffsetIntoReg(retVar, rdi)
 		}
 
-		if procRecord.IsForeign {
+		if extra.Indirect {
+			// the callee could be any proc, so it gets the same treatment as varargs procs
+			p.issueCommand(fmt.Sprintf("mov eax, %d", numFloatArgsInReg))
+			p.issueCommand(fmt.Sprintf("call qword [rbp-%d]", p.varStorage[extra.ProcVar].rbpOffset))
+		} else if procRecord.IsForeign {
+			// varargs procs expect the number of vector registers used in al
+			p.issueCommand(fmt.Sprintf("mov eax, %d", numFloatArgsInReg))
 			p.issueCommand(fmt.Sprintf("call %s  wrt ..plt", extra.Name))
 		} else {
-			p.issueCommand(fmt.Sprintf("call proc_%s", extra.Name))
+			p.issueCommand(fmt.Sprintf("call proc_%s", procSymbol(extra.Name, extra.Overload)))
 		}
 
-		// TODO this needs to change when we support things bigger than 8 bytes
-		if numArgs > len(paramPassingRegOrder) {
-			p.issueCommand(fmt.Sprintf("add rsp, %d", numStackVars*8+numStackVars%2*8))
+		if stackArgsSize > 0 {
+			p.issueCommand(fmt.Sprintf("add rsp, %d", stackArgsSize+stackPadding))
 		}
 		if p.registers.all[rax].occupiedBy != invalidVn {
 			panic("rax should've been freed up before the call")
 		}
-		if p.sizeof(retVar) > 0 && p.sizeof(retVar) <= 8 {
+		if returnsTuple {
+			p.takeReturnedValues(returnTuple, append([]int{retVar}, extra.ReturnTo...), tupleBufferSize)
+		} else if !provideReturnStorage && p.sizeof(retVar) > 0 {
 			if p.inRegister(retVar) {
 				p.releaseRegister(p.varStorage[retVar].currentRegister)
 			}
-			p.allocateRegToVar(rax, retVar)
+			if p.isFloat(retVar) {
+				p.allocateRegToVar(xmm0, retVar)
+			} else {
+				p.allocateRegToVar(rax, retVar)
+			}
 		}
 	}
 
 }
 
+// The values of a proc that returns more than one value is either in registers or in a buffer at rsp.
+// bufferSize is 0 in the former case.
+func (p *procGen) takeReturnedValues(tuple typing.Tuple, returnVars []int, bufferSize int) {
+	claim := func(vn int, reg registerId) {
+		if p.inRegister(vn) {
+			p.releaseRegister(p.varStorage[vn].currentRegister)
+		}
+		p.allocateRegToVar(reg, vn)
+	}
+	if bufferSize == 0 {
+		for i, reg := range tupleReturnRegisters(p.typer, tuple) {
+			claim(returnVars[i], reg)
+		}
+		return
+	}
+	// copy the big values first since the copying uses rsi, rdi and rcx
+	for i, vn := range returnVars {
+		if fitsInRegister(tuple.Types[i]) {
+			continue
+		}
+		p.ensureStackOffsetValid(vn)
+		p.issueCommand(fmt.Sprintf("lea rsi, [rsp+%d]", tuple.Offset(i)))
+		p.loadVarOffsetIntoReg(vn, rdi)
+		p.issueCommand(fmt.Sprintf("mov rcx, %d", p.sizeof(vn)))
+		p.issueCommand("call _intrinsic_memcpy")
+	}
+	for i, vn := range returnVars {
+		if !fitsInRegister(tuple.Types[i]) {
+			continue
+		}
+		var reg registerId
+		if p.isFloat(vn) {
+			reg = p.findOrMakeFreeXmm()
+		} else {
+			reg = p.findOrMakeFreeReg()
+		}
+		claim(vn, reg)
+		size := p.sizeof(vn)
+		source := fmt.Sprintf("%s [rsp+%d]", prefixForSize(size), tuple.Offset(i))
+		if p.isFloat(vn) {
+			p.issueCommand(fmt.Sprintf("%s %s, %s", floatMovMnemonic(size), p.registers.all[reg].qwordName, source))
+		} else {
+			p.issueCommand(fmt.Sprintf("mov %s, %s", p.registers.all[reg].nameForSize(size), source))
+		}
+	}
+	p.issueCommand(fmt.Sprintf("add rsp, %d", bufferSize))
+}
+
+func (p *procGen) genTupleReturn(tuple typing.Tuple, values []int) {
+	if !p.callerProvidesReturnSpace {
+		targets := tupleReturnRegisters(p.typer, tuple)
+		p.freeUpRegisters(true, targets...)
+		for i, vn := range values {
+			target := targets[i]
+			declared := tuple.Types[i]
+			switch {
+			case p.isFloat(vn) && p.sizeof(vn) == declared.Size():
+				p.loadRegisterWithVar(target, vn)
+			case p.isFloat(vn):
+				mnemonic := floatConversionMnemonic(p.sizeof(vn), declared.Size())
+				p.issueCommand(fmt.Sprintf("%s %s, %s", mnemonic, p.registers.all[target].qwordName, p.varOperand(vn)))
+			case p.valueKnown(vn):
+				p.loadKnownValueIntoRegSized(vn, declared, target)
+			default:
+				p.loadRegisterWithVar(target, vn)
+				if declared.Size() > p.sizeof(vn) {
+					p.signOrZeroExtendMov(vn, vn)
+				}
+			}
+		}
+		return
+	}
+
+	p.freeUpRegisters(true, rax, rsi, rdi, rcx)
+	for i, vn := range values {
+		declared := tuple.Types[i]
+		offset := tuple.Offset(i)
+		p.issueCommand("mov rdi, qword [rbp-8]")
+		if !fitsInRegister(declared) {
+			if p.inRegister(vn) {
+				panic("ice: a var this big shouldn't be in register")
+			}
+			p.ensureStackOffsetValid(vn)
+			if offset > 0 {
+				p.issueCommand(fmt.Sprintf("add rdi, %d", offset))
+			}
+			p.loadVarOffsetIntoReg(vn, rsi)
+			p.issueCommand(fmt.Sprintf("mov rcx, %d", p.sizeof(vn)))
+			p.issueCommand("call _intrinsic_memcpy")
+			continue
+		}
+		size := declared.Size()
+		dest := fmt.Sprintf("%s [rdi+%d]", prefixForSize(size), offset)
+		switch {
+		case p.isFloat(vn):
+			p.issueCommand(fmt.Sprintf("%s %s, %s", floatMovMnemonic(size), dest, p.floatRegisterSizedTo(vn, size)))
+		case p.valueKnown(vn):
+			p.loadKnownValueIntoRegSized(vn, declared, rax)
+			p.issueCommand(fmt.Sprintf("mov %s, %s", dest, p.registers.all[rax].nameForSize(size)))
+		default:
+			p.signOrZeroExtendMovToReg(rax, vn)
+			p.issueCommand(fmt.Sprintf("mov %s, %s", dest, p.registers.all[rax].nameForSize(size)))
+		}
+	}
+}
+
 func (p *procGen) genReturn(optIdx int, opt ir.Inst) {
 	returnType := *p.procRecord.Return
 
 	returnExtra := opt.Extra.(ir.ReturnExtra)
+	if tuple, isTuple := returnType.(typing.Tuple); isTuple {
+		p.genTupleReturn(tuple, returnExtra.Values)
+		p.issueCommand("jmp .end_of_proc")
+		return
+	}
 	if len(returnExtra.Values) > 0 {
 		retVar := returnExtra.Values[0]
-		if p.valueKnown(retVar) {
+		if p.isFloat(retVar) {
+			if returnType.Size() == p.sizeof(retVar) {
+				p.loadRegisterWithVar(xmm0, retVar)
+			} else {
+				p.freeUpRegisters(true, xmm0)
+				mnemonic := floatConversionMnemonic(p.sizeof(retVar), returnType.Size())
+				p.issueCommand(fmt.Sprintf("%s xmm0, %s", mnemonic, p.varOperand(retVar)))
+			}
+		} else if p.valueKnown(retVar) {
 			p.loadKnownValueIntoReg(retVar, rax)
-		} else if p.varPerfectRegSize(retVar) {
+		} else if p.varFitsInRegister(retVar) {
 			p.loadRegisterWithVar(rax, retVar)
 			if returnType.Size() > p.sizeof(retVar) {
 				p.signOrZeroExtendMov(retVar, retVar)
@@ -1032,7 +1848,7 @@ func (p *procGen) genReturn(optIdx int, opt ir.Inst) {
 			p.issueCommand(fmt.Sprintf("mov rcx, %d", p.sizeof(retVar)))
 			p.issueCommand("call _intrinsic_memcpy")
 		} else {
-			panic("Can't handle return where the data doesn't exactly fit a register or 8 < size <= 16 yet")
+			panic("ice: returning a var that doesn't fit in a register without a place to put it")
 		}
 	}
 	p.issueCommand("jmp .end_of_proc")
@@ -1052,13 +1868,18 @@ func (p *procGen) genIndirectLoad(optIdx int, opt ir.Inst) {
 		pointedToSize := inType.ToWhat.Size()
 		p.swapStackBoundVars()
 		outSize := p.sizeof(out)
-		if isPerfectSize(pointedToSize) && isPerfectSize(outSize) {
+		if fitsInRegister(inType.ToWhat) && isPerfectSize(outSize) {
 			p.ensureInRegister(out)
 			sourceOperand := p.prepareEffectiveAddress(in)
 			prefix := prefixForSize(pointedToSize)
-			mnemonic, outRegSizing := p.decideMovType(inType.ToWhat)
-			regName := p.registerOf(out).nameForSize(outRegSizing)
-			p.issueCommand(fmt.Sprintf("%s %s, %s %s", mnemonic, regName, prefix, sourceOperand))
+			if p.isFloat(out) {
+				mnemonic := floatMovMnemonic(pointedToSize)
+				p.issueCommand(fmt.Sprintf("%s %s, %s %s", mnemonic, p.registerOf(out).qwordName, prefix, sourceOperand))
+			} else {
+				mnemonic, outRegSizing := p.decideMovType(inType.ToWhat)
+				regName := p.registerOf(out).nameForSize(outRegSizing)
+				p.issueCommand(fmt.Sprintf("%s %s, %s %s", mnemonic, regName, prefix, sourceOperand))
+			}
 		} else {
 			if pointedToSize != p.sizeof(out) {
 				panic("ice: memcpy indirect load where the sizes are not equal")
@@ -1084,10 +1905,13 @@ func (p *procGen) genIndirectWrite(optIdx int, opt ir.Inst) {
 	data := opt.In()
 	pointedToSize := p.typeTable[target].(typing.Pointer).ToWhat.Size()
 
-	if p.varPerfectRegSize(data) {
+	if p.varFitsInRegister(data) {
 		destOperand := p.prepareEffectiveAddress(target)
 		prefix := prefixForSize(pointedToSize)
-		if p.valueKnown(data) {
+		if p.isFloat(data) {
+			valueReg := p.floatRegisterSizedTo(data, pointedToSize)
+			p.issueCommand(fmt.Sprintf("%s %s %s, %s", floatMovMnemonic(pointedToSize), prefix, destOperand, valueReg))
+		} else if p.valueKnown(data) {
 			switch precomp := &p.precompute[data]; precomp.valueType {
 			case integer:
 				// since it's a immediate mov, we don't need to do sign extension
@@ -1122,6 +1946,99 @@ func (p *procGen) genIndirectWrite(optIdx int, opt ir.Inst) {
 	}
 }
 
+func (p *procGen) genStructLiteral(opt ir.Inst) {
+	out := opt.Out()
+	extra := opt.Extra.(ir.StructLiteralExtra)
+	record := p.typeTable[out].(*typing.StructRecord)
+	if len(extra.Values) < len(record.MemberOrder) {
+		p.zeroOutVarOnStack(out)
+	} else {
+		p.ensureStackOffsetValid(out)
+	}
+	for i, vn := range extra.Values {
+		var field *typing.StructField
+		if len(extra.Names) == 0 {
+			field = record.MemberOrder[i]
+		} else {
+			field = record.Members[extra.Names[i]]
+		}
+		p.writeToStack(vn, field.Type, p.varStorage[out].rbpOffset-field.Offset)
+	}
+}
+
+func (p *procGen) genArrayLiteral(opt ir.Inst) {
+	out := opt.Out()
+	extra := opt.Extra.(ir.ArrayLiteralExtra)
+	array := p.typeTable[out].(typing.Array)
+	elementType := array.OfWhat
+	elementSize := elementType.Size()
+	p.ensureStackOffsetValid(out)
+
+	allConstant := fitsInRegister(elementType) && !p.typer.IsFloat(elementType)
+	for _, vn := range extra.Values {
+		allConstant = allConstant && p.valueKnown(vn) && p.precompute[vn].valueType == integer
+	}
+	if allConstant {
+		// copy from the static data segment instead of doing one store per element
+		labelName := p.genLabel(fmt.Sprintf("static_array_%p", p.block.Opts))
+		p.staticDataBuf.WriteString(fmt.Sprintf("%s:\n", labelName))
+		directive := map[int]string{1: "db", 2: "dw", 4: "dd", 8: "dq"}[elementSize]
+		for _, vn := range extra.Values {
+			value := p.getPrecomputedValue(vn)
+			if elementSize < 8 {
+				value &= 1<<uint(elementSize*8) - 1
+			}
+			p.staticDataBuf.WriteString(fmt.Sprintf("\t%s\t%d\n", directive, value))
+		}
+		if rest := array.Size() - len(extra.Values)*elementSize; rest > 0 {
+			p.staticDataBuf.WriteString(fmt.Sprintf("\ttimes %d db 0\n", rest))
+		}
+		p.freeUpRegisters(true, rsi, rdi, rcx)
+		p.issueCommand(fmt.Sprintf("lea rsi, [%s]", labelName))
+		p.loadVarOffsetIntoReg(out, rdi)
+		p.issueCommand(fmt.Sprintf("mov rcx, %d", array.Size()))
+		p.issueCommand("call _intrinsic_memcpy")
+		return
+	}
+
+	if len(extra.Values)*elementSize < array.Size() {
+		p.zeroOutVarOnStack(out)
+	}
+	for i, vn := range extra.Values {
+		p.writeToStack(vn, elementType, p.varStorage[out].rbpOffset-i*elementSize)
+	}
+}
+
+// write the value of vn to [rbp-rbpOffset], sized to fit a location of type dest
+func (p *procGen) writeToStack(vn int, dest typing.TypeRecord, rbpOffset int) {
+	size := dest.Size()
+	if !fitsInRegister(dest) {
+		p.freeUpRegisters(true, rsi, rdi, rcx)
+		p.ensureStackOffsetValid(vn)
+		if p.inRegister(vn) {
+			p.memRegCommand("mov", vn, vn)
+		}
+		p.issueCommand(fmt.Sprintf("lea rdi, [rbp-%d]", rbpOffset))
+		p.loadVarOffsetIntoReg(vn, rsi)
+		p.issueCommand(fmt.Sprintf("mov rcx, %d", size))
+		p.issueCommand("call _intrinsic_memcpy")
+		return
+	}
+	destOperand := fmt.Sprintf("%s [rbp-%d]", prefixForSize(size), rbpOffset)
+	switch {
+	case p.isFloat(vn):
+		p.issueCommand(fmt.Sprintf("%s %s, %s", floatMovMnemonic(size), destOperand, p.floatRegisterSizedTo(vn, size)))
+	case p.valueKnown(vn):
+		reg := p.findOrMakeFreeReg()
+		p.loadKnownValueIntoRegSized(vn, dest, reg)
+		p.issueCommand(fmt.Sprintf("mov %s, %s", destOperand, p.registers.all[reg].nameForSize(size)))
+	default:
+		reg := p.ensureInRegister(vn)
+		p.signOrZeroExtendMovToReg(reg, vn)
+		p.issueCommand(fmt.Sprintf("mov %s, %s", destOperand, p.registers.all[reg].nameForSize(size)))
+	}
+}
+
 func (p *procGen) setccToVar(how ir.ComparisonMethod, vn int) {
 	var mnemonic string
 	switch how {
@@ -1186,6 +2103,9 @@ func (p *procGen) arrayToPointer(optIdx int, opt ir.Inst) {
 	if p.precompute[out].precomputedOnce && !p.valueKnown(out) {
 		panic("ice: re-precompute by outputting from ir.ArrayToPointer")
 	}
+	if p.loadSliceData(in, out) {
+		return
+	}
 	if p.valueKnown(in) {
 		precomp := p.precompute[in]
 		switch precomp.valueType {
@@ -1214,33 +2134,260 @@ func (p *procGen) arrayToPointer(optIdx int, opt ir.Inst) {
 	}
 }
 
+// The data pointer of a slice is only known at runtime. Returns false if in is not a slice or a pointer to one.
+func (p *procGen) loadSliceData(in int, out int) bool {
+	var source string
+	switch inType := p.typeTable[in].(type) {
+	case typing.Slice:
+		p.ensureStackOffsetValid(in)
+		source = fmt.Sprintf("[rbp-%d]", p.varStorage[in].rbpOffset)
+	case typing.Pointer:
+		if _, toSlice := inType.ToWhat.(typing.Slice); !toSlice {
+			return false
+		}
+		p.swapStackBoundVars()
+		source = p.prepareEffectiveAddress(in)
+	default:
+		return false
+	}
+	p.endPrecomputation(out)
+	outReg := p.ensureInRegister(out)
+	p.issueCommand(fmt.Sprintf("mov %s, qword %s", p.registers.all[outReg].qwordName, source))
+	return true
+}
+
+// the program exits with an error unless the jump is taken
+func (p *procGen) boundsCheck(jumpIfInBounds string) {
+	inBounds := p.genLabel(".in_bounds")
+	p.issueCommand(fmt.Sprintf("%s %s", jumpIfInBounds, inBounds))
+	p.issueCommand("call _intrinsic_out_of_bounds")
+	fmt.Fprintf(p.out.buffer, "%s:\n", inBounds)
+}
+
+// Only indexing into slices is checked, indexing fixed arrays stays unchecked
+func (p *procGen) genBoundsCheck(opt ir.Inst) {
+	index := opt.In()
+	of := opt.Extra.(ir.BoundsCheckExtra).Of
+	var length string
+	switch ofType := p.typeTable[of].(type) {
+	case typing.Slice:
+		p.ensureStackOffsetValid(of)
+		length = fmt.Sprintf("qword [rbp-%d+8]", p.varStorage[of].rbpOffset)
+	case typing.Pointer:
+		if _, toSlice := ofType.ToWhat.(typing.Slice); !toSlice {
+			return
+		}
+		p.swapStackBoundVars()
+		length = "qword " + p.prepareEffectiveAddressWithOffset(of, 8)
+	default:
+		return
+	}
+	if p.valueKnown(index) {
+		p.issueCommand(fmt.Sprintf("cmp %s, %d", length, p.getPrecomputedValue(index)))
+		p.boundsCheck("ja")
+		return
+	}
+	indexReg := p.ensureInRegister(index)
+	if p.sizeof(index) < 8 {
+		p.signOrZeroExtendMov(index, index)
+	}
+	// negative indices are huge when compared unsigned
+	p.issueCommand(fmt.Sprintf("cmp %s, %s", p.registers.all[indexReg].qwordName, length))
+	p.boundsCheck("jb")
+}
+
+// The range is inclusive on both ends unless the slice is exclusive. The high end is checked
+// against the length of what's being sliced when there is one. Slicing a plain pointer is unchecked.
+func (p *procGen) genTakeSlice(opt ir.Inst) {
+	out := opt.Out()
+	base := opt.In()
+	extra := opt.Extra.(ir.TakeSliceExtra)
+	p.swapStackBoundVars()
+	// after this point nothing gets put in a register so rsi, rdi and rcx stay ours
+	p.endPrecomputingAndMaterialize(base)
+	p.freeUpRegisters(true, rsi, rdi, rcx)
+	loadInteger := func(reg registerId, vn int) {
+		if p.valueKnown(vn) {
+			p.loadKnownValueIntoRegSized(vn, p.typer.Builtins[typing.IntIdx], reg)
+		} else {
+			p.signOrZeroExtendMovToReg(reg, vn)
+		}
+	}
+	loadSliceOfString := func() {
+		// rsi has the string, which points to the length
+		p.issueCommand("mov rdi, qword [rsi]")
+		p.issueCommand("add rsi, 8")
+	}
+
+	// rsi gets the pointer to the first element. The capacity is the number of elements
+	var capacity string
+	baseType := p.typeTable[base]
+	if _, isPointer := baseType.(typing.Pointer); isPointer {
+		p.issueCommand(fmt.Sprintf("mov rsi, %s", p.varOperand(base)))
+	}
+	switch baseType := baseType.(type) {
+	case typing.Array:
+		p.ensureStackOffsetValid(base)
+		p.loadVarOffsetIntoReg(base, rsi)
+		capacity = fmt.Sprint(baseType.Size() / baseType.OfWhat.Size())
+	case typing.Slice:
+		p.ensureStackOffsetValid(base)
+		p.issueCommand(fmt.Sprintf("mov rsi, qword [rbp-%d]", p.varStorage[base].rbpOffset))
+		p.issueCommand(fmt.Sprintf("mov rdi, qword [rbp-%d+8]", p.varStorage[base].rbpOffset))
+		capacity = "rdi"
+	case typing.String:
+		p.issueCommand(fmt.Sprintf("mov rsi, %s", p.varOperand(base)))
+		loadSliceOfString()
+		capacity = "rdi"
+	case typing.Pointer:
+		switch pointee := baseType.ToWhat.(type) {
+		case typing.Array:
+			capacity = fmt.Sprint(pointee.Size() / pointee.OfWhat.Size())
+		case typing.Slice:
+			p.issueCommand("mov rdi, qword [rsi+8]")
+			p.issueCommand("mov rsi, qword [rsi]")
+			capacity = "rdi"
+		case typing.String:
+			p.issueCommand("mov rsi, qword [rsi]")
+			loadSliceOfString()
+			capacity = "rdi"
+		}
+	}
+
+	// rcx is one past the last element. The comparisons are signed so that low..low-1
+	// is an empty slice for any low up to the capacity
+	loadInteger(rcx, extra.High)
+	if !extra.Exclusive {
+		p.issueCommand("inc rcx")
+	}
+	if capacity != "" {
+		p.issueCommand("cmp rcx, " + capacity)
+		p.boundsCheck("jle")
+	}
+	loadInteger(rdi, extra.Low)
+	p.issueCommand("test rdi, rdi")
+	p.boundsCheck("jns")
+	p.issueCommand("cmp rcx, rdi")
+	p.boundsCheck("jge")
+	// rcx becomes the length
+	p.issueCommand("sub rcx, rdi")
+	if elementSize := p.typeTable[out].(typing.Slice).OfWhat.Size(); elementSize != 1 {
+		p.issueCommand(fmt.Sprintf("imul rdi, rdi, %d", elementSize))
+	}
+	p.issueCommand("add rsi, rdi")
+
+	p.ensureStackOffsetValid(out)
+	p.issueCommand(fmt.Sprintf("mov qword [rbp-%d], rsi", p.varStorage[out].rbpOffset))
+	p.issueCommand(fmt.Sprintf("mov qword [rbp-%d+8], rcx", p.varStorage[out].rbpOffset))
+}
+
 func (p *procGen) evalCompare(opt *ir.Inst) int64 {
 	extra := opt.Extra.(ir.CompareExtra)
 	leftValue := p.getPrecomputedValue(opt.ReadOperand)
 	rightValue := p.getPrecomputedValue(extra.Right)
-	var result bool
-	switch extra.How {
-	case ir.Lesser:
-		result = leftValue < rightValue
-	case ir.LesserOrEqual:
-		result = leftValue <= rightValue
-	case ir.Greater:
-		result = leftValue > rightValue
-	case ir.GreaterOrEqual:
-		result = leftValue >= rightValue
-	case ir.AreEqual:
-		result = leftValue == rightValue
-	case ir.NotEqual:
-		result = leftValue != rightValue
-	}
-
-	if result {
+	if ir.FoldCompare(extra.How, leftValue, rightValue) {
 		return 1
 	} else {
 		return 0
 	}
 }
 
+// wrap a value the same way writing it into a var of the same type as vn would
+func (p *procGen) wrapToVarSize(vn int, value int64) int64 {
+	shift := uint(64 - p.sizeof(vn)*8)
+	if shift == 0 {
+		return value
+	}
+	if p.typer.IsUnsigned(p.typeTable[vn]) {
+		return int64(uint64(value) << shift >> shift)
+	}
+	return value << shift >> shift
+}
+
+func (p *procGen) shiftMnemonic(opt *ir.Inst) string {
+	if opt.Type == ir.ShiftLeft {
+		return "shl"
+	}
+	if p.typer.IsUnsigned(p.typeTable[opt.Left()]) {
+		return "shr"
+	}
+	return "sar"
+}
+
+func bitwiseMnemonic(instType ir.InstType) string {
+	switch instType {
+	case ir.BitAnd:
+		return "and"
+	case ir.BitOr:
+		return "or"
+	case ir.BitXor:
+		return "xor"
+	}
+	panic("ice: not a bitwise instruction")
+}
+
+func (p *procGen) evalBitwise(opt *ir.Inst, leftValue int64, rightValue int64) int64 {
+	left := opt.Left()
+	leftValue = p.wrapToVarSize(left, leftValue)
+	result := ir.FoldInteger(opt.Type, leftValue, rightValue, p.sizeof(left), p.typer.IsUnsigned(p.typeTable[left]))
+	return p.wrapToVarSize(left, result)
+}
+
+func (p *procGen) evalDivision(opt *ir.Inst, leftValue int64, rightValue int64) int64 {
+	left := opt.Left()
+	unsigned := p.typer.IsUnsigned(p.typeTable[left])
+	if unsigned {
+		leftValue = p.wrapToVarSize(left, leftValue)
+	}
+	return ir.FoldInteger(opt.Type, leftValue, rightValue, p.sizeof(left), unsigned)
+}
+
+func (p *procGen) mulMnemonic(vn int) string {
+	if p.typer.IsUnsigned(p.typeTable[vn]) {
+		return "mul"
+	}
+	return "imul"
+}
+
+func (p *procGen) divMnemonic(vn int) string {
+	if p.typer.IsUnsigned(p.typeTable[vn]) {
+		return "div"
+	}
+	return "idiv"
+}
+
+// set up the upper half of the dividend. The lower half should already be in rax
+func (p *procGen) extendDividend(dividend int) {
+	size := p.sizeof(dividend)
+	if p.typer.IsUnsigned(p.typeTable[dividend]) {
+		if size == 1 {
+			p.issueCommand("movzx ax, al")
+		} else {
+			p.issueCommand("xor rdx, rdx")
+		}
+		return
+	}
+	switch size {
+	case 1:
+		p.issueCommand("cbw")
+	case 2:
+		p.issueCommand("cwd")
+	case 4:
+		p.issueCommand("cdq")
+	case 8:
+		p.issueCommand("cqo")
+	}
+}
+
+// the dividend stays in rax, so put the remainder there
+func (p *procGen) moveRemainderToRax(dividend int) {
+	if p.sizeof(dividend) == 1 {
+		p.issueCommand("mov al, ah")
+	} else {
+		p.issueCommand("mov rax, rdx")
+	}
+}
+
 func (p *procGen) startLoop() {
 	p.preLoopVarState = append(p.preLoopVarState, p.copyVarState())
 }
@@ -1306,7 +2453,7 @@ func (p *procGen) generateSingleInst(optIdx int, opt ir.Inst) {
 		return
 	}
 	if opt.GeneratedFrom != nil {
-		optLineIdx := opt.GeneratedFrom.GetLineNumber()
+		optLineIdx := opt.StatementLine
 		if optLineIdx > p.currentLineIdx {
 			// println("source line", optLineIdx+1)
 			p.decommissionAllTempVars()
@@ -1332,6 +2479,24 @@ func (p *procGen) generateSingleInst(optIdx int, opt ir.Inst) {
 	case ir.ArrayToPointer:
 		p.arrayToPointer(optIdx, opt)
 		return
+	case ir.BoundsCheck:
+		p.genBoundsCheck(opt)
+		return
+	case ir.TakeSlice:
+		p.genTakeSlice(opt)
+		return
+	case ir.GlobalAddress:
+		out := opt.Out()
+		p.endPrecomputation(out)
+		outReg := p.ensureInRegister(out)
+		p.issueCommand(fmt.Sprintf("mov %s, %s", p.registers.all[outReg].qwordName, globalLabel(opt.Extra.(string))))
+		return
+	case ir.ProcAddress:
+		out := opt.Out()
+		p.endPrecomputation(out)
+		outReg := p.ensureInRegister(out)
+		p.issueCommand(fmt.Sprintf("lea %s, [rel proc_%s]", p.registers.all[outReg].qwordName, procSymbol(opt.Extra.(string), 0)))
+		return
 	case ir.IndirectLoad:
 		p.genIndirectLoad(optIdx, opt)
 		return
@@ -1360,6 +2525,9 @@ func (p *procGen) generateSingleInst(optIdx int, opt ir.Inst) {
 	case ir.OptionSelectEnd:
 		p.endOptionSelect()
 		return
+	case ir.Switch:
+		p.genSwitch(optIdx, opt)
+		return
 	}
 
 	mut := ir.FindMutationVar(&opt)
@@ -1389,6 +2557,15 @@ func (p *procGen) generateSingleInst(optIdx int, opt ir.Inst) {
 	case ir.Call:
 		p.genCall(optIdx, opt)
 		return
+	case ir.Cast:
+		p.genCast(opt.Out(), opt.In())
+		return
+	case ir.StructLiteral:
+		p.genStructLiteral(opt)
+		return
+	case ir.ArrayLiteral:
+		p.genArrayLiteral(opt)
+		return
 	case ir.Return:
 		p.genReturn(optIdx, opt)
 		return
@@ -1417,16 +2594,17 @@ func (p *procGen) generateSingleInst(optIdx int, opt ir.Inst) {
 func (p *procGen) generate() {
 	{
 		paramOffset := -16 - 8*len(preservedRegisters)
+		argRegs := argRegisters(p.typer, p.typeTable[:p.block.NumberOfArgs], p.callerProvidesReturnSpace)
 		for i := 0; i < p.block.NumberOfArgs; i++ {
-			regOrder := i
-			if p.callerProvidesReturnSpace {
-				regOrder += 1
+			if _, isSlice := p.typeTable[i].(typing.Slice); isSlice && argRegs[i] != invalidRegister {
+				// spilled once the frame is set up
+				continue
 			}
-			if regOrder < len(paramPassingRegOrder) {
-				p.loadRegisterWithVar(paramPassingRegOrder[regOrder], i)
+			if argRegs[i] != invalidRegister {
+				p.loadRegisterWithVar(argRegs[i], i)
 			} else {
 				p.varStorage[i].rbpOffset = paramOffset
-				paramOffset -= 8 // params are rounded to eightbytes
+				paramOffset -= roundToEightbytes(p.sizeof(i)) // params are rounded to eightbytes
 			}
 		}
 	}
@@ -1447,12 +2625,12 @@ func (p *procGen) generate() {
 	for _, jump := range p.conditionalJumps {
 		p.out = jump.out
 		p.fullVarState = jump.state
-		p.conditionalJump(p.block.Opts[jump.optIdx])
+		p.conditionalJump(jump.inst)
 	}
 	for _, jump := range p.jumps {
 		p.out = jump.out
 		p.fullVarState = jump.state
-		p.jump(&p.block.Opts[jump.optIdx])
+		p.jump(&jump.inst)
 	}
 }
 
@@ -1483,7 +2661,8 @@ func (p *procGen) endPrecomputingAndMaterialize(vn int) {
 // returns whether whether the func was able to do precomputation for the instruction
 func (p *procGen) doPrecomputaion(optIdx int, opt ir.Inst) bool {
 	switch opt.Type {
-	case ir.Add, ir.Sub, ir.Div, ir.Mult, ir.And, ir.Or, ir.Increment, ir.Decrement:
+	case ir.Add, ir.Sub, ir.Div, ir.Mod, ir.Mult, ir.And, ir.Or, ir.Increment, ir.Decrement,
+		ir.BitAnd, ir.BitOr, ir.BitXor, ir.ShiftLeft, ir.ShiftRight:
 		if !p.valueKnown(opt.MutateOperand) {
 			return false
 		}
@@ -1507,7 +2686,7 @@ func (p *procGen) doPrecomputaion(optIdx int, opt ir.Inst) bool {
 		rightValue := p.getPrecomputedValue(right)
 		switch precomp.valueType {
 		case integer:
-			precomp.value += rightValue
+			precomp.value = ir.FoldInteger(ir.Add, precomp.value, rightValue, p.sizeof(left), false)
 		case pointerRelativeToVar, pointerRelativeToStackBase:
 			pointedToSize := p.typeTable[left].(typing.Pointer).ToWhat.Size()
 			delta := int64(pointedToSize) * rightValue
@@ -1519,16 +2698,16 @@ func (p *procGen) doPrecomputaion(optIdx int, opt ir.Inst) bool {
 		default:
 			panic("adding to an unsupported precomp value type " + precomp.valueType.String())
 		}
-	case ir.Sub:
-		p.precompute[opt.Left()].value -= p.getPrecomputedValue(opt.Right())
-	case ir.Mult:
-		p.precompute[opt.Left()].value *= p.getPrecomputedValue(opt.Right())
-	case ir.Div:
+	case ir.Sub, ir.Mult:
+		left := opt.Left()
+		p.precompute[left].value = ir.FoldInteger(opt.Type, p.precompute[left].value, p.getPrecomputedValue(opt.Right()), p.sizeof(left), false)
+	case ir.Div, ir.Mod:
+		left := opt.Left()
 		rightValue := p.getPrecomputedValue(opt.Right())
 		if rightValue == 0 {
 			panic(parsing.ErrorFromNode(opt.GeneratedFrom, "Divide by zero"))
 		}
-		p.precompute[opt.Left()].value /= rightValue
+		p.precompute[left].value = p.evalDivision(&opt, p.getPrecomputedValue(left), rightValue)
 	case ir.Compare:
 		extra := opt.Extra.(ir.CompareExtra)
 		if !(p.valueKnown(opt.ReadOperand) && p.valueKnown(extra.Right)) {
@@ -1551,19 +2730,26 @@ func (p *procGen) doPrecomputaion(optIdx int, opt ir.Inst) bool {
 		p.precompute[opt.Left()].value &= p.getPrecomputedValue(opt.Right())
 	case ir.Or:
 		p.precompute[opt.Left()].value |= p.getPrecomputedValue(opt.Right())
+	case ir.BitAnd, ir.BitOr, ir.BitXor, ir.ShiftLeft, ir.ShiftRight:
+		left := opt.Left()
+		p.precompute[left].value = p.evalBitwise(&opt, p.getPrecomputedValue(left), p.getPrecomputedValue(opt.Right()))
+	case ir.BitNot:
+		p.precompute[opt.Out()].valueType = integer
+		p.precompute[opt.Out()].value = p.wrapToVarSize(opt.Out(), ^p.getPrecomputedValue(opt.In()))
 	case ir.PeelStruct:
 		in := opt.In()
 		fieldName := opt.Extra.(string)
 		switch inType := p.typeTable[in].(type) {
-		case *typing.StructRecord:
-			_, memberIsPointer := inType.Members[fieldName].Type.(typing.Pointer)
+		case *typing.StructRecord, typing.Slice:
+			record, _ := typing.AsStruct(inType)
+			memberIsPointer := typing.PeeledByValue(record.Members[fieldName].Type)
 			// If it's a pointer we would need to do a load
 			if memberIsPointer {
 				return false
 			}
 		case typing.Pointer:
-			record := inType.ToWhat.(*typing.StructRecord)
-			_, memberIsPointer := record.Members[fieldName].Type.(typing.Pointer)
+			record, _ := typing.AsStruct(inType.ToWhat)
+			memberIsPointer := typing.PeeledByValue(record.Members[fieldName].Type)
 			// If it's a pointer we would need to deference in. Can't do that at compile time.
 			if memberIsPointer {
 				return false
@@ -1575,10 +2761,11 @@ func (p *procGen) doPrecomputaion(optIdx int, opt ir.Inst) bool {
 		in := opt.In()
 		out := opt.Out()
 		switch inType := p.typeTable[in].(type) {
-		case *typing.StructRecord:
+		case *typing.StructRecord, typing.Slice:
+			record, _ := typing.AsStruct(inType)
 			p.ensureStackOffsetValid(in)
 			fieldName := opt.Extra.(string)
-			memberOffset := inType.Members[fieldName].Offset
+			memberOffset := record.Members[fieldName].Offset
 			p.precompute[out].valueType = pointerRelativeToStackBase
 			p.precompute[out].value = int64(-p.varStorage[in].rbpOffset + memberOffset)
 			p.precompute[out].precomputedOnce = true
@@ -1594,7 +2781,7 @@ func (p *procGen) doPrecomputaion(optIdx int, opt ir.Inst) bool {
 			p.precompute[out].precomputedOnce = true
 			return true
 		case typing.Pointer:
-			record, pointerToStruct := inType.ToWhat.(*typing.StructRecord)
+			record, pointerToStruct := typing.AsStruct(inType.ToWhat)
 			if !pointerToStruct {
 				return false
 			}
@@ -1702,12 +2889,12 @@ func (p *procGen) genInstPartialKnown(optIdx int, opt ir.Inst) {
 		p.issueCommand(fmt.Sprintf("mov %s, %d", tmpStackStorage, p.getPrecomputedValue(r)))
 		if p.sizeof(l) == 1 {
 			p.loadRegisterWithVar(rax, l)
-			p.issueCommand(fmt.Sprintf("imul %s", tmpStackStorage))
+			p.issueCommand(fmt.Sprintf("%s %s", p.mulMnemonic(l), tmpStackStorage))
 		} else {
 			p.ensureInRegister(l)
 			p.issueCommand(fmt.Sprintf("imul %s, %s", p.fittingRegisterName(l), tmpStackStorage))
 		}
-	case ir.Div:
+	case ir.Div, ir.Mod:
 		l := opt.Left()
 		r := opt.Right()
 		precompValue := p.getPrecomputedValue(r)
@@ -1718,8 +2905,11 @@ func (p *procGen) genInstPartialKnown(optIdx int, opt ir.Inst) {
 		p.issueCommand(fmt.Sprintf("mov %s, %d", tmpStackStorage, precompValue))
 		p.loadRegisterWithVar(rax, l)
 		p.freeUpRegisters(true, rdx)
-		p.issueCommand("xor rdx, rdx")
-		p.issueCommand(fmt.Sprintf("div %s", tmpStackStorage))
+		p.extendDividend(l)
+		p.issueCommand(fmt.Sprintf("%s %s", p.divMnemonic(l), tmpStackStorage))
+		if opt.Type == ir.Mod {
+			p.moveRemainderToRax(l)
+		}
 	case ir.Compare:
 		extra := opt.Extra.(ir.CompareExtra)
 		l := opt.ReadOperand
@@ -1758,15 +2948,36 @@ func (p *procGen) genInstPartialKnown(optIdx int, opt ir.Inst) {
 		} else {
 			p.andOrImm(&opt, preCompValue)
 		}
+	case ir.BitAnd, ir.BitOr, ir.BitXor:
+		l := opt.Left()
+		imm := p.wrapToVarSize(l, p.getPrecomputedValue(opt.Right()))
+		mnemonic := bitwiseMnemonic(opt.Type)
+		if imm >= math.MinInt32 && imm <= math.MaxInt32 {
+			p.issueCommand(fmt.Sprintf("%s %s, %d", mnemonic, p.varOperand(l), imm))
+		} else {
+			// no 64 bit immediate for these
+			tmpReg := p.findOrMakeFreeReg()
+			p.issueCommand(fmt.Sprintf("mov %s, %d", p.registers.all[tmpReg].qwordName, imm))
+			p.issueCommand(fmt.Sprintf("%s %s, %s", mnemonic, p.varOperand(l), p.registers.all[tmpReg].qwordName))
+		}
+	case ir.ShiftLeft, ir.ShiftRight:
+		l := opt.Left()
+		count := p.getPrecomputedValue(opt.Right()) & ir.ShiftCountMask(p.sizeof(l))
+		p.issueCommand(fmt.Sprintf("%s %s, %d", p.shiftMnemonic(&opt), p.varOperand(l), count))
+	case ir.BitNot:
+		out := opt.Out()
+		outReg := p.ensureInRegister(out)
+		p.loadKnownValueIntoReg(opt.In(), outReg)
+		p.issueCommand(fmt.Sprintf("not %s", p.fittingRegisterName(out)))
 	case ir.JumpIfTrue:
 		if p.getPrecomputedValue(opt.ReadOperand) != 0 {
-			p.jumpOrDelayedJump(optIdx, &opt)
+			p.jumpOrDelayedJump(&opt)
 		} else {
 			p.issueCommand("; never jumps")
 		}
 	case ir.JumpIfFalse:
 		if p.getPrecomputedValue(opt.ReadOperand) == 0 {
-			p.jumpOrDelayedJump(optIdx, &opt)
+			p.jumpOrDelayedJump(&opt)
 		} else {
 			p.issueCommand("; never jumps")
 		}
@@ -1822,9 +3033,9 @@ func (p *procGen) genInstPartialKnown(optIdx int, opt ir.Inst) {
 			panic("ice: precompute-generate peeling a non pointer. Can only handle precomputed pointers")
 		}
 		fieldName := opt.Extra.(string)
-		record := pointer.ToWhat.(*typing.StructRecord)
+		record, _ := typing.AsStruct(pointer.ToWhat)
 		member := record.Members[fieldName]
-		if _, memberIsPointer := member.Type.(typing.Pointer); !memberIsPointer {
+		if !typing.PeeledByValue(member.Type) {
 			panic("ice: genInstPartialKnown asked to peel a struct by doing anything but dereferencing")
 		}
 		offset := member.Offset
@@ -1838,6 +3049,19 @@ func (p *procGen) genInstPartialKnown(optIdx int, opt ir.Inst) {
 }
 
 func (p *procGen) genInstAllRuntimeVars(optIdx int, opt ir.Inst) {
+	switch opt.Type {
+	case ir.Add, ir.Sub, ir.Mult, ir.Div:
+		if p.isFloat(opt.Left()) {
+			p.genFloatArithmetic(opt)
+			return
+		}
+	case ir.Compare:
+		if p.isFloat(opt.In()) {
+			p.genFloatCompare(opt)
+			return
+		}
+	}
+
 	switch opt.Type {
 	case ir.Assign:
 		p.varVarCopy(opt.Left(), opt.Right())
@@ -1896,32 +3120,59 @@ func (p *procGen) genInstAllRuntimeVars(optIdx int, opt ir.Inst) {
 		if p.sizeof(l) == 1 {
 			// we have to bring r to a register to do a 8 bit multiply
 			p.ensureInRegister(r)
-			p.issueCommand(fmt.Sprintf("imul %s", p.registerOf(r).byteName))
+			p.issueCommand(fmt.Sprintf("%s %s", p.mulMnemonic(l), p.registerOf(r).byteName))
 		} else if p.inRegister(r) {
+			// the lower half of the product is the same for signed and unsigned
 			p.regRegCommandSizedToFirst("imul", l, r)
 		} else {
 			p.regMemCommand("imul", l, r)
 		}
-	case ir.Div:
+	case ir.Div, ir.Mod:
 		l := opt.Left()
 		r := opt.Right()
 
 		p.loadRegisterWithVar(rax, l)
 		p.freeUpRegisters(true, rdx)
-		p.issueCommand("xor rdx, rdx")
+		p.extendDividend(l)
 		needSignExtension := p.sizeof(l) > p.sizeof(r)
 		if !p.inRegister(r) && needSignExtension {
 			p.loadRegisterWithVar(r8, r) // got to bring it into register to do sign extension
 		}
 		if p.inRegister(r) && p.varStorage[r].currentRegister != rdx {
 			rRegLeftSize := p.signOrZeroExtendIfNeeded(r, l)
-			p.issueCommand(fmt.Sprintf("idiv %s", rRegLeftSize))
+			p.issueCommand(fmt.Sprintf("%s %s", p.divMnemonic(l), rRegLeftSize))
 		} else {
 			if !p.hasStackStorage(r) {
 				panic("operand to div doens't have stack offset nor is it in register. Where is the value?")
 			}
-			p.issueCommand(fmt.Sprintf("idiv %s", p.stackOperand(r)))
+			p.issueCommand(fmt.Sprintf("%s %s", p.divMnemonic(l), p.stackOperand(r)))
 		}
+		if opt.Type == ir.Mod {
+			p.moveRemainderToRax(l)
+		}
+	case ir.BitAnd, ir.BitOr, ir.BitXor:
+		l := opt.Left()
+		r := opt.Right()
+		mnemonic := bitwiseMnemonic(opt.Type)
+		p.ensureInRegister(r)
+		rRegLeftSize := p.signOrZeroExtendIfNeeded(r, l)
+		if p.inRegister(l) {
+			p.issueCommand(fmt.Sprintf("%s %s, %s", mnemonic, p.fittingRegisterName(l), rRegLeftSize))
+		} else {
+			p.memRegCommand(mnemonic, l, r)
+		}
+	case ir.ShiftLeft, ir.ShiftRight:
+		// the count has to be in cl
+		p.loadRegisterWithVar(rcx, opt.Right())
+		p.dontSwap[rcx] = true
+		p.issueCommand(fmt.Sprintf("%s %s, cl", p.shiftMnemonic(&opt), p.varOperand(opt.Left())))
+	case ir.BitNot:
+		out := opt.Out()
+		in := opt.In()
+		p.ensureInRegister(in)
+		p.ensureInRegister(out)
+		p.regRegCommand("mov", out, in)
+		p.issueCommand(fmt.Sprintf("not %s", p.fittingRegisterName(out)))
 	case ir.JumpIfFalse, ir.ShortJumpIfFalse, ir.ShortJumpIfTrue, ir.JumpIfTrue:
 		label := opt.Extra.(string)
 		in := opt.In()
@@ -1937,13 +3188,13 @@ func (p *procGen) genInstAllRuntimeVars(optIdx int, opt ir.Inst) {
 			p.conditionalJump(opt)
 		} else {
 			p.conditionalJumps = append(p.conditionalJumps, preJumpState{
-				out:    p.out,
-				state:  p.copyVarState(),
-				optIdx: optIdx})
+				out:   p.out,
+				state: p.copyVarState(),
+				inst:  opt})
 			p.switchToNewOutBlock()
 		}
 	case ir.Jump:
-		p.jumpOrDelayedJump(optIdx, &opt)
+		p.jumpOrDelayedJump(&opt)
 	case ir.Label:
 		label := opt.Extra.(string)
 		fmt.Fprintf(p.out.buffer, ".%s:\n", label)
@@ -1963,7 +3214,8 @@ func (p *procGen) genInstAllRuntimeVars(optIdx int, opt ir.Inst) {
 			p.labelToState[label] = p.copyVarState()
 		}
 	case ir.StartProc:
-		fmt.Fprintf(p.out.buffer, "proc_%s:\n", opt.Extra.(string))
+		p.procName = procSymbol(opt.Extra.(string), p.overload)
+		fmt.Fprintf(p.out.buffer, "proc_%s:\n", p.procName)
 		p.issueCommand("push rbp")
 		for _, reg := range preservedRegisters {
 			p.issueCommand(fmt.Sprintf("push %s", p.registers.all[reg].qwordName))
@@ -1975,6 +3227,7 @@ func (p *procGen) genInstAllRuntimeVars(optIdx int, opt ir.Inst) {
 		}
 		p.prologueBlock = p.out
 		p.switchToNewOutBlock()
+		p.spillSliceArgs()
 	case ir.EndProc:
 		fmt.Fprintln(p.out.buffer, ".end_of_proc:")
 		p.issueCommand("mov rsp, rbp")
@@ -2046,17 +3299,18 @@ func (p *procGen) genInstAllRuntimeVars(optIdx int, opt ir.Inst) {
 		case typing.Pointer:
 			p.ensureInRegister(in)
 			inReg := p.registerOf(in)
-			record := baseType.ToWhat.(*typing.StructRecord)
+			record, _ := typing.AsStruct(baseType.ToWhat)
 			memberOffset := record.Members[fieldName].Offset
-			_, memberIsPointer := record.Members[fieldName].Type.(typing.Pointer)
+			memberIsPointer := typing.PeeledByValue(record.Members[fieldName].Type)
 			if memberIsPointer {
 				p.issueCommand(fmt.Sprintf("mov %s, qword [%s+%d]", outReg.qwordName, inReg.qwordName, memberOffset))
 			} else {
 				p.issueCommand(fmt.Sprintf("lea %s, [%s+%d]", outReg.qwordName, inReg.qwordName, memberOffset))
 			}
-		case *typing.StructRecord:
-			memberOffset := baseType.Members[fieldName].Offset
-			_, memberIsPointer := baseType.Members[fieldName].Type.(typing.Pointer)
+		case *typing.StructRecord, typing.Slice:
+			record, _ := typing.AsStruct(baseType)
+			memberOffset := record.Members[fieldName].Offset
+			memberIsPointer := typing.PeeledByValue(record.Members[fieldName].Type)
 			p.ensureStackOffsetValid(in)
 			if memberIsPointer {
 				p.issueCommand(fmt.Sprintf("mov %s, qword [rbp-%d+%d]", outReg.qwordName, p.varStorage[in].rbpOffset, memberOffset))
@@ -2208,7 +3462,8 @@ func collectOutput(firstBlock *outputBlock, out io.Writer) {
 	}
 }
 
-func X86ForBlock(out io.Writer, block frontend.OptBlock, typeTable []typing.TypeRecord, globalEnv *typing.EnvRecord, typer *typing.Typer, procRecord typing.ProcRecord) *bytes.Buffer {
+// overload is the index of the proc among the procs that share its name
+func X86ForBlock(out io.Writer, block frontend.OptBlock, typeTable []typing.TypeRecord, globalEnv *typing.EnvRecord, typer *typing.Typer, procRecord typing.ProcRecord, overload int) *bytes.Buffer {
 	firstOut := newOutputBlock()
 	var staticDataBuf bytes.Buffer
 	gen := procGen{
@@ -2223,8 +3478,9 @@ func X86ForBlock(out io.Writer, block frontend.OptBlock, typeTable []typing.Type
 		labelToState:              make(map[string]*fullVarState),
 		lastUsage:                 findLastusage(block),
 		procRecord:                procRecord,
+		overload:                  overload,
 		precompute:                make([]precomputeInfo, block.NumberOfVars),
-		callerProvidesReturnSpace: (*(procRecord.Return)).Size() > 16,
+		callerProvidesReturnSpace: returnsThroughMemory(typer, *procRecord.Return),
 	}
 	constantVars := findConstantVars(block)
 	stopPrecomputation := findWhenToStopPrecomputation(block)
@@ -2234,3 +3490,97 @@ func X86ForBlock(out io.Writer, block frontend.OptBlock, typeTable []typing.Type
 	collectOutput(gen.firstOutputBlock, out)
 	return &staticDataBuf
 }
+
+func globalLabel(name string) string {
+	return "global_" + name
+}
+
+// WriteGlobals lays out storage for global variables. The ones with an initial value go in the
+// data section and the rest go in bss so they start out zeroed.
+// The caller should have switched to the data section already.
+// Lay out value as something of type record that starts offset bytes into the global. Bytes that
+// array and struct literals don't give a value for are zero.
+func writeGlobalValue(data *bytes.Buffer, stringData *bytes.Buffer, label string, offset int, record typing.TypeRecord, value interface{}) {
+	if composite, isComposite := value.(frontend.CompositeValue); isComposite {
+		written := 0
+		switch record := record.(type) {
+		case typing.Array:
+			for _, element := range composite.Values {
+				writeGlobalValue(data, stringData, label, offset+written, record.OfWhat, element)
+				written += record.OfWhat.Size()
+			}
+		case *typing.StructRecord:
+			fields := typing.StructLiteralFields(record, composite.Names, composite.Literal.(parsing.StructLiteral))
+			order := make([]int, len(fields))
+			for i := range order {
+				order[i] = i
+			}
+			sort.Slice(order, func(i, j int) bool {
+				return fields[order[i]].Offset < fields[order[j]].Offset
+			})
+			for _, i := range order {
+				if gap := fields[i].Offset - written; gap > 0 {
+					fmt.Fprintf(data, "\ttimes %d db 0\n", gap)
+				}
+				writeGlobalValue(data, stringData, label, offset+fields[i].Offset, fields[i].Type, composite.Values[i])
+				written = fields[i].Offset + fields[i].Type.Size()
+			}
+		}
+		if rest := record.Size() - written; rest > 0 {
+			fmt.Fprintf(data, "\ttimes %d db 0\n", rest)
+		}
+		return
+	}
+	switch value := value.(type) {
+	case int64, uint64, uint8:
+		directive := map[int]string{1: "db", 2: "dw", 4: "dd", 8: "dq"}[record.Size()]
+		fmt.Fprintf(data, "\t%s\t%d\n", directive, value)
+	case float64:
+		if record.Size() == 4 {
+			fmt.Fprintf(data, "\tdd\t0x%x\n", math.Float32bits(float32(value)))
+		} else {
+			fmt.Fprintf(data, "\tdq\t0x%x\n", math.Float64bits(value))
+		}
+	case bool:
+		byteValue := 0
+		if value {
+			byteValue = 1
+		}
+		fmt.Fprintf(data, "\tdb\t%d\n", byteValue)
+	case string:
+		stringLabel := "static_string_" + label
+		if offset > 0 {
+			stringLabel = fmt.Sprintf("%s_%d", stringLabel, offset)
+		}
+		fmt.Fprintf(data, "\tdq\t%s\n", stringLabel)
+		writeStringData(stringData, stringLabel, value)
+	default:
+		panic("ice: unknown initial value for a global")
+	}
+}
+
+func WriteGlobals(out io.Writer, env *typing.EnvRecord, initialValues map[string]interface{}) {
+	names := make([]string, 0, len(env.Globals))
+	for name := range env.Globals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var data, stringData, bss bytes.Buffer
+	for _, name := range names {
+		label := globalLabel(name)
+		value, initialized := initialValues[name]
+		if !initialized {
+			fmt.Fprintf(&bss, "alignb 8\n%s:\n\tresb\t%d\n", label, env.Globals[name].Size())
+			continue
+		}
+		fmt.Fprintf(&data, "align 8\n%s:\n", label)
+		writeGlobalValue(&data, &stringData, label, 0, env.Globals[name], value)
+	}
+	data.WriteTo(out)
+	stringData.WriteTo(out)
+	if bss.Len() > 0 {
+		io.WriteString(out, "section .bss\n")
+		bss.WriteTo(out)
+	}
+}
@@ -21,13 +21,13 @@ import (
 var fixture []byte
 
 func (p *procGen) saveAllRegisters() {
-	for _, reg := range p.registers.all {
+	for _, reg := range p.registers.all[:numRegisters] {
 		p.issueCommand(fmt.Sprintf("push %s", reg.qwordName))
 	}
 }
 
 func (p *procGen) restoreAllRegisters() {
-	for i := len(p.registers.all) - 1; i >= 0; i-- {
+	for i := int(numRegisters) - 1; i >= 0; i-- {
 		reg := p.registers.all[i]
 		p.issueCommand(fmt.Sprintf("pop %s", reg.qwordName))
 	}
@@ -16,7 +16,10 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"reflect"
 	"sort"
+	"strings"
+	"unicode"
 )
 
 const dumpIr = false
@@ -28,7 +31,7 @@ type embedGraphNode struct {
 }
 
 // resolve all the type of members in structs and build the global environment
-func buildGlobalEnv(typer *typing.Typer, env *typing.EnvRecord, nodeToStruct map[*parsing.ASTNode]*typing.StructRecord, workOrders []*frontend.ProcWorkOrder) error {
+func buildGlobalEnv(typer *typing.Typer, env *typing.EnvRecord, nodeToStruct map[*parsing.ASTNode]*typing.StructRecord, enums []*typing.EnumRecord, workOrders []*frontend.ProcWorkOrder, globalDecls map[string]parsing.TypeDecl, globalValues map[string]interface{}) error {
 	notDone := make(map[string][]*typing.TypeRecord)
 	addUnresolved := func(unresolvedRecord *typing.TypeRecord) {
 		unresolved := (*unresolvedRecord).(typing.Unresolved)
@@ -39,34 +42,32 @@ func buildGlobalEnv(typer *typing.Typer, env *typing.EnvRecord, nodeToStruct map
 	for _, structRecord := range nodeToStruct {
 		for _, field := range structRecord.Members {
 			unresolved, isUnresolved := field.Type.(typing.Unresolved)
+			typing.ForEachUnresolved(&field.Type, addUnresolved)
 			if isUnresolved {
-				addUnresolved(&field.Type)
-
-				if unresolved.Decl.LevelOfIndirection == 0 && (unresolved.Decl.ArrayBase == nil || unresolved.Decl.ArrayBase.LevelOfIndirection == 0) {
+				decl := unresolved.Decl
+				if decl.LevelOfIndirection == 0 && decl.SliceOf == nil && (decl.ArrayBase == nil || (decl.ArrayBase.LevelOfIndirection == 0 && decl.ArrayBase.SliceOf == nil)) {
 					embedGraphString[structRecord] = append(embedGraphString[structRecord], typing.GrabUnresolvedName(unresolved))
 				}
 			}
 		}
 	}
 	for _, order := range workOrders {
-		argRecords := make([]typing.TypeRecord, len(order.ProcDecl.Args))
-		returnType := typer.TypeRecordFromDecl(order.ProcDecl.Return)
-		if _, returnIsUnresolved := returnType.(typing.Unresolved); returnIsUnresolved {
-			addUnresolved(&returnType)
-		}
+		order.Overload = len(env.Procs[order.Name])
+		env.Procs[order.Name] = append(env.Procs[order.Name], buildProcRecord(typer, order.ProcDecl, addUnresolved))
+	}
 
-		for i, argDecl := range order.ProcDecl.Args {
-			record := typer.TypeRecordFromDecl(argDecl.Type)
-			argRecords[i] = record
-			if _, recordIsUnresolved := record.(typing.Unresolved); recordIsUnresolved {
-				addUnresolved(&argRecords[i])
-			}
-		}
-		env.Procs[order.Name] = typing.ProcRecord{
-			&returnType,
-			argRecords,
-			order.ProcDecl.IsForeign,
+	globalRecords := make(map[string]*typing.TypeRecord)
+	for name, value := range globalValues {
+		if _, hasDecl := globalDecls[name]; hasDecl {
+			continue
 		}
+		record := typer.TypeImmediate(value)
+		globalRecords[name] = &record
+	}
+	for name, decl := range globalDecls {
+		record := typer.TypeRecordFromDecl(decl)
+		typing.ForEachUnresolved(&record, addUnresolved)
+		globalRecords[name] = &record
 	}
 
 	for node, structRecord := range nodeToStruct {
@@ -79,27 +80,134 @@ func buildGlobalEnv(typer *typing.Typer, env *typing.EnvRecord, nodeToStruct map
 		delete(notDone, name)
 		env.Types[structNode.Name.Name] = structRecord
 	}
+	for _, enumRecord := range enums {
+		for _, typeRecordPtr := range notDone[enumRecord.Name] {
+			unresolved := (*typeRecordPtr).(typing.Unresolved)
+			*typeRecordPtr = typing.BuildRecordAccordingToUnresolved(enumRecord, unresolved)
+		}
+		delete(notDone, enumRecord.Name)
+		env.Types[enumRecord.Name] = enumRecord
+	}
+	// instances of generic structs can have any of the types above in them
+	for name := range env.GenericStructs {
+		for _, typeRecordPtr := range notDone[name] {
+			if err := typer.ResolveUserTypes(env, typeRecordPtr); err != nil {
+				return err
+			}
+		}
+		delete(notDone, name)
+	}
 	if len(notDone) > 0 {
 		for typeName := range notDone {
 			return fmt.Errorf("%s does not name a type", typeName)
 		}
 	}
+	for name, record := range globalRecords {
+		env.Globals[name] = *record
+	}
+	// the argument types are only comparable once they are all resolved
+	for _, order := range workOrders {
+		overloads := env.Procs[order.Name]
+		if len(overloads) == 1 {
+			continue
+		}
+		if order.ProcDecl.IsForeign {
+			panic(parsing.ErrorFromNode(order.ProcDecl, "Foreign procs can't be overloaded"))
+		}
+		for i := 0; i < order.Overload; i++ {
+			if reflect.DeepEqual(overloads[i].Args, overloads[order.Overload].Args) {
+				panic(parsing.ErrorFromNode(order.ProcDecl, "Redeclaration of proc with the same argument types"))
+			}
+		}
+	}
+	// methods take the struct they are on first, either by pointer or by value
+	for _, order := range workOrders {
+		if order.Receiver == "" {
+			continue
+		}
+		receiver, isStruct := env.Types[order.Receiver].(*typing.StructRecord)
+		if !isStruct {
+			panic(parsing.ErrorFromNode(order.ProcDecl, "Methods can only be declared on structs"))
+		}
+		overloads := env.Procs[order.Name]
+		args := overloads[order.Overload].Args
+		if len(args) == 0 || !isReceiverType(args[0], receiver) {
+			panic(parsing.ErrorFromNode(order.ProcDecl, fmt.Sprintf("The first parameter of a method must be %s or *%s", receiver.Name, receiver.Name)))
+		}
+		// calls decide how to pass the receiver before picking an overload
+		_, byPointer := args[0].(typing.Pointer)
+		if _, firstByPointer := overloads[0].Args[0].(typing.Pointer); byPointer != firstByPointer {
+			panic(parsing.ErrorFromNode(order.ProcDecl, "Every overload of a method must take the receiver the same way"))
+		}
+	}
 	embedGraph := make(map[*typing.StructRecord]embedGraphNode)
 	for record, stringEmbedees := range embedGraphString {
 		sort.Slice(stringEmbedees, func(i, j int) bool {
 			return stringEmbedees[i] < stringEmbedees[j]
 		})
 		stringEmbedees = dedupSorted(stringEmbedees)
-		embedees := make([]*typing.StructRecord, len(stringEmbedees))
-		for i, name := range stringEmbedees {
-			embedees[i] = env.Types[name].(*typing.StructRecord)
+		embedees := make([]*typing.StructRecord, 0, len(stringEmbedees))
+		for _, name := range stringEmbedees {
+			// enums are embedded too but they don't have members to lay out
+			if embedee, isStruct := env.Types[name].(*typing.StructRecord); isStruct {
+				embedees = append(embedees, embedee)
+			}
 		}
 		embedGraph[record] = embedGraphNode{embedees: embedees}
 	}
 	resolveStructSize(nodeToStruct, embedGraph)
+	env.ResolveInstanceSizes()
 	return nil
 }
 
+// whether a method on receiver can take record as its first parameter
+func isReceiverType(record typing.TypeRecord, receiver *typing.StructRecord) bool {
+	if pointer, isPointer := record.(typing.Pointer); isPointer {
+		record = pointer.ToWhat
+	}
+	return record == receiver
+}
+
+// the unresolved types in the record are passed to handleUnresolved
+func buildProcRecord(typer *typing.Typer, decl parsing.ProcDecl, handleUnresolved func(*typing.TypeRecord)) typing.ProcRecord {
+	argRecords := make([]typing.TypeRecord, len(decl.Args))
+	returnType := typer.TypeRecordFromDecl(decl.Return)
+	if len(decl.ExtraReturns) == 0 {
+		typing.ForEachUnresolved(&returnType, handleUnresolved)
+	} else {
+		tuple := typing.Tuple{Types: []typing.TypeRecord{returnType}}
+		for _, extra := range decl.ExtraReturns {
+			tuple.Types = append(tuple.Types, typer.TypeRecordFromDecl(extra))
+		}
+		for i := range tuple.Types {
+			typing.ForEachUnresolved(&tuple.Types[i], handleUnresolved)
+		}
+		returnType = tuple
+	}
+
+	for i, argDecl := range decl.Args {
+		argRecords[i] = typer.TypeRecordFromDecl(argDecl.Type)
+		typing.ForEachUnresolved(&argRecords[i], handleUnresolved)
+	}
+	return typing.ProcRecord{
+		Return:    &returnType,
+		Args:      argRecords,
+		IsForeign: decl.IsForeign,
+	}
+}
+
+// errors inside instances of generic procs say which instance they come from
+func addInstanceContext(description string) {
+	err := recover()
+	if err == nil {
+		return
+	}
+	if userError, isUserError := err.(*errors.UserError); isUserError {
+		userError.Message += "\n    in " + description
+	}
+	panic(err)
+}
+
 func dedupSorted(slice []string) []string {
 	pushDist := 0
 	for i := 1; i < len(slice); i++ {
@@ -157,8 +265,74 @@ func resolveStructSizeVisit(structRecord *typing.StructRecord, embedGraph map[*t
 	structRecord.ResolveSizeAndOffset()
 }
 
+// constants at the top level can refer to each other regardless of the order they are declared in
+func evalGlobalConstants(nodes map[string]parsing.ASTNode) map[string]interface{} {
+	values := make(map[string]interface{})
+	evaluating := make(map[string]bool)
+	var lookup frontend.ConstantLookup
+	lookup = func(name string) (interface{}, bool) {
+		if value, done := values[name]; done {
+			return value, true
+		}
+		node, isConstant := nodes[name]
+		if !isConstant {
+			return nil, false
+		}
+		if evaluating[name] {
+			panic(parsing.ErrorFromNode(node, "Constant depends on itself"))
+		}
+		evaluating[name] = true
+		values[name] = frontend.EvalConstant(node, lookup)
+		return values[name], true
+	}
+	for name := range nodes {
+		lookup(name)
+	}
+	return values
+}
+
+// members without a value are one more than the member before them
+func buildEnum(typer *typing.Typer, node parsing.EnumDeclare, members []parsing.EnumMember, lookupConstant frontend.ConstantLookup) *typing.EnumRecord {
+	backing := typer.TypeRecordFromDecl(parsing.TypeDecl{Base: node.Backing})
+	if !backing.IsNumber() || typer.IsFloat(backing) {
+		panic(parsing.ErrorFromNode(node.Backing, "Enums must be backed by an integer type"))
+	}
+	record := &typing.EnumRecord{
+		Name:    node.Name.Name,
+		Backing: backing,
+		Members: make(map[string]int64),
+	}
+	lookup := func(name string) (interface{}, bool) {
+		if value, isMember := record.Members[name]; isMember {
+			return value, true
+		}
+		return lookupConstant(name)
+	}
+	var next int64
+	for _, member := range members {
+		if _, alreadyExist := record.Members[member.Name.Name]; alreadyExist {
+			panic(parsing.ErrorFromNode(member.Name, "Redeclaration of enum member"))
+		}
+		value := next
+		if member.Value != nil {
+			var isInt bool
+			value, isInt = frontend.EvalConstant(member.Value, lookup).(int64)
+			if !isInt {
+				panic(parsing.ErrorFromNode(member.Value, "Enum values must be integers"))
+			}
+		}
+		if !typer.IntegerCanHold(backing, value) {
+			panic(parsing.ErrorFromNode(member, fmt.Sprintf("Value doesn't fit in %s", backing.Rep())))
+		}
+		record.Members[member.Name.Name] = value
+		next = value + 1
+	}
+	return record
+}
+
 func doCompile(sourceLines []string, libc bool, asmOut io.Writer) {
 	var workOrders []*frontend.ProcWorkOrder
+	genericProcs := make(map[string]*frontend.ProcWorkOrder)
 	var labelGen frontend.LabelIdGen
 	parser := parsing.NewParser()
 	typer := typing.NewTyper()
@@ -166,6 +340,15 @@ func doCompile(sourceLines []string, libc bool, asmOut io.Writer) {
 	var nodesForProc []*parsing.ASTNode
 	env := typing.NewEnvRecord(typer)
 	structs := make(map[*parsing.ASTNode]*typing.StructRecord)
+	genericStructs := make(map[*parsing.ASTNode]*typing.GenericStruct)
+	globals := make(map[string]bool)
+	globalDecls := make(map[string]parsing.TypeDecl)
+	globalValues := make(map[string]interface{})
+	globalInitNodes := make(map[string]parsing.ASTNode)
+	constantNodes := make(map[string]parsing.ASTNode)
+	fieldDecls := make(map[*typing.StructField]parsing.TypeDecl)
+	var enumNodes []*parsing.ASTNode
+	enumMembers := make(map[*parsing.ASTNode][]parsing.EnumMember)
 	if libc {
 		library.AddLibcExtrasToEnv(env, typer)
 	}
@@ -187,21 +370,19 @@ func doCompile(sourceLines []string, libc bool, asmOut io.Writer) {
 		}
 
 		last := len(parser.OutBuffer) - 1
-		isComplete := parser.OutBuffer[last].IsComplete
 		node := parser.OutBuffer[last].Node
 		parent := parser.OutBuffer[last].Parent
 		var isForeignProc bool
 
 		exprNode, isExpr := (*node).(parsing.ExprNode)
-		if !isComplete && isExpr && exprNode.Op == parsing.ConstDeclare {
-			procDecl, isProc := exprNode.Right.(parsing.ProcDecl)
-			if !isProc {
-				continue
-			}
+		if procDecl, isProc := exprNode.Right.(parsing.ProcDecl); isExpr && isProc && exprNode.Op == parsing.ConstDeclare {
 			currentProc = node
 			if !procDecl.IsForeign {
 				continue
 			}
+			if len(procDecl.ExtraReturns) > 0 {
+				panic(parsing.ErrorFromNode(exprNode, "Foreign procs can only return one value"))
+			}
 			isForeignProc = true
 		}
 
@@ -214,103 +395,350 @@ func doCompile(sourceLines []string, libc bool, asmOut io.Writer) {
 		if _, isEnd := (*node).(parsing.BlockEnd); isForeignProc || (isEnd && parent == currentProc) {
 			procDeclare := (*currentProc).(parsing.ExprNode)
 			procDecl := procDeclare.Right.(parsing.ProcDecl)
-			procName := procDeclare.Left.(parsing.IdName).Name
+			var procName, receiver string
+			if method, isMethod := procDeclare.Left.(parsing.ExprNode); isMethod {
+				receiver = method.Left.(parsing.IdName).Name
+				procName = receiver + "." + method.Right.(parsing.IdName).Name
+				if procDecl.IsForeign {
+					panic(parsing.ErrorFromNode(procDeclare.Left, "Foreign procs can't be methods"))
+				}
+				if len(procDecl.TypeParams) > 0 {
+					panic(parsing.ErrorFromNode(procDeclare.Left, "Methods can't be generic"))
+				}
+			} else {
+				procName = procDeclare.Left.(parsing.IdName).Name
+			}
 			order := frontend.ProcWorkOrder{
 				Out:       make(chan frontend.OptBlock),
 				In:        nodesForProc,
 				Name:      procName,
+				Receiver:  receiver,
 				ProcDecl:  procDecl,
 				UserError: make(chan *errors.UserError),
 			}
-			workOrders = append(workOrders, &order)
-			if !isForeignProc {
-				go func() {
-					defer func() {
-						err := recover()
-						if err != nil {
-							switch err := err.(type) {
-							case *errors.UserError:
-								order.UserError <- err
-							default:
-								panic(err)
-							}
-						}
-					}()
-					frontend.GenForProc(&labelGen, &order)
-				}()
+			if len(procDecl.TypeParams) > 0 {
+				if _, exists := genericProcs[procName]; exists {
+					panic(parsing.ErrorFromNode(procDeclare.Left, "Generic procs can't be overloaded"))
+				}
+				genericProcs[procName] = &order
+			} else {
+				workOrders = append(workOrders, &order)
 			}
 			nodesForProc = nil
 			currentProc = nil
 			continue
 		}
 
-		if structDeclare, isStructDeclare := (*node).(parsing.StructDeclare); isStructDeclare {
+		if currentProc == nil && parent == nil {
+			var globalName parsing.IdName
+			switch global := (*node).(type) {
+			case parsing.ExprNode:
+				if global.Op == parsing.Declare || global.Op == parsing.ConstDeclare {
+					globalName = global.Left.(parsing.IdName)
+				}
+			case parsing.Declaration:
+				globalName = global.Name
+			case parsing.MultiAssign:
+				panic(parsing.ErrorFromNode(global, "Globals must be declared one at a time"))
+			}
+			if globalName.Name != "" {
+				if _, isConstant := constantNodes[globalName.Name]; isConstant || globals[globalName.Name] {
+					panic(parsing.ErrorFromNode(globalName, "Redeclaration of global"))
+				}
+			}
+			switch global := (*node).(type) {
+			case parsing.ExprNode:
+				switch global.Op {
+				case parsing.Declare:
+					globals[globalName.Name] = true
+					// initial values can use constants declared after them
+					globalInitNodes[globalName.Name] = global.Right
+				case parsing.ConstDeclare:
+					constantNodes[globalName.Name] = global.Right
+				}
+			case parsing.Declaration:
+				globals[globalName.Name] = true
+				globalDecls[globalName.Name] = global.Type
+			}
+		}
+
+		if structDeclare, isStructDeclare := (*node).(parsing.StructDeclare); isStructDeclare && len(structDeclare.TypeParams) > 0 {
+			generic := &typing.GenericStruct{
+				Name:      structDeclare.Name.Name,
+				IsUnion:   structDeclare.IsUnion,
+				Instances: make(map[string]*typing.StructRecord),
+			}
+			for _, param := range structDeclare.TypeParams {
+				generic.TypeParams = append(generic.TypeParams, param.Name)
+			}
+			genericStructs[node] = generic
+			env.GenericStructs[generic.Name] = generic
+		} else if isStructDeclare {
 			newStruct := typing.StructRecord{
 				Name:    string(structDeclare.Name.Name),
 				Members: make(map[string]*typing.StructField),
+				IsUnion: structDeclare.IsUnion,
 			}
 			structs[node] = &newStruct
 		}
 
+		if enumDeclare, isEnum := (*node).(parsing.EnumDeclare); isEnum {
+			if currentProc != nil {
+				panic(parsing.ErrorFromNode(enumDeclare, "Enums must be declared at the top level"))
+			}
+			enumNodes = append(enumNodes, node)
+			enumMembers[node] = enumDeclare.Members
+		}
+
+		if members, isMembers := (*node).(parsing.EnumMembers); isMembers {
+			enumMembers[parent] = append(enumMembers[parent], members.Members...)
+		}
+
 		if typeDeclare, isDecl := (*node).(parsing.Declaration); isDecl {
+			if generic, isGeneric := genericStructs[parent]; isGeneric {
+				generic.FieldNames = append(generic.FieldNames, typeDeclare.Name.Name)
+				generic.FieldDecls = append(generic.FieldDecls, typeDeclare.Type)
+			}
 			parentStruct, found := structs[parent]
 			if found {
-				newField := &typing.StructField{
-					Type: typer.TypeRecordFromDecl(typeDeclare.Type),
-				}
+				// the type is filled in once we know all the constants
+				newField := &typing.StructField{}
+				fieldDecls[newField] = typeDeclare.Type
 				parentStruct.MemberOrder = append(parentStruct.MemberOrder, newField)
 				parentStruct.Members[typeDeclare.Name.Name] = newField
 			}
 		}
 
 	}
+	if err := parser.Finish(); err != nil {
+		parseFailed = true
+		if userError, isUserError := err.(*errors.UserError); isUserError {
+			displayError(sourceLines, userError)
+		}
+	}
 	if parseFailed {
 		os.Exit(1)
 	}
 
+	constants := evalGlobalConstants(constantNodes)
+	lookupConstant := func(name string) (interface{}, bool) {
+		value, found := constants[name]
+		return value, found
+	}
+	var enums []*typing.EnumRecord
+	enumValues := make(map[string]map[string]int64)
+	for _, node := range enumNodes {
+		enumRecord := buildEnum(typer, (*node).(parsing.EnumDeclare), enumMembers[node], lookupConstant)
+		enums = append(enums, enumRecord)
+		enumValues[enumRecord.Name] = enumRecord.Members
+	}
+	for field, decl := range fieldDecls {
+		field.Type = typer.TypeRecordFromDecl(frontend.ResolveArraySizes(decl, lookupConstant))
+	}
+	for _, generic := range genericStructs {
+		for i, decl := range generic.FieldDecls {
+			generic.FieldDecls[i] = frontend.ResolveArraySizes(decl, lookupConstant)
+		}
+	}
+	for name, decl := range globalDecls {
+		globalDecls[name] = frontend.ResolveArraySizes(decl, lookupConstant)
+	}
+	for name, node := range globalInitNodes {
+		value := frontend.GlobalInitialValue(node, lookupConstant)
+		globalValues[name] = value
+		// array and struct literals say their type the same way a declaration does
+		if composite, isComposite := value.(frontend.CompositeValue); isComposite {
+			globalDecls[name] = composite.Type
+		}
+	}
+	allOrders := workOrders
+	for _, order := range genericProcs {
+		allOrders = append(allOrders, order)
+	}
+	for _, order := range allOrders {
+		order.ProcDecl.Return = frontend.ResolveArraySizes(order.ProcDecl.Return, lookupConstant)
+		for i := range order.ProcDecl.ExtraReturns {
+			order.ProcDecl.ExtraReturns[i] = frontend.ResolveArraySizes(order.ProcDecl.ExtraReturns[i], lookupConstant)
+		}
+		for i := range order.ProcDecl.Args {
+			order.ProcDecl.Args[i].Type = frontend.ResolveArraySizes(order.ProcDecl.Args[i].Type, lookupConstant)
+		}
+	}
+
+	generics := make(map[string]int)
+	for name, order := range genericProcs {
+		generics[name] = len(order.ProcDecl.TypeParams)
+		if _, isBuiltin := env.Procs[name]; isBuiltin {
+			panic(parsing.ErrorFromNode(order.ProcDecl, "Generic procs can't be overloaded"))
+		}
+	}
+	for _, order := range workOrders {
+		if generic, isGeneric := genericProcs[order.Name]; isGeneric {
+			panic(parsing.ErrorFromNode(generic.ProcDecl, "Generic procs can't be overloaded"))
+		}
+	}
+
+	// procs can use globals declared after them, so we wait until we've seen everything
+	startFrontend := func(order *frontend.ProcWorkOrder) {
+		order.Globals = globals
+		order.Constants = constants
+		order.Enums = enumValues
+		order.Generics = generics
+		if order.ProcDecl.IsForeign {
+			return
+		}
+		go func(order *frontend.ProcWorkOrder) {
+			defer func() {
+				err := recover()
+				if err != nil {
+					switch err := err.(type) {
+					case *errors.UserError:
+						order.UserError <- err
+					default:
+						panic(err)
+					}
+				}
+			}()
+			frontend.GenForProc(&labelGen, order)
+		}(order)
+	}
+	for _, order := range workOrders {
+		startFrontend(order)
+	}
+
 	if libc {
 		library.WriteLibcPrologue(asmOut)
 	} else {
 		library.WriteAssemblyPrologue(asmOut)
 	}
 
-	err := buildGlobalEnv(typer, env, structs, workOrders)
+	err := buildGlobalEnv(typer, env, structs, enums, workOrders, globalDecls, globalValues)
 	if err != nil {
 		panic(err)
 	}
+	initializedGlobals := make([]string, 0, len(globalValues))
+	for name := range globalValues {
+		initializedGlobals = append(initializedGlobals, name)
+	}
+	sort.Strings(initializedGlobals)
+	for _, name := range initializedGlobals {
+		typer.CheckGlobalValue(env, env.Globals[name], globalValues[name], globalInitNodes[name])
+	}
+
+	// Generic procs are instantiated as calls to them show up. Each instance is an overload of the generic
+	// proc and gets its own work order. The type parameters name types in the environment of the instance.
+	instances := make(map[string]map[string]int)
+	instanceEnvs := make(map[*frontend.ProcWorkOrder]*typing.EnvRecord)
+	instanceDescriptions := make(map[*frontend.ProcWorkOrder]string)
+	frontendFailed := make(map[string]bool)
+	instantiate := func(callerEnv *typing.EnvRecord, call ir.Inst) int {
+		extra := call.Extra.(ir.CallExtra)
+		generic := genericProcs[extra.Name]
+		args := make([]typing.TypeRecord, len(extra.TypeArgs))
+		for i, decl := range extra.TypeArgs {
+			args[i] = typer.TypeRecordFromDecl(decl)
+			if err := typer.ResolveUserTypes(callerEnv, &args[i]); err != nil {
+				panic(parsing.ErrorFromNode(call.GeneratedFrom, err.Error()))
+			}
+		}
+		key := typing.RepForListOfTypes(args, nil)
+		if instances[extra.Name] == nil {
+			instances[extra.Name] = make(map[string]int)
+		}
+		if overload, exists := instances[extra.Name][key]; exists {
+			return overload
+		}
+
+		var params []string
+		description := extra.Name + " with "
+		for i, param := range generic.ProcDecl.TypeParams {
+			params = append(params, param.Name)
+			if i > 0 {
+				description += ", "
+			}
+			description += param.Name + " = " + args[i].Rep()
+		}
+		scoped := env.WithTypeArgs(params, args)
+		var resolveErr error
+		record := buildProcRecord(typer, generic.ProcDecl, func(slot *typing.TypeRecord) {
+			if err := typer.ResolveUserTypes(scoped, slot); err != nil && resolveErr == nil {
+				resolveErr = err
+			}
+		})
+		if resolveErr != nil {
+			panic(parsing.ErrorFromNode(generic.ProcDecl, resolveErr.Error()+"\n    in "+description))
+		}
+		env.ResolveInstanceSizes()
+
+		instance := *generic
+		instance.Out = make(chan frontend.OptBlock)
+		instance.UserError = make(chan *errors.UserError)
+		instance.Overload = len(env.Procs[extra.Name])
+		env.Procs[extra.Name] = append(env.Procs[extra.Name], record)
+		instances[extra.Name][key] = instance.Overload
+		instanceEnvs[&instance] = scoped
+		instanceDescriptions[&instance] = description
+		workOrders = append(workOrders, &instance)
+		startFrontend(&instance)
+		return instance.Overload
+	}
+
 	// fmt.Printf("%#v\n", env.Types)
 	sawError := false
 	var staticData []*bytes.Buffer
-	for _, workOrder := range workOrders {
+	// instantiating generic procs adds to the work orders as we go
+	for i := 0; i < len(workOrders); i++ {
+		workOrder := workOrders[i]
 		if workOrder.ProcDecl.IsForeign {
 			fmt.Fprintf(asmOut, "extern %s\n", workOrder.Name)
 			continue
 		}
+		orderEnv := env
+		description, isInstance := instanceDescriptions[workOrder]
+		if isInstance {
+			orderEnv = instanceEnvs[workOrder]
+		}
 		select {
 		case err := <-workOrder.UserError:
 			sawError = true
+			// the frontend doesn't care about types, so every instance runs into the same problem
+			if isInstance && frontendFailed[workOrder.Name] {
+				continue
+			}
+			frontendFailed[workOrder.Name] = true
 			displayError(sourceLines, err)
 		case out := <-workOrder.Out:
-			_ = ir.Dump
-			if dumpIr {
-				ir.Dump(out.Opts)
-			}
-			if dumpEnv {
-				parsing.Dump(env)
-			}
-			procRecord := env.Procs[workOrder.Name]
-			typeTable, err := typer.InferAndCheck(env, &out, procRecord)
-			if err != nil {
-				panic(err)
-			}
-			for i, e := range typeTable {
-				if e == nil {
-					println(i)
-					panic("Bug in typer -- not all vars have types!")
+			func() {
+				if isInstance {
+					defer addInstanceContext(description)
 				}
-			}
-			static := backend.X86ForBlock(asmOut, out, typeTable, env, typer, procRecord)
-			staticData = append(staticData, static)
+				for j, opt := range out.Opts {
+					if extra, isCall := opt.Extra.(ir.CallExtra); isCall && len(extra.TypeArgs) > 0 {
+						extra.Overload = instantiate(orderEnv, opt)
+						out.Opts[j].Extra = extra
+					}
+				}
+				_ = ir.Dump
+				if dumpIr {
+					ir.Dump(out.Opts)
+				}
+				if dumpEnv {
+					parsing.Dump(env)
+				}
+				procRecord := env.Procs[workOrder.Name][workOrder.Overload]
+				typeTable, err := typer.InferAndCheck(orderEnv, &out, procRecord)
+				if err != nil {
+					panic(err)
+				}
+				for i, e := range typeTable {
+					if e == nil {
+						println(i)
+						panic("Bug in typer -- not all vars have types!")
+					}
+				}
+				static := backend.X86ForBlock(asmOut, out, typeTable, orderEnv, typer, procRecord, workOrder.Overload)
+				staticData = append(staticData, static)
+			}()
 		}
 	}
 	if sawError {
@@ -329,29 +757,41 @@ func doCompile(sourceLines []string, libc bool, asmOut io.Writer) {
 	for _, static := range staticData {
 		static.WriteTo(asmOut)
 	}
+	backend.WriteGlobals(asmOut, env, globalValues)
 }
 
 func displayError(sourceLines []string, err *errors.UserError) {
 	fmt.Fprintln(os.Stderr, err.Error())
-	line := sourceLines[err.Line]
-	lineLength := len(line)
-	if line[lineLength-1] == '\n' {
-		line = line[:lineLength]
-	}
-	fmt.Fprintln(os.Stderr, line)
-
-	for i := 0; i < lineLength; i++ {
-		if i < err.StartColumn {
-			charToPrint := " "
-			if line[i] == '\t' {
-				charToPrint = "\t"
+	endLine := err.EndLine
+	if endLine < err.Line {
+		endLine = err.Line
+	}
+	for lineNumber := err.Line; lineNumber <= endLine; lineNumber++ {
+		line := strings.TrimSuffix(sourceLines[lineNumber], "\n")
+		fmt.Fprintln(os.Stderr, line)
+
+		// lines after the first are marked from the first non-space. Lines before the last are marked to the end
+		start := err.StartColumn
+		if lineNumber != err.Line {
+			start = len(line) - len(strings.TrimLeftFunc(line, unicode.IsSpace))
+		}
+		end := err.EndColumn
+		if lineNumber != endLine {
+			end = len(line) - 1
+		}
+		for i := 0; i < len(line) && i <= end; i++ {
+			if i < start {
+				charToPrint := " "
+				if line[i] == '\t' {
+					charToPrint = "\t"
+				}
+				fmt.Fprint(os.Stderr, charToPrint)
+			} else {
+				fmt.Fprint(os.Stderr, "^")
 			}
-			fmt.Fprint(os.Stderr, charToPrint)
-		} else if i <= err.EndColumn {
-			fmt.Fprint(os.Stderr, "^")
 		}
+		fmt.Fprintln(os.Stderr)
 	}
-	fmt.Fprintln(os.Stderr)
 }
 
 func catchUserError(sourceLines []string) {
@@ -2,9 +2,12 @@ package errors
 
 import "fmt"
 
+// The error spans from StartColumn on Line to EndColumn on EndLine.
+// EndLine being before Line means the error is on a single line.
 type UserError struct {
 	Line        int
 	StartColumn int
+	EndLine     int
 	EndColumn   int
 	Message     string
 }
@@ -0,0 +1,5 @@
+LIMIT :: 10
+
+main :: proc () {
+	LIMIT = 11
+}
@@ -0,0 +1,3 @@
+main :: proc () {
+	puts("bell\q\n")
+}
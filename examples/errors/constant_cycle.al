@@ -0,0 +1,6 @@
+A :: B + 1
+B :: A * 2
+
+main :: proc () {
+	print_int(A)
+}
@@ -0,0 +1,3 @@
+main :: proc () {
+	defer x := 1
+}
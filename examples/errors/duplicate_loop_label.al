@@ -0,0 +1,7 @@
+main :: proc () {
+	outer: for {
+		outer: for {
+			break outer
+		}
+	}
+}
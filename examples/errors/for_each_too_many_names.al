@@ -0,0 +1,4 @@
+main :: proc () {
+	for a, b, c in "abc" {
+	}
+}
@@ -0,0 +1,7 @@
+max :: proc ($T type, a T, b T) -> T {
+	return a
+}
+
+main :: proc () {
+	f := &max
+}
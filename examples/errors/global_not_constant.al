@@ -0,0 +1,9 @@
+lookup := start()
+
+start :: proc () -> int {
+	return 1
+}
+
+main :: proc () {
+	print_int(lookup)
+}
@@ -0,0 +1,3 @@
+main :: proc () {
+	x := 0x1_0000_0000_0000_0000
+}
@@ -0,0 +1,5 @@
+main :: proc () {
+	for i := 0..10 step 0 {
+		print_int(i)
+	}
+}
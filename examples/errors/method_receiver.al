@@ -0,0 +1,10 @@
+struct apricot {
+	foo int
+}
+
+apricot.ripe :: proc (foo int) -> bool {
+	return foo > 3
+}
+
+main :: proc () {
+}
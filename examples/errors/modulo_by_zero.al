@@ -0,0 +1,4 @@
+main :: proc () {
+	a := 39
+	a %= 0
+}
@@ -0,0 +1,3 @@
+main :: proc () {
+	a, b := 1
+}
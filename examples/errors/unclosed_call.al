@@ -0,0 +1,6 @@
+main :: proc () {
+	x := foo(1,
+		2
+	if x {
+	}
+}
@@ -0,0 +1,5 @@
+main :: proc () {
+	for i := 0..3 {
+		break outer
+	}
+}
@@ -0,0 +1,5 @@
+main :: proc () {
+	x := 1 /* start
+	/* nested */
+	puts("hi")
+}
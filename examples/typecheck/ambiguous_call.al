@@ -0,0 +1,10 @@
+f :: proc (a u8, b int) {
+}
+
+f :: proc (a int, b u8) {
+}
+
+main :: proc () {
+	var x u8
+	f(x, x)
+}
@@ -0,0 +1,3 @@
+main :: proc () {
+	a := [2]int{1, 2, 3}
+}
@@ -1,4 +1,4 @@
 main :: proc () {
-	var foo u8
-	a := int(foo)
+	foo := "10"
+	a := u8(foo)
 }
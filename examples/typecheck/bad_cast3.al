@@ -0,0 +1,4 @@
+main :: proc () {
+	var offset s32
+	p := cast(*u8, offset)
+}
@@ -0,0 +1,4 @@
+main :: proc () {
+	a := true
+	b := a & false
+}
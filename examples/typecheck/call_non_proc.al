@@ -0,0 +1,4 @@
+main :: proc () {
+	f := 3
+	f(1)
+}
@@ -0,0 +1,9 @@
+enum Color : u8 { Red, Green }
+enum Shape : u8 { Circle, Square }
+
+main :: proc () {
+	c := Color.Red
+	if c == Shape.Circle {
+		puts("same\n")
+	}
+}
@@ -0,0 +1,6 @@
+enum Color : u8 { Red, Green }
+
+main :: proc () {
+	var c Color
+	c = 1
+}
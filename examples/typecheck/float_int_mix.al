@@ -0,0 +1,5 @@
+main :: proc () {
+	a := 1.5
+	b := 2
+	c := a + b
+}
@@ -0,0 +1,10 @@
+max :: proc ($T type, a T, b T) -> T {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+main :: proc () {
+	x := max(bool, true, false)
+}
@@ -0,0 +1,6 @@
+main :: proc () {
+	n := 5
+	for x in n {
+		print_int(x)
+	}
+}
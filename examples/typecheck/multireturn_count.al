@@ -0,0 +1,7 @@
+pair :: proc () -> (int, bool) {
+	return 1, true
+}
+
+main :: proc () {
+	a, b, c := pair()
+}
@@ -0,0 +1,7 @@
+pair :: proc () -> (int, bool) {
+	return true, 1
+}
+
+main :: proc () {
+	a, b := pair()
+}
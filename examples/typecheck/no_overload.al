@@ -0,0 +1,9 @@
+f :: proc (a int) {
+}
+
+f :: proc (a bool, b int) {
+}
+
+main :: proc () {
+	f("no")
+}
@@ -0,0 +1,5 @@
+main :: proc () {
+	a := [3]int{1, 2, 3}
+	s := a[0..2]
+	print_int(s.capacity)
+}
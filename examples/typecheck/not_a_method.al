@@ -0,0 +1,12 @@
+struct apricot {
+	foo int
+}
+
+apricot.ripe :: proc (self *apricot) -> bool {
+	return self.foo > 3
+}
+
+main :: proc () {
+	var bar apricot
+	bar.peel()
+}
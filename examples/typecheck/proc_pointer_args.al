@@ -0,0 +1,8 @@
+double :: proc (a int) -> int {
+	return a * 2
+}
+
+main :: proc () {
+	f := &double
+	f(true)
+}
@@ -0,0 +1,4 @@
+main :: proc () {
+	x := 10
+	y := x[0..2]
+}
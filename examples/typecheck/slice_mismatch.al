@@ -0,0 +1,7 @@
+takes_bytes :: proc (b []u8) {
+}
+
+main :: proc () {
+	a := [3]int{1, 2, 3}
+	takes_bytes(a[0..2])
+}
@@ -0,0 +1,8 @@
+struct point {
+	x int
+	y int
+}
+
+main :: proc () {
+	p := point{x = 1, y = 2, x = 3}
+}
@@ -0,0 +1,8 @@
+struct point {
+	x int
+	y int
+}
+
+main :: proc () {
+	p := point{1, 2, 3}
+}
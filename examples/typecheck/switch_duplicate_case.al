@@ -0,0 +1,9 @@
+main :: proc () {
+	n := 3
+	switch n {
+	case 1, 2:
+		puts("small\n")
+	case 3, 1:
+		puts("also small\n")
+	}
+}
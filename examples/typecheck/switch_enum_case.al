@@ -0,0 +1,12 @@
+enum Color : u8 { Red, Green }
+enum Shape : u8 { Circle, Square }
+
+main :: proc () {
+	c := Color.Red
+	switch c {
+	case Color.Green:
+		puts("green\n")
+	case Shape.Square:
+		puts("square\n")
+	}
+}
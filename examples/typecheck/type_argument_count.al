@@ -0,0 +1,7 @@
+struct list($T) {
+	items [4]T
+}
+
+main :: proc () {
+	var l list(int, int)
+}
@@ -0,0 +1,8 @@
+union number {
+	whole s64
+	half u32
+}
+
+main :: proc () {
+	n := number{whole = 1, half = 2}
+}
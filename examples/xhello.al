@@ -71,14 +71,39 @@ struct Screen {
 	root_input_mask s64
 }
 
-struct XEvent {
-	type s32
-	fill [188]u8
+enum XEventType : s32 {
+	KeyPress = 2,
+	KeyRelease,
+	ButtonPress,
+	ButtonRelease,
+	MotionNotify,
+	EnterNotify,
+	LeaveNotify,
+	FocusIn,
+	FocusOut,
+	KeymapNotify,
+	Expose,
+}
+
+struct XAnyEvent {
+	type XEventType
+	serial u64
+	send_event s32
+	display *XDisplay
+	window u64
+}
+
+union XEvent {
+	type XEventType
+	xany XAnyEvent
+	pad [24]s64
 }
 
 XOpenDisplay :: foreign proc (name *u8) -> *XDisplay
 
-XCreateSimpleWindow :: foreign proc (display *XDisplay, window u64, x s32, y s32, width u32, height u32, border_width u32, border u64, background u64) -> u64
+XCreateSimpleWindow :: foreign proc (display *XDisplay, window u64,
+                                     x s32, y s32, width u32, height u32,
+                                     border_width u32, border u64, background u64) -> u64
 
 XMapWindow :: foreign proc (display *XDisplay, w u64) -> s32
 
@@ -112,19 +137,19 @@ main :: proc () {
 
   	gc := screen.default_gc
 
-    w = XCreateSimpleWindow(d, rootWindow, 100, 100, 500, 500, 1, 777215, 111111)
+    w = XCreateSimpleWindow(d, rootWindow,
+                            100, 100, 500, 500,
+                            1, 777215, 111111)
     XSelectInput(d, w, 32769)
   	XMapWindow(d, w)
 
-  	Expose := 12
-  	KeyPress := 2
   	for true {
 		XNextEvent(d, &e)
-		if e.type == Expose {
+		if e.type == XEventType.Expose {
         	XFillRectangle(d, w, gc, 20, 20, 10, 10)
         	XDrawString(d, w, gc, 10, 50, msg.data, msg.length)
 		}
-		if e.type == KeyPress {
+		if e.type == XEventType.KeyPress {
 			break
 		}
   	}
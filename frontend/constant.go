@@ -0,0 +1,167 @@
+package frontend
+
+import (
+	"github.com/XrXr/alang/ir"
+	"github.com/XrXr/alang/parsing"
+)
+
+// Returns the value of a named constant. The value is int64, bool or string.
+type ConstantLookup func(name string) (interface{}, bool)
+
+// EvalConstant folds the expression on the right side of `NAME :: expr`. Integer constants are ints, so
+// the arithmetic here wraps the same way it does for s64 at runtime.
+func EvalConstant(node parsing.ASTNode, lookup ConstantLookup) interface{} {
+	switch n := node.(type) {
+	case parsing.Literal:
+		switch n.Type {
+		case parsing.Number:
+			switch value := literalValue(n).(type) {
+			case int64:
+				return value
+			case uint64:
+				return int64(value)
+			}
+		case parsing.Char:
+			return int64(n.Value[0])
+		case parsing.Boolean, parsing.String:
+			return literalValue(n)
+		}
+		panic(parsing.ErrorFromNode(n, "Constants can only be integers, bools or strings"))
+	case parsing.IdName:
+		value, found := lookup(n.Name)
+		if !found {
+			panic(parsing.ErrorFromNode(n, "Not a constant"))
+		}
+		return value
+	case parsing.ExprNode:
+		return evalConstantExpr(n, lookup)
+	}
+	panic(parsing.ErrorFromNode(node, "Not a constant expression"))
+}
+
+func evalConstantExpr(n parsing.ExprNode, lookup ConstantLookup) interface{} {
+	var left interface{}
+	if n.Left != nil {
+		left = EvalConstant(n.Left, lookup)
+	}
+	switch n.Op {
+	case parsing.BitNot:
+		right := mustBeIntConstant(n.Right, lookup)
+		return ^right
+	case parsing.LogicalNot:
+		right := mustBeBoolConstant(n.Right, lookup)
+		return !right
+	case parsing.LogicalAnd, parsing.LogicalOr:
+		l, lIsBool := left.(bool)
+		if !lIsBool {
+			panic(parsing.ErrorFromNode(n.Left, "Operands must be bools"))
+		}
+		r := mustBeBoolConstant(n.Right, lookup)
+		if n.Op == parsing.LogicalAnd {
+			return l && r
+		}
+		return l || r
+	case parsing.DoubleEqual, parsing.BangEqual:
+		right := EvalConstant(n.Right, lookup)
+		if !sameConstantKind(left, right) {
+			panic(parsing.ErrorFromNode(n, "Can't compare constants of different types"))
+		}
+		if n.Op == parsing.DoubleEqual {
+			return left == right
+		}
+		return left != right
+	}
+
+	l, lIsInt := left.(int64)
+	if !lIsInt {
+		panic(parsing.ErrorFromNode(n, "Operands must be integers"))
+	}
+	r := mustBeIntConstant(n.Right, lookup)
+	// the backend evaluates the same instructions at compile time through the same helpers
+	if how, isComparison := comparisonMethod[n.Op]; isComparison {
+		return ir.FoldCompare(how, l, r)
+	}
+	inst, isArithmetic := binaryOpInst[n.Op]
+	if !isArithmetic {
+		panic(parsing.ErrorFromNode(n, "Not a constant expression"))
+	}
+	if (inst == ir.Div || inst == ir.Mod) && r == 0 {
+		panic(parsing.ErrorFromNode(n, "Divide by zero"))
+	}
+	return ir.FoldInteger(inst, l, r, 8, false)
+}
+
+func mustBeIntConstant(node parsing.ASTNode, lookup ConstantLookup) int64 {
+	value, isInt := EvalConstant(node, lookup).(int64)
+	if !isInt {
+		panic(parsing.ErrorFromNode(node, "Operands must be integers"))
+	}
+	return value
+}
+
+func mustBeBoolConstant(node parsing.ASTNode, lookup ConstantLookup) bool {
+	value, isBool := EvalConstant(node, lookup).(bool)
+	if !isBool {
+		panic(parsing.ErrorFromNode(node, "Operands must be bools"))
+	}
+	return value
+}
+
+func sameConstantKind(a, b interface{}) bool {
+	switch a.(type) {
+	case int64:
+		_, isInt := b.(int64)
+		return isInt
+	case bool:
+		_, isBool := b.(bool)
+		return isBool
+	case string:
+		_, isString := b.(string)
+		return isString
+	}
+	return false
+}
+
+// ResolveArraySizes fills in array sizes that are constant expressions
+func ResolveArraySizes(decl parsing.TypeDecl, lookup ConstantLookup) parsing.TypeDecl {
+	if decl.ArrayBase != nil {
+		base := ResolveArraySizes(*decl.ArrayBase, lookup)
+		decl.ArrayBase = &base
+	}
+	if decl.SliceOf != nil {
+		of := ResolveArraySizes(*decl.SliceOf, lookup)
+		decl.SliceOf = &of
+	}
+	if decl.Proc != nil {
+		proc := parsing.ProcTypeDecl{Return: ResolveArraySizes(decl.Proc.Return, lookup)}
+		for _, arg := range decl.Proc.Args {
+			proc.Args = append(proc.Args, ResolveArraySizes(arg, lookup))
+		}
+		decl.Proc = &proc
+	}
+	if decl.TypeArgs != nil {
+		typeArgs := make([]parsing.TypeDecl, len(decl.TypeArgs))
+		for i, arg := range decl.TypeArgs {
+			typeArgs[i] = ResolveArraySizes(arg, lookup)
+		}
+		decl.TypeArgs = typeArgs
+	}
+	if decl.ArraySizeExprs == nil {
+		return decl
+	}
+	sizes := make([]int, len(decl.ArraySizes))
+	copy(sizes, decl.ArraySizes)
+	for i, expr := range decl.ArraySizeExprs {
+		if expr == nil {
+			continue
+		}
+		size := mustBeIntConstant(expr, lookup)
+		if size <= 0 {
+			panic(parsing.ErrorFromNode(expr, "Array size must be positive"))
+		}
+		sizes[i] = int(size)
+	}
+	decl.ArraySizes = sizes
+	decl.ArraySizeExprs = nil
+	return decl
+}
@@ -1,6 +1,7 @@
 package frontend
 
 import (
+	"fmt"
 	"github.com/XrXr/alang/ir"
 	"github.com/XrXr/alang/parsing"
 	"sort"
@@ -8,6 +9,41 @@ import (
 )
 
 const undefinedMessage = "Undefined name"
+const assignToConstantMessage = "Can't assign to a constant"
+
+// binary operators that update their left operand in place
+var binaryOpInst = map[parsing.Operator]ir.InstType{
+	parsing.Star:       ir.Mult,
+	parsing.Divide:     ir.Div,
+	parsing.Modulo:     ir.Mod,
+	parsing.Plus:       ir.Add,
+	parsing.Minus:      ir.Sub,
+	parsing.BitAnd:     ir.BitAnd,
+	parsing.BitOr:      ir.BitOr,
+	parsing.BitXor:     ir.BitXor,
+	parsing.ShiftLeft:  ir.ShiftLeft,
+	parsing.ShiftRight: ir.ShiftRight,
+}
+
+var comparisonMethod = map[parsing.Operator]ir.ComparisonMethod{
+	parsing.Greater:      ir.Greater,
+	parsing.GreaterEqual: ir.GreaterOrEqual,
+	parsing.Lesser:       ir.Lesser,
+	parsing.LesserEqual:  ir.LesserOrEqual,
+	parsing.DoubleEqual:  ir.AreEqual,
+	parsing.BangEqual:    ir.NotEqual,
+}
+
+var compoundAssignInst = map[parsing.Operator]ir.InstType{
+	parsing.PlusEqual:       ir.Add,
+	parsing.MinusEqual:      ir.Sub,
+	parsing.BitAndEqual:     ir.BitAnd,
+	parsing.BitOrEqual:      ir.BitOr,
+	parsing.BitXorEqual:     ir.BitXor,
+	parsing.ShiftLeftEqual:  ir.ShiftLeft,
+	parsing.ShiftRightEqual: ir.ShiftRight,
+	parsing.ModuloEqual:     ir.Mod,
+}
 
 func GenForProc(labelGen *LabelIdGen, order *ProcWorkOrder) {
 	var gen procGen
@@ -17,6 +53,10 @@ func GenForProc(labelGen *LabelIdGen, order *ProcWorkOrder) {
 		parentScope: nil,
 	}
 	gen.labelGen = labelGen
+	gen.globals = order.Globals
+	gen.constants = order.Constants
+	gen.enums = order.Enums
+	gen.generics = order.Generics
 
 	for i, arg := range order.ProcDecl.Args {
 		_ = i
@@ -58,11 +98,15 @@ func genForProcSubSection(labelGen *LabelIdGen, order *ProcWorkOrder, scope *sco
 		sawIfLastIter := sawIf
 		sawIf = false
 		gen.pushCurrentlyGenerating(nodePtr)
+		outerStatementLine := gen.statementLine
+		gen.statementLine = (*nodePtr).GetLineNumber()
 		after := func() {
+			gen.statementLine = outerStatementLine
 			gen.popCurrentlyGenerating(nodePtr)
 		}
 		switch node := (*nodePtr).(type) {
 		case parsing.BlockEnd:
+			genDeferredUntil(scope, scope)
 			if scope.outOfScopeMutations != nil {
 				sort.Ints(*scope.outOfScopeMutations)
 				*scope.outOfScopeMutations = DedupSorted(*scope.outOfScopeMutations)
@@ -79,14 +123,24 @@ func genForProcSubSection(labelGen *LabelIdGen, order *ProcWorkOrder, scope *sco
 		case parsing.Declaration:
 			// these are declaration without values i.e. not foo := 3
 			newVar := scope.newNamedVar(node.Name.Name)
-			scope.addOpt(ir.MakeMutateOnlyInst(ir.AssignImm, newVar, node.Type))
+			scope.addOpt(ir.MakeMutateOnlyInst(ir.AssignImm, newVar, ResolveArraySizes(node.Type, scope.resolveConstant)))
 		case parsing.ExprNode:
 			switch node.Op {
+			case parsing.ConstDeclare:
+				name := node.Left.(parsing.IdName).Name
+				_, varExists := scope.varTable[name]
+				_, constantExists := scope.constants[name]
+				if varExists || constantExists {
+					panic(parsing.ErrorFromNode(node.Left, "Redeclaration of constant"))
+				}
+				scope.newConstant(name, EvalConstant(node.Right, scope.resolveConstant))
 			case parsing.Declare:
 				varName := node.Left.(parsing.IdName).Name
-				if _, alreadyExist := scope.resolve(varName); alreadyExist {
+				_, isConstant := scope.resolveConstant(varName)
+				if _, alreadyExist := scope.resolve(varName); alreadyExist || isConstant || scope.isGlobal(varName) {
 					_, existsInCurrentScope := scope.varTable[varName]
-					if existsInCurrentScope {
+					_, constantInCurrentScope := scope.constants[varName]
+					if existsInCurrentScope || constantInCurrentScope {
 						panic(parsing.ErrorFromNode(node.Left, "Redeclaration of variable"))
 					}
 					// we need to generate the right side before we make the variable in the current scope since
@@ -98,44 +152,18 @@ func genForProcSubSection(labelGen *LabelIdGen, order *ProcWorkOrder, scope *sco
 					varNum := scope.newNamedVar(varName)
 					genExpressionValueToVar(scope, varNum, node.Right)
 				}
-			case parsing.Assign, parsing.PlusEqual, parsing.MinusEqual:
-				leftAsIdent, leftIsIdent := node.Left.(parsing.IdName)
-				if leftIdent := leftAsIdent.Name; leftIsIdent {
-					leftVarNum, varFound := scope.resolve(leftIdent)
-					if !varFound {
-						panic(parsing.ErrorFromNode(node.Left, undefinedMessage))
-					}
-					rightResult := genExpressionValue(scope, node.Right)
-					switch node.Op {
-					case parsing.PlusEqual:
-						scope.addOpt(ir.MakeBinaryInst(ir.Add, leftVarNum, rightResult, nil))
-					case parsing.MinusEqual:
-						scope.addOpt(ir.MakeBinaryInst(ir.Sub, leftVarNum, rightResult, nil))
-					default:
-						scope.addOpt(ir.MakeBinaryInst(ir.Assign, leftVarNum, rightResult, nil))
-					}
-				} else {
-					assignmentPtr := genAssignmentTarget(scope, node.Left)
-					rightResult := genExpressionValue(scope, node.Right)
-					var leftTmp int
-					if node.Op == parsing.PlusEqual || node.Op == parsing.MinusEqual {
-						leftTmp = scope.newVar()
-						scope.addOpt(ir.MakeBinaryInst(ir.IndirectLoad, leftTmp, assignmentPtr, nil))
-					}
-					switch node.Op {
-					case parsing.PlusEqual:
-						scope.addOpt(ir.MakeBinaryInst(ir.Add, leftTmp, rightResult, nil))
-						scope.addOpt(ir.MakeBinaryInst(ir.IndirectWrite, assignmentPtr, leftTmp, nil))
-					case parsing.MinusEqual:
-						scope.addOpt(ir.MakeBinaryInst(ir.Sub, leftTmp, rightResult, nil))
-						scope.addOpt(ir.MakeBinaryInst(ir.IndirectWrite, assignmentPtr, leftTmp, nil))
-					default:
-						scope.addOpt(ir.MakeBinaryInst(ir.IndirectWrite, assignmentPtr, rightResult, nil))
-					}
+			case parsing.Assign, parsing.PlusEqual, parsing.MinusEqual, parsing.BitAndEqual, parsing.BitOrEqual,
+				parsing.BitXorEqual, parsing.ShiftLeftEqual, parsing.ShiftRightEqual, parsing.ModuloEqual:
+				genAssignment(scope, node)
+			case parsing.Dot:
+				if isMethodCall(node) {
+					genExpressionValue(scope, node)
 				}
 			default:
 				//TODO issue warning here
 			}
+		case parsing.MultiAssign:
+			genMultiAssign(scope, node)
 		case parsing.IfNode:
 			sawIf = true
 			optionSelectAllOutOfScopeMutations = &[]int{}
@@ -171,18 +199,33 @@ func genForProcSubSection(labelGen *LabelIdGen, order *ProcWorkOrder, scope *sco
 			scope.addOpt(ir.Inst{Type: ir.OptionEnd, Extra: mutations})
 			finishOptionSelect()
 			scope.addOpt(labelInst(endOfTree))
+		case parsing.SwitchNode:
+			i = genSwitch(labelGen, order, scope, node, i)
 		case parsing.Loop:
 			outsideLoopMutations := &[]int{}
 			loopStart := labelGen.GenLabel("loop_%d")
 			loopEnd := loopStart + "_loopEnd"
 
-			var iterationVar, endVar int
+			var iterationVar, endVar, stepVar, collection int
 			usingRangeExpr := false
 			counterIsNamed := false
 			var counterVarName string
 			var rangeExpr parsing.ExprNode
+			step := int64(1)
+			forEach := node.Element.Name != ""
 
-			if loopExpr, loopExprIsExprNode := node.Expression.(parsing.ExprNode); loopExprIsExprNode {
+			if forEach {
+				// goes over the indices like 0..<collection.length would. The elements are found through .data
+				usingRangeExpr = true
+				rangeExpr.Op = parsing.RangeExclusive
+				collection = computePointerRecursive(scope, node.Expression)
+				gen.pushCurrentlyGenerating(&node.Expression)
+				endVar = genForEachMemberLoad(scope, collection, "length")
+				gen.popCurrentlyGenerating(&node.Expression)
+				iterationVar = scope.newVar()
+				scope.addOpt(ir.MakeMutateOnlyInst(ir.AssignImm, iterationVar, int64(0)))
+				gen.nonTemporaryVars = append(gen.nonTemporaryVars, collection)
+			} else if loopExpr, loopExprIsExprNode := node.Expression.(parsing.ExprNode); loopExprIsExprNode {
 				switch loopExpr.Op {
 				case parsing.Declare:
 					usingRangeExpr = true
@@ -191,23 +234,34 @@ func genForProcSubSection(labelGen *LabelIdGen, order *ProcWorkOrder, scope *sco
 					counterIsNamed = true
 					counterVarName = loopExpr.Left.(parsing.IdName).Name
 					fallthrough
-				case parsing.Range:
+				case parsing.Range, parsing.RangeExclusive:
 					if !usingRangeExpr {
 						rangeExpr = loopExpr
 					}
 					usingRangeExpr = true
-					if rangeExpr.Op != parsing.Range {
+					if rangeExpr.Op != parsing.Range && rangeExpr.Op != parsing.RangeExclusive {
 						panic("parser bug")
 					}
 					iterationVar = scope.newVar()
 					endVar = genExpressionValue(scope, rangeExpr.Right)
 					genExpressionValueToVar(scope, iterationVar, rangeExpr.Left)
-
+					if node.Step != nil {
+						step = loopStep(scope, node.Step)
+						stepVar = scope.newVar()
+						scope.addOpt(ir.MakeMutateOnlyInst(ir.AssignImm, stepVar, step))
+					}
 				}
 			}
+			if node.Step != nil && (!usingRangeExpr || forEach) {
+				panic(parsing.ErrorFromNode(node.Step, "Only ranges can have a step"))
+			}
+			if label := node.Label.Name; label != "" && scope.namedLoopBody(label) != nil {
+				panic(parsing.ErrorFromNode(node.Label, "Loop label already in use"))
+			}
 			// loop body
 			scope := scope.inherit()
 			scope.loopLabel = loopStart
+			scope.loopName = node.Label.Name
 			scope.outOfScopeMutations = outsideLoopMutations
 			scope.addOpt(ir.Inst{Type: ir.OutsideLoopMutations, Extra: outsideLoopMutations})
 			scope.addOpt(labelInst(loopStart))
@@ -217,14 +271,28 @@ func genForProcSubSection(labelGen *LabelIdGen, order *ProcWorkOrder, scope *sco
 				condVar := scope.newVar()
 				scope.addOpt(ir.MakeReadOnlyInst(ir.Compare, iterationVar,
 					ir.CompareExtra{
-						How:   ir.LesserOrEqual,
+						How:   rangeLoopCondition(rangeExpr.Op, step),
 						Right: endVar,
 						Out:   condVar,
 					}))
 				scope.addOpt(ir.MakeReadOnlyInst(ir.JumpIfFalse, condVar, loopEnd))
 				if counterIsNamed {
-					namedCounterVn := scope.newNamedVar(counterVarName)
-					scope.addOpt(ir.MakeBinaryInst(ir.Assign, namedCounterVn, iterationVar, nil))
+					// the counter is the iteration var itself so the body can change where the loop goes next
+					scope.varTable[counterVarName] = iterationVar
+				}
+				if forEach {
+					elementPointer := genForEachMemberLoad(scope, collection, "data")
+					scope.addOpt(ir.MakeBinaryInst(ir.Add, elementPointer, iterationVar, nil))
+					if node.Index.Name != "" {
+						index := scope.newNamedVar(node.Index.Name)
+						scope.addOpt(ir.MakeBinaryInst(ir.Assign, index, iterationVar, nil))
+					}
+					element := scope.newNamedVar(node.Element.Name)
+					if node.ByPointer {
+						scope.addOpt(ir.MakeBinaryInst(ir.Assign, element, elementPointer, nil))
+					} else {
+						scope.addOpt(ir.MakeBinaryInst(ir.IndirectLoad, element, elementPointer, nil))
+					}
 				}
 			} else {
 				if node.Expression != nil {
@@ -238,7 +306,12 @@ func genForProcSubSection(labelGen *LabelIdGen, order *ProcWorkOrder, scope *sco
 			// continue code
 			scope.addOpt(labelInst(loopStart + "_loopContinue"))
 			if usingRangeExpr {
-				scope.addOpt(ir.MakeMutateOnlyInst(ir.Increment, iterationVar, nil))
+				if node.Step == nil {
+					scope.addOpt(ir.MakeMutateOnlyInst(ir.Increment, iterationVar, nil))
+				} else {
+					scope.addOpt(ir.MakeBinaryInst(ir.Add, iterationVar, stepVar, nil))
+					gen.nonTemporaryVars = append(gen.nonTemporaryVars, stepVar)
+				}
 				scope.addOpt(ir.MakePlainInst(ir.Jump, loopStart))
 				gen.nonTemporaryVars = append(gen.nonTemporaryVars, iterationVar, endVar)
 			} else {
@@ -248,22 +321,31 @@ func genForProcSubSection(labelGen *LabelIdGen, order *ProcWorkOrder, scope *sco
 			scope.addOpt(ir.MakePlainInst(ir.LoopEnd, nil))
 			scope.addOpt(labelInst(loopEnd))
 		case parsing.ContinueNode:
-			if scope.loopLabel == "" {
-				panic(parsing.ErrorFromNode(node, "Use of continue outside of a loop"))
-			}
-			scope.addOpt(ir.MakePlainInst(ir.Jump, scope.loopLabel+"_loopContinue"))
+			target := jumpTarget(scope, node, node.Label, "continue")
+			genDeferredUntil(scope, target)
+			scope.addOpt(ir.MakePlainInst(ir.Jump, target.loopLabel+"_loopContinue"))
 		case parsing.BreakNode:
-			if scope.loopLabel == "" {
-				panic(parsing.ErrorFromNode(node, "Use of break outside of a loop"))
-			}
-			scope.addOpt(ir.MakePlainInst(ir.Jump, scope.loopLabel+"_loopEnd"))
+			target := jumpTarget(scope, node, node.Label, "break")
+			genDeferredUntil(scope, target)
+			scope.addOpt(ir.MakePlainInst(ir.Jump, target.loopLabel+"_loopEnd"))
 		case parsing.ReturnNode:
 			var returnValues []int
 			for _, valueExpr := range node.Values {
 				retVar := genExpressionValue(scope, valueExpr)
 				returnValues = append(returnValues, retVar)
 			}
+			if scope.hasDeferred() {
+				// the values are computed before the deferred statements get to change them
+				for i, retVar := range returnValues {
+					copied := scope.newVar()
+					scope.addOpt(ir.MakeBinaryInst(ir.Assign, copied, retVar, nil))
+					returnValues[i] = copied
+				}
+				genDeferredUntil(scope, nil)
+			}
 			scope.addOpt(ir.Inst{Type: ir.Return, Extra: ir.ReturnExtra{returnValues}})
+		case parsing.DeferNode:
+			scope.deferred = append(scope.deferred, deferredStatement{node.Statement, scope.snapshot()})
 		default:
 			genExpressionValue(scope, node)
 		}
@@ -273,6 +355,130 @@ func genForProcSubSection(labelGen *LabelIdGen, order *ProcWorkOrder, scope *sco
 	return -1
 }
 
+// The values of the call go to temporaries first so no names change before all the targets are evaluated
+func genMultiAssign(scope *scope, node parsing.MultiAssign) {
+	var extra ir.CallExtra
+	if call, isCall := node.Value.(parsing.ProcCall); isCall {
+		extra = genCallExtra(scope, call)
+	} else if isMethodCall(node.Value) {
+		extra = genMethodCallExtra(scope, node.Value)
+	} else {
+		panic(parsing.ErrorFromNode(node.Value, "Only a call can give more than one value"))
+	}
+	values := make([]int, len(node.Targets))
+	for i := range values {
+		values[i] = scope.newVar()
+	}
+	extra.ReturnTo = values[1:]
+	scope.addOpt(ir.MakeMutateOnlyInst(ir.Call, values[0], extra))
+
+	for i, target := range node.Targets {
+		if node.Op == parsing.Declare {
+			name := target.(parsing.IdName)
+			_, existsInCurrentScope := scope.varTable[name.Name]
+			_, constantInCurrentScope := scope.constants[name.Name]
+			if existsInCurrentScope || constantInCurrentScope {
+				panic(parsing.ErrorFromNode(name, "Redeclaration of variable"))
+			}
+			newVar := scope.newNamedVar(name.Name)
+			scope.addOpt(ir.MakeBinaryInst(ir.Assign, newVar, values[i], nil))
+			continue
+		}
+		if ident, isIdent := target.(parsing.IdName); isIdent && !scope.isGlobal(ident.Name) {
+			if _, isConstant := scope.resolveConstant(ident.Name); isConstant {
+				panic(parsing.ErrorFromNode(ident, assignToConstantMessage))
+			}
+			vn, found := scope.resolve(ident.Name)
+			if !found {
+				panic(parsing.ErrorFromNode(ident, undefinedMessage))
+			}
+			scope.addOpt(ir.MakeBinaryInst(ir.Assign, vn, values[i], nil))
+			continue
+		}
+		assignmentPtr := genAssignmentTarget(scope, target)
+		scope.addOpt(ir.MakeBinaryInst(ir.IndirectWrite, assignmentPtr, values[i], nil))
+	}
+}
+
+// Like if/else, exactly one of the cases run so all the cases make up one option select.
+// There is always a default label for ir.Switch to go to, even if the source doesn't have a default case.
+func genSwitch(labelGen *LabelIdGen, order *ProcWorkOrder, scope *scope, node parsing.SwitchNode, i int) int {
+	gen := scope.gen
+	allOutOfScopeMutations := &[]int{}
+	endLabel := labelGen.GenLabel("switch_end_%d")
+	scope.addOpt(ir.Inst{Type: ir.OptionSelectStart, Extra: allOutOfScopeMutations})
+	valueVar := genExpressionValue(scope, node.Value)
+	switchIdx := len(gen.opts)
+	// extra is filled in once we've seen all the cases
+	scope.addOpt(ir.MakeReadOnlyInst(ir.Switch, valueVar, nil))
+
+	var extra ir.SwitchExtra
+	sawCase := false
+	for {
+		caseNode, isCase := (*order.In[i]).(parsing.CaseNode)
+		if !isCase {
+			break
+		}
+		i++
+		label := labelGen.GenLabel("case_%d")
+		if caseNode.IsDefault {
+			if extra.Default != "" {
+				panic(parsing.ErrorFromNode(caseNode, "Multiple defaults in switch"))
+			}
+			extra.Default = label
+		} else {
+			switchCase := ir.SwitchCase{Label: label}
+			for _, valueNode := range caseNode.Values {
+				switchCase.Values = append(switchCase.Values, evalCaseValue(scope, valueNode))
+			}
+			extra.Cases = append(extra.Cases, switchCase)
+		}
+		if sawCase {
+			scope.addOpt(ir.MakePlainInst(ir.Jump, endLabel))
+		}
+		sawCase = true
+
+		mutations := &[]int{}
+		caseScope := scope.inherit()
+		caseScope.outOfScopeMutations = mutations
+		caseScope.addOpt(labelInst(label))
+		i = genForProcSubSection(labelGen, order, caseScope, i)
+		caseScope.addOpt(ir.Inst{Type: ir.OptionEnd, Extra: mutations})
+		*allOutOfScopeMutations = append(*allOutOfScopeMutations, *mutations...)
+	}
+	if _, isEnd := (*order.In[i]).(parsing.BlockEnd); !isEnd {
+		panic("ice: switch should end with a BlockEnd. Should've been caught by the parser")
+	}
+	i++
+
+	if extra.Default == "" {
+		extra.Default = labelGen.GenLabel("switch_no_default_%d")
+		if sawCase {
+			scope.addOpt(ir.MakePlainInst(ir.Jump, endLabel))
+		}
+		scope.addOpt(labelInst(extra.Default))
+		scope.addOpt(ir.Inst{Type: ir.OptionEnd, Extra: &[]int{}})
+	}
+	sort.Ints(*allOutOfScopeMutations)
+	*allOutOfScopeMutations = DedupSorted(*allOutOfScopeMutations)
+	scope.addOpt(ir.Inst{Type: ir.OptionSelectEnd})
+	scope.addOpt(labelInst(endLabel))
+	gen.opts[switchIdx].Extra = extra
+	return i
+}
+
+// case values have to be known at compile time so the backend can lay out the switch
+func evalCaseValue(scope *scope, node parsing.ASTNode) ir.CaseValue {
+	if enumName, value, isEnumMember := resolveEnumMember(scope, node); isEnumMember {
+		return ir.CaseValue{Value: value, Enum: enumName, From: node}
+	}
+	value, isInt := EvalConstant(node, scope.resolveConstant).(int64)
+	if !isInt {
+		panic(parsing.ErrorFromNode(node, "Case values must be integers or enum members"))
+	}
+	return ir.CaseValue{Value: value, From: node}
+}
+
 func genAndOr(scope *scope, node parsing.ASTNode, op parsing.Operator, condJumpInst, combineInst ir.InstType, endLabel string, destVn int) {
 	expr, nodeIsExpr := node.(parsing.ExprNode)
 	if !nodeIsExpr || expr.Op != op {
@@ -291,6 +497,12 @@ func genExpressionValue(scope *scope, node parsing.ASTNode) int {
 	switch n := node.(type) {
 	case parsing.IdName:
 		vn, found := scope.resolve(n.Name)
+		_, isConstant := scope.resolveConstant(n.Name)
+		if !found && (isConstant || scope.isGlobal(n.Name)) {
+			vn = scope.newVar()
+			genExpressionValueToVar(scope, vn, node)
+			return vn
+		}
 		if !found {
 			panic(parsing.ErrorFromNode(n, undefinedMessage))
 		}
@@ -310,38 +522,49 @@ func genExpressionValueToVar(scope *scope, dest int, node parsing.ASTNode) {
 	defer gen.popCurrentlyGenerating(&node)
 	switch n := node.(type) {
 	case parsing.IdName:
+		if value, isConstant := scope.resolveConstant(n.Name); isConstant {
+			scope.addOpt(ir.MakeMutateOnlyInst(ir.AssignImm, dest, value))
+			break
+		}
+		if scope.isGlobal(n.Name) {
+			address := genGlobalAddress(scope, n)
+			scope.addOpt(ir.MakeBinaryInst(ir.IndirectLoad, dest, address, nil))
+			break
+		}
 		vn, found := scope.resolve(n.Name)
 		if !found {
 			panic(parsing.ErrorFromNode(n, undefinedMessage))
 		}
 		scope.addOpt(ir.MakeBinaryInst(ir.Assign, dest, vn, nil))
 	case parsing.Literal:
-		var value interface{}
-		switch n.Type {
-		case parsing.Number:
-			v, err := strconv.ParseInt(n.Value, 10, 64)
-			if err == nil {
-				value = v
-			} else {
-				value, err = strconv.ParseUint(n.Value, 10, 64)
-			}
-		case parsing.Boolean:
-			value = boolStrToBool(n.Value)
-		case parsing.String:
-			value = n.Value
-		case parsing.NilPtr:
-			value = parsing.NilPtr
-		}
-		scope.addOpt(ir.MakeMutateOnlyInst(ir.AssignImm, dest, value))
+		scope.addOpt(ir.MakeMutateOnlyInst(ir.AssignImm, dest, literalValue(n)))
 	case parsing.ProcCall:
-		var argVars []int
-		for _, argNode := range n.Args {
-			argEval := genExpressionValue(scope, argNode)
-			argVars = append(argVars, argEval)
-		}
-		scope.addOpt(ir.MakeMutateOnlyInst(ir.Call, dest, ir.CallExtra{
-			Name:    n.Callee.Name,
-			ArgVars: argVars,
+		scope.addOpt(ir.MakeMutateOnlyInst(ir.Call, dest, genCallExtra(scope, n)))
+	case parsing.Cast:
+		value := genExpressionValue(scope, n.Value)
+		scope.addOpt(ir.MakeBinaryInst(ir.Cast, dest, value, ResolveArraySizes(n.Type, scope.resolveConstant)))
+	case parsing.StructLiteral:
+		var names []string
+		for _, name := range n.Names {
+			names = append(names, name.Name)
+		}
+		var values []int
+		for _, valueNode := range n.Values {
+			values = append(values, genExpressionValue(scope, valueNode))
+		}
+		scope.addOpt(ir.MakeMutateOnlyInst(ir.StructLiteral, dest, ir.StructLiteralExtra{
+			Type:   n.Type.Name,
+			Names:  names,
+			Values: values,
+		}))
+	case parsing.ArrayLiteral:
+		var values []int
+		for _, valueNode := range n.Values {
+			values = append(values, genExpressionValue(scope, valueNode))
+		}
+		scope.addOpt(ir.MakeMutateOnlyInst(ir.ArrayLiteral, dest, ir.ArrayLiteralExtra{
+			Type:   ResolveArraySizes(n.Type, scope.resolveConstant),
+			Values: values,
 		}))
 	case parsing.ExprNode:
 		switch n.Op {
@@ -357,6 +580,12 @@ func genExpressionValueToVar(scope *scope, dest int, node parsing.ASTNode) {
 			}
 			rightDest := genExpressionValue(scope, n.Right)
 			scope.addOpt(ir.MakeBinaryInst(ir.Not, dest, rightDest, nil))
+		case parsing.BitNot:
+			if n.Left != nil {
+				panic("parser bug")
+			}
+			rightDest := genExpressionValue(scope, n.Right)
+			scope.addOpt(ir.MakeBinaryInst(ir.BitNot, dest, rightDest, nil))
 		case parsing.LogicalAnd:
 			end := labelGen.GenLabel("andEnd_%d")
 			genAndOr(scope, n, n.Op, ir.ShortJumpIfFalse, ir.And, end, dest)
@@ -365,44 +594,38 @@ func genExpressionValueToVar(scope *scope, dest int, node parsing.ASTNode) {
 			end := labelGen.GenLabel("orEnd_%d")
 			genAndOr(scope, n, n.Op, ir.ShortJumpIfTrue, ir.Or, end, dest)
 			scope.addOpt(labelInst(end))
-		case parsing.Star, parsing.Minus, parsing.Plus, parsing.Divide,
+		case parsing.Star, parsing.Minus, parsing.Plus, parsing.Divide, parsing.Modulo,
+			parsing.BitAnd, parsing.BitOr, parsing.BitXor, parsing.ShiftLeft, parsing.ShiftRight,
 			parsing.Greater, parsing.GreaterEqual, parsing.Lesser,
 			parsing.LesserEqual, parsing.DoubleEqual, parsing.BangEqual:
 			leftDest := scope.newVar()
 			genExpressionValueToVar(scope, leftDest, n.Left)
 			rightDest := genExpressionValue(scope, n.Right)
-			switch n.Op {
-			case parsing.Star:
-				scope.addOpt(ir.MakeBinaryInst(ir.Mult, leftDest, rightDest, nil))
-				scope.addOpt(ir.MakeBinaryInst(ir.Assign, dest, leftDest, nil))
-			case parsing.Divide:
-				scope.addOpt(ir.MakeBinaryInst(ir.Div, leftDest, rightDest, nil))
+			if inst, isArithmetic := binaryOpInst[n.Op]; isArithmetic {
+				scope.addOpt(ir.MakeBinaryInst(inst, leftDest, rightDest, nil))
 				scope.addOpt(ir.MakeBinaryInst(ir.Assign, dest, leftDest, nil))
-			case parsing.Plus:
-				scope.addOpt(ir.MakeBinaryInst(ir.Add, leftDest, rightDest, nil))
-				scope.addOpt(ir.MakeBinaryInst(ir.Assign, dest, leftDest, nil))
-			case parsing.Minus:
-				scope.addOpt(ir.MakeBinaryInst(ir.Sub, leftDest, rightDest, nil))
-				scope.addOpt(ir.MakeBinaryInst(ir.Assign, dest, leftDest, nil))
-			case parsing.Greater:
-				scope.addOpt(ir.MakeReadOnlyInst(ir.Compare, leftDest, ir.CompareExtra{How: ir.Greater, Right: rightDest, Out: dest}))
-			case parsing.GreaterEqual:
-				scope.addOpt(ir.MakeReadOnlyInst(ir.Compare, leftDest, ir.CompareExtra{How: ir.GreaterOrEqual, Right: rightDest, Out: dest}))
-			case parsing.Lesser:
-				scope.addOpt(ir.MakeReadOnlyInst(ir.Compare, leftDest, ir.CompareExtra{How: ir.Lesser, Right: rightDest, Out: dest}))
-			case parsing.LesserEqual:
-				scope.addOpt(ir.MakeReadOnlyInst(ir.Compare, leftDest, ir.CompareExtra{How: ir.LesserOrEqual, Right: rightDest, Out: dest}))
-			case parsing.DoubleEqual:
-				scope.addOpt(ir.MakeReadOnlyInst(ir.Compare, leftDest, ir.CompareExtra{How: ir.AreEqual, Right: rightDest, Out: dest}))
-			case parsing.BangEqual:
-				scope.addOpt(ir.MakeReadOnlyInst(ir.Compare, leftDest, ir.CompareExtra{How: ir.NotEqual, Right: rightDest, Out: dest}))
+				break
 			}
+			how := comparisonMethod[n.Op]
+			scope.addOpt(ir.MakeReadOnlyInst(ir.Compare, leftDest, ir.CompareExtra{How: how, Right: rightDest, Out: dest}))
 		case parsing.AddressOf:
 			switch right := n.Right.(type) {
 			case parsing.IdName:
+				if scope.isGlobal(right.Name) {
+					scope.addOpt(ir.MakeMutateOnlyInst(ir.GlobalAddress, dest, right.Name))
+					break
+				}
+				if _, isConstant := scope.resolveConstant(right.Name); isConstant {
+					panic(parsing.ErrorFromNode(n.Right, "Can't take the address of a constant"))
+				}
 				vn, found := scope.resolve(right.Name)
+				if _, isGeneric := gen.generics[right.Name]; isGeneric && !found {
+					panic(parsing.ErrorFromNode(n.Right, "Can't take the address of a generic proc"))
+				}
 				if !found {
-					panic(parsing.ErrorFromNode(n.Right, undefinedMessage))
+					// the typer knows whether this names a proc
+					scope.addOpt(ir.MakeMutateOnlyInst(ir.ProcAddress, dest, right.Name))
+					break
 				}
 				scope.addOpt(ir.MakeBinaryInst(ir.TakeAddress, dest, vn, nil))
 			default:
@@ -413,6 +636,21 @@ func genExpressionValueToVar(scope *scope, dest int, node parsing.ASTNode) {
 				scope.addOpt(ir.MakeBinaryInst(ir.Assign, dest, address, nil))
 			}
 		case parsing.ArrayAccess, parsing.Dot:
+			if isMethodCall(n) {
+				scope.addOpt(ir.MakeMutateOnlyInst(ir.Call, dest, genMethodCallExtra(scope, n)))
+				break
+			}
+			if genEnumMember(scope, dest, n) {
+				break
+			}
+			if bounds, isSlicing := sliceBounds(n); isSlicing {
+				base := computePointerRecursive(scope, n.Left)
+				low := genExpressionValue(scope, bounds.Left)
+				high := genExpressionValue(scope, bounds.Right)
+				exclusive := bounds.Op == parsing.RangeExclusive
+				scope.addOpt(ir.MakeBinaryInst(ir.TakeSlice, dest, base, ir.TakeSliceExtra{Low: low, High: high, Exclusive: exclusive}))
+				break
+			}
 			location := computePointer(scope, n)
 			scope.addOpt(ir.MakeBinaryInst(ir.IndirectLoad, dest, location, nil))
 		default:
@@ -433,6 +671,9 @@ func computePointer(scope *scope, node parsing.ASTNode) int {
 		case parsing.ArrayAccess:
 			return computePointerRecursive(scope, node)
 		case parsing.Dot:
+			if isMethodCall(n) {
+				panic(parsing.ErrorFromNode(node, "The value of a call has no address"))
+			}
 			left := computePointerRecursive(scope, n.Left)
 			result := scope.newVar()
 			fieldName := n.Right.(parsing.IdName).Name
@@ -444,6 +685,15 @@ func computePointer(scope *scope, node parsing.ASTNode) int {
 	return 0
 }
 
+// the data or the length of what a for-each loop goes over. base is what computePointerRecursive gives
+func genForEachMemberLoad(scope *scope, base int, member string) int {
+	memberPointer := scope.newVar()
+	scope.addOpt(ir.MakeBinaryInst(ir.StructMemberPtr, memberPointer, base, ir.ForEachMemberExtra{Name: member}))
+	value := scope.newVar()
+	scope.addOpt(ir.MakeBinaryInst(ir.IndirectLoad, value, memberPointer, nil))
+	return value
+}
+
 func computePointerRecursive(scope *scope, node parsing.ASTNode) int {
 	gen := scope.gen
 	gen.pushCurrentlyGenerating(&node)
@@ -452,23 +702,288 @@ func computePointerRecursive(scope *scope, node parsing.ASTNode) int {
 	case parsing.ExprNode:
 		switch n.Op {
 		case parsing.ArrayAccess:
+			if _, isSlicing := sliceBounds(n); isSlicing {
+				return genExpressionValue(scope, node)
+			}
 			left := computePointerRecursive(scope, n.Left)
 			position := genExpressionValue(scope, n.Right)
+			scope.addOpt(ir.MakeReadOnlyInst(ir.BoundsCheck, position, ir.BoundsCheckExtra{Of: left}))
 			arrayPointer := scope.newVar()
 			scope.addOpt(ir.MakeBinaryInst(ir.ArrayToPointer, arrayPointer, left, nil))
 			scope.addOpt(ir.MakeBinaryInst(ir.Add, arrayPointer, position, nil))
 			return arrayPointer
 		case parsing.Dot:
+			if isMethodCall(n) {
+				return genExpressionValue(scope, node)
+			}
 			left := computePointerRecursive(scope, n.Left)
 			result := scope.newVar()
 			fieldName := n.Right.(parsing.IdName).Name
 			scope.addOpt(ir.MakeBinaryInst(ir.PeelStruct, result, left, fieldName))
 			return result
 		}
+	case parsing.IdName:
+		// use the global's storage directly instead of a copy. The typer loads the global instead when
+		// it's a pointer, a slice or a string, the same way using a local of those types would.
+		if scope.isGlobal(n.Name) {
+			base := scope.newVar()
+			scope.addOpt(ir.MakeBinaryInst(ir.GlobalBase, base, genGlobalAddress(scope, n), nil))
+			return base
+		}
 	}
 	return genExpressionValue(scope, node)
 }
 
+// The body of the loop a break or continue leaves. The label is empty for the innermost loop.
+func jumpTarget(scope *scope, node parsing.ASTNode, label parsing.IdName, keyword string) *scope {
+	if scope.loopLabel == "" {
+		panic(parsing.ErrorFromNode(node, "Use of "+keyword+" outside of a loop"))
+	}
+	if label.Name == "" {
+		return scope.loopBody()
+	}
+	body := scope.namedLoopBody(label.Name)
+	if body == nil {
+		panic(parsing.ErrorFromNode(label, "Unknown loop label"))
+	}
+	return body
+}
+
+// The step of a range loop has to be known at compile time so we know which way the loop goes
+func loopStep(scope *scope, node parsing.ASTNode) int64 {
+	step, isInt := EvalConstant(node, scope.resolveConstant).(int64)
+	if !isInt {
+		panic(parsing.ErrorFromNode(node, "Loop step must be an integer"))
+	}
+	if step == 0 {
+		panic(parsing.ErrorFromNode(node, "Loop step can't be zero"))
+	}
+	return step
+}
+
+// a..b includes b while a..<b stops before it. Loops with a negative step count down to the end.
+func rangeLoopCondition(op parsing.Operator, step int64) ir.ComparisonMethod {
+	switch {
+	case step > 0 && op == parsing.Range:
+		return ir.LesserOrEqual
+	case step > 0:
+		return ir.Lesser
+	case op == parsing.Range:
+		return ir.GreaterOrEqual
+	}
+	return ir.Greater
+}
+
+// thing[low..high] and thing[low..<high] make a slice instead of indexing
+func sliceBounds(n parsing.ExprNode) (parsing.ExprNode, bool) {
+	if n.Op != parsing.ArrayAccess {
+		return parsing.ExprNode{}, false
+	}
+	bounds, isExpr := n.Right.(parsing.ExprNode)
+	return bounds, isExpr && (bounds.Op == parsing.Range || bounds.Op == parsing.RangeExclusive)
+}
+
+// find the value of Enum.Member. Returns false when node is not of that form
+func resolveEnumMember(scope *scope, node parsing.ASTNode) (string, int64, bool) {
+	expr, isExpr := node.(parsing.ExprNode)
+	if !isExpr || expr.Op != parsing.Dot {
+		return "", 0, false
+	}
+	enumName, leftIsIdent := expr.Left.(parsing.IdName)
+	if !leftIsIdent {
+		return "", 0, false
+	}
+	members, isEnum := scope.resolveEnum(enumName.Name)
+	if !isEnum {
+		return "", 0, false
+	}
+	memberName := expr.Right.(parsing.IdName)
+	value, isMember := members[memberName.Name]
+	if !isMember {
+		panic(parsing.ErrorFromNode(memberName, "Not a member of enum "+enumName.Name))
+	}
+	return enumName.Name, value, true
+}
+
+// Enum.Member is the member's value casted to the enum. Returns false when node is not an enum member
+func genEnumMember(scope *scope, dest int, node parsing.ExprNode) bool {
+	enumName, value, isEnumMember := resolveEnumMember(scope, node)
+	if !isEnumMember {
+		return false
+	}
+	valueVar := scope.newVar()
+	scope.addOpt(ir.MakeMutateOnlyInst(ir.AssignImm, valueVar, value))
+	scope.addOpt(ir.MakeMutateOnlyInst(ir.Call, dest, ir.CallExtra{
+		Name:    enumName,
+		ArgVars: []int{valueVar},
+	}))
+	return true
+}
+
+// Calling a name that is a variable goes through the proc pointer in it, and so does calling the value
+// of an expression. The first arguments to a generic proc are types.
+func genCallExtra(scope *scope, call parsing.ProcCall) ir.CallExtra {
+	if call.Target != nil {
+		extra := ir.CallExtra{Indirect: true}
+		for _, argNode := range call.Args {
+			extra.ArgVars = append(extra.ArgVars, genExpressionValue(scope, argNode))
+		}
+		extra.ProcVar = genExpressionValue(scope, call.Target)
+		return extra
+	}
+	extra := ir.CallExtra{Name: call.Callee.Name}
+	_, isVar := scope.resolve(call.Callee.Name)
+	isVar = isVar || scope.isGlobal(call.Callee.Name)
+	args := call.Args
+	if numTypeParams, isGeneric := scope.gen.generics[call.Callee.Name]; isGeneric && !isVar {
+		if len(args) < numTypeParams {
+			panic(parsing.ErrorFromNode(call, fmt.Sprintf("%s needs %d type arguments", call.Callee.Name, numTypeParams)))
+		}
+		for _, argNode := range args[:numTypeParams] {
+			extra.TypeArgs = append(extra.TypeArgs, ResolveArraySizes(typeDeclFromExpr(argNode), scope.resolveConstant))
+		}
+		args = args[numTypeParams:]
+	}
+	for _, argNode := range args {
+		extra.ArgVars = append(extra.ArgVars, genExpressionValue(scope, argNode))
+	}
+	if isVar {
+		extra.Indirect = true
+		extra.ProcVar = genExpressionValue(scope, call.Callee)
+	}
+	return extra
+}
+
+// thing.method(args) is a Dot with a call on the right
+func isMethodCall(node parsing.ASTNode) bool {
+	expr, isExpr := node.(parsing.ExprNode)
+	if !isExpr || expr.Op != parsing.Dot {
+		return false
+	}
+	_, isCall := expr.Right.(parsing.ProcCall)
+	return isCall
+}
+
+// The receiver is the first argument. The typer finds the method from the type of the receiver and
+// turns ir.MethodReceiver into whatever gives the method the receiver the way it wants it. When there
+// is no such method but a member with the name holds a proc, the call goes through the member instead.
+func genMethodCallExtra(scope *scope, node parsing.ASTNode) ir.CallExtra {
+	gen := scope.gen
+	gen.pushCurrentlyGenerating(&node)
+	defer gen.popCurrentlyGenerating(&node)
+	dot := node.(parsing.ExprNode)
+	call := dot.Right.(parsing.ProcCall)
+	base := computePointerRecursive(scope, dot.Left)
+	receiver := scope.newVar()
+	scope.addOpt(ir.MakeBinaryInst(ir.MethodReceiver, receiver, base, call.Callee.Name))
+	extra := ir.CallExtra{Name: call.Callee.Name, ArgVars: []int{receiver}, Method: true}
+	for _, argNode := range call.Args {
+		extra.ArgVars = append(extra.ArgVars, genExpressionValue(scope, argNode))
+	}
+	return extra
+}
+
+// Type arguments are parsed as expressions. *T is a dereference and list(T) is a call.
+func typeDeclFromExpr(node parsing.ASTNode) parsing.TypeDecl {
+	switch n := node.(type) {
+	case parsing.IdName:
+		return parsing.TypeDecl{Base: n}
+	case parsing.ExprNode:
+		if n.Op == parsing.Dereference && n.Left == nil {
+			decl := typeDeclFromExpr(n.Right)
+			decl.LevelOfIndirection++
+			return decl
+		}
+	case parsing.ProcCall:
+		if n.Target != nil {
+			break
+		}
+		decl := parsing.TypeDecl{Base: n.Callee}
+		for _, arg := range n.Args {
+			decl.TypeArgs = append(decl.TypeArgs, typeDeclFromExpr(arg))
+		}
+		return decl
+	}
+	panic(parsing.ErrorFromNode(node, "Expected a type"))
+}
+
+// globals are always accessed through memory since any call could change them
+func genGlobalAddress(scope *scope, ident parsing.IdName) int {
+	address := scope.newVar()
+	scope.addOpt(ir.MakeMutateOnlyInst(ir.GlobalAddress, address, ident.Name))
+	return address
+}
+
+// Assign and the compound assignments like +=
+func genAssignment(scope *scope, node parsing.ExprNode) {
+	updateInst, isCompound := compoundAssignInst[node.Op]
+	leftAsIdent, leftIsIdent := node.Left.(parsing.IdName)
+	if leftIdent := leftAsIdent.Name; leftIsIdent && !scope.isGlobal(leftIdent) {
+		leftVarNum, varFound := scope.resolve(leftIdent)
+		if _, isConstant := scope.resolveConstant(leftIdent); isConstant {
+			panic(parsing.ErrorFromNode(node.Left, assignToConstantMessage))
+		}
+		if !varFound {
+			panic(parsing.ErrorFromNode(node.Left, undefinedMessage))
+		}
+		rightResult := genExpressionValue(scope, node.Right)
+		if isCompound {
+			scope.addOpt(ir.MakeBinaryInst(updateInst, leftVarNum, rightResult, nil))
+		} else {
+			scope.addOpt(ir.MakeBinaryInst(ir.Assign, leftVarNum, rightResult, nil))
+		}
+	} else {
+		assignmentPtr := genAssignmentTarget(scope, node.Left)
+		rightResult := genExpressionValue(scope, node.Right)
+		if isCompound {
+			leftTmp := scope.newVar()
+			scope.addOpt(ir.MakeBinaryInst(ir.IndirectLoad, leftTmp, assignmentPtr, nil))
+			scope.addOpt(ir.MakeBinaryInst(updateInst, leftTmp, rightResult, nil))
+			scope.addOpt(ir.MakeBinaryInst(ir.IndirectWrite, assignmentPtr, leftTmp, nil))
+		} else {
+			scope.addOpt(ir.MakeBinaryInst(ir.IndirectWrite, assignmentPtr, rightResult, nil))
+		}
+	}
+}
+
+// genDeferred generates the deferred statement as if it were written at the point where exiting leaves.
+// Names resolve from where the defer is but mutations are tracked as coming from exiting.
+func genDeferred(exiting *scope, deferred deferredStatement) {
+	exitScope := &scope{
+		gen:                 exiting.gen,
+		parentScope:         deferred.names,
+		varTable:            make(map[string]int),
+		loopLabel:           exiting.loopLabel,
+		firstVarInScope:     exiting.firstVarInScope,
+		outOfScopeMutations: exiting.outOfScopeMutations,
+	}
+	switch node := deferred.statement.(type) {
+	case parsing.ExprNode:
+		if _, isCompound := compoundAssignInst[node.Op]; isCompound || node.Op == parsing.Assign {
+			genAssignment(exitScope, node)
+		} else if isMethodCall(node) {
+			genExpressionValue(exitScope, node)
+		}
+	case parsing.MultiAssign:
+		genMultiAssign(exitScope, node)
+	default:
+		genExpressionValue(exitScope, node)
+	}
+}
+
+// run the deferred statements of every scope from scope up to and including upTo, innermost first.
+// upTo being nil means all the way up to the root scope.
+func genDeferredUntil(scope *scope, upTo *scope) {
+	for cur := scope; cur != nil; cur = cur.parentScope {
+		for i := len(cur.deferred) - 1; i >= 0; i-- {
+			genDeferred(scope, cur.deferred[i])
+		}
+		if cur == upTo {
+			break
+		}
+	}
+}
+
 // return a var number which stores a pointer
 func genAssignmentTarget(scope *scope, node parsing.ASTNode) int {
 	switch n := node.(type) {
@@ -476,6 +991,11 @@ func genAssignmentTarget(scope *scope, node parsing.ASTNode) int {
 		switch n.Op {
 		case parsing.Dereference:
 			if ident, bareDeref := n.Right.(parsing.IdName); bareDeref {
+				if scope.isGlobal(ident.Name) {
+					pointer := scope.newVar()
+					scope.addOpt(ir.MakeBinaryInst(ir.IndirectLoad, pointer, genGlobalAddress(scope, ident), nil))
+					return pointer
+				}
 				vn, found := scope.resolve(ident.Name)
 				if !found {
 					panic(parsing.ErrorFromNode(ident, undefinedMessage))
@@ -486,10 +1006,83 @@ func genAssignmentTarget(scope *scope, node parsing.ASTNode) int {
 		case parsing.ArrayAccess, parsing.Dot:
 			return computePointer(scope, node)
 		}
+	case parsing.IdName:
+		if scope.isGlobal(n.Name) {
+			return genGlobalAddress(scope, n)
+		}
+		if _, isConstant := scope.resolveConstant(n.Name); isConstant {
+			panic(parsing.ErrorFromNode(n, assignToConstantMessage))
+		}
+		panic(parsing.ErrorFromNode(n, undefinedMessage))
 	}
 	panic(parsing.ErrorFromNode(node, "Not a valid assignment target"))
 }
 
+func literalValue(n parsing.Literal) interface{} {
+	var value interface{}
+	switch n.Type {
+	case parsing.Number:
+		v, fits := parsing.ParseIntLiteral(n.Value)
+		if !fits {
+			panic(parsing.ErrorFromNode(n, "Integer literal doesn't fit in 64 bits"))
+		}
+		value = v
+	case parsing.Float:
+		v, err := strconv.ParseFloat(n.Value, 64)
+		if err != nil {
+			panic(parsing.ErrorFromNode(n, "Invalid float literal"))
+		}
+		value = v
+	case parsing.Boolean:
+		value = boolStrToBool(n.Value)
+	case parsing.Char:
+		value = n.Value[0]
+	case parsing.String:
+		value = n.Value
+	case parsing.NilPtr:
+		value = parsing.NilPtr
+	}
+	return value
+}
+
+// The initial value of a global that is an array or a struct literal. Values are in the order they
+// appear in Literal and can be CompositeValue themselves. Names is empty when the members of a struct
+// are given in declaration order.
+type CompositeValue struct {
+	Literal parsing.ASTNode
+	Type    parsing.TypeDecl
+	Names   []string
+	Values  []interface{}
+}
+
+// The value of a global is written into the data section, so it has to be known before the program runs.
+// Anything that isn't a literal is folded like the right side of a constant declaration.
+func GlobalInitialValue(node parsing.ASTNode, lookup ConstantLookup) interface{} {
+	switch n := node.(type) {
+	case parsing.Literal:
+		if n.Type == parsing.NilPtr || n.Type == parsing.Array {
+			panic(parsing.ErrorFromNode(node, "Globals can only be initialized with constants, or array and struct literals of constants"))
+		}
+		return literalValue(n)
+	case parsing.ArrayLiteral:
+		composite := CompositeValue{Literal: n, Type: ResolveArraySizes(n.Type, lookup)}
+		for _, valueNode := range n.Values {
+			composite.Values = append(composite.Values, GlobalInitialValue(valueNode, lookup))
+		}
+		return composite
+	case parsing.StructLiteral:
+		composite := CompositeValue{Literal: n, Type: parsing.TypeDecl{Base: n.Type}}
+		for _, name := range n.Names {
+			composite.Names = append(composite.Names, name.Name)
+		}
+		for _, valueNode := range n.Values {
+			composite.Values = append(composite.Values, GlobalInitialValue(valueNode, lookup))
+		}
+		return composite
+	}
+	return EvalConstant(node, lookup)
+}
+
 func boolStrToBool(s string) bool {
 	if s == "true" {
 		return true
@@ -12,8 +12,14 @@ type ProcWorkOrder struct {
 	Out       chan OptBlock
 	In        []*parsing.ASTNode
 	Name      string
+	Overload  int    // index into the overloads of Name in the environment
+	Receiver  string // the struct a method is declared on. Empty for procs that aren't methods
 	ProcDecl  parsing.ProcDecl
 	UserError chan *errors.UserError
+	Globals   map[string]bool
+	Constants map[string]interface{}
+	Enums     map[string]map[string]int64
+	Generics  map[string]int // generic procs and how many type parameters they have
 }
 
 type OptBlock struct {
@@ -29,11 +35,17 @@ type procGen struct {
 	rootScope        *scope
 	labelGen         *LabelIdGen
 	nodeStack        []*parsing.ASTNode // keep track of what node we are generating for
+	statementLine    int
 	nonTemporaryVars []int
+	globals          map[string]bool
+	constants        map[string]interface{}
+	enums            map[string]map[string]int64
+	generics         map[string]int
 }
 
 func (p *procGen) addOpt(opt ir.Inst) {
 	opt.GeneratedFrom = *p.nodeStack[len(p.nodeStack)-1]
+	opt.StatementLine = p.statementLine
 	p.opts = append(p.opts, opt)
 }
 
@@ -66,10 +78,21 @@ type scope struct {
 	gen             *procGen
 	parentScope     *scope
 	varTable        map[string]int
+	constants       map[string]interface{}
 	loopLabel       string
 	firstVarInScope int
+	// the label the user gave the loop. Only on the scope for the body of the loop
+	loopName string
 	// keep track of mutation of variables that are not local to the scope
 	outOfScopeMutations *[]int
+	// statements to run when leaving the scope, in the order they appear
+	deferred []deferredStatement
+}
+
+type deferredStatement struct {
+	statement parsing.ASTNode
+	// the names the statement can see. Names declared after the defer are not visible
+	names *scope
 }
 
 func (s *scope) inherit() *scope {
@@ -87,6 +110,49 @@ func (s *scope) inherit() *scope {
 	return &sub
 }
 
+// a copy of the names visible in the scope so far
+func (s *scope) snapshot() *scope {
+	frozen := *s
+	frozen.varTable = make(map[string]int, len(s.varTable))
+	for name, vn := range s.varTable {
+		frozen.varTable[name] = vn
+	}
+	frozen.constants = make(map[string]interface{}, len(s.constants))
+	for name, value := range s.constants {
+		frozen.constants[name] = value
+	}
+	frozen.deferred = nil
+	return &frozen
+}
+
+func (s *scope) hasDeferred() bool {
+	for cur := s; cur != nil; cur = cur.parentScope {
+		if len(cur.deferred) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// the scope for the body of the loop s is in
+func (s *scope) loopBody() *scope {
+	cur := s
+	for cur.parentScope != nil && cur.parentScope.loopLabel == cur.loopLabel {
+		cur = cur.parentScope
+	}
+	return cur
+}
+
+// the body of the loop the user labeled name, nil if there isn't one
+func (s *scope) namedLoopBody(name string) *scope {
+	for cur := s; cur != nil; cur = cur.parentScope {
+		if cur.loopName == name {
+			return cur
+		}
+	}
+	return nil
+}
+
 func (s *scope) addOpt(opt ir.Inst) {
 	if s.outOfScopeMutations != nil {
 		// if the opt mutates a var that's outside the loop
@@ -103,6 +169,8 @@ func (s *scope) resolve(name string) (int, bool) {
 		varNum, found := cur.varTable[name]
 		if found {
 			return varNum, found
+		} else if _, isConstant := cur.constants[name]; isConstant {
+			return 0, false
 		} else {
 			cur = cur.parentScope
 		}
@@ -110,6 +178,44 @@ func (s *scope) resolve(name string) (int, bool) {
 	return 0, false
 }
 
+func (s *scope) resolveConstant(name string) (interface{}, bool) {
+	cur := s
+	for cur != nil {
+		if _, isVar := cur.varTable[name]; isVar {
+			return nil, false
+		}
+		value, found := cur.constants[name]
+		if found {
+			return value, found
+		}
+		cur = cur.parentScope
+	}
+	value, found := s.gen.constants[name]
+	return value, found
+}
+
+func (s *scope) newConstant(name string, value interface{}) {
+	if s.constants == nil {
+		s.constants = make(map[string]interface{})
+	}
+	s.constants[name] = value
+}
+
+// the members of an enum. Anything else with the same name shadows the enum
+func (s *scope) resolveEnum(name string) (map[string]int64, bool) {
+	_, isLocal := s.resolve(name)
+	_, isConstant := s.resolveConstant(name)
+	members, isEnum := s.gen.enums[name]
+	return members, isEnum && !isLocal && !isConstant && !s.gen.globals[name]
+}
+
+// locals shadow globals
+func (s *scope) isGlobal(name string) bool {
+	_, isLocal := s.resolve(name)
+	_, isConstant := s.resolveConstant(name)
+	return !isLocal && !isConstant && s.gen.globals[name]
+}
+
 func (s *scope) newVar() int {
 	current := s.gen.nextVarNum
 	s.gen.nextVarNum++
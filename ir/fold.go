@@ -0,0 +1,70 @@
+package ir
+
+// Compile time evaluation in the backend and constant folding in the frontend both go through
+// the helpers here so they agree with each other and with what the generated code does at runtime.
+
+// ShiftCountMask is what the hardware looks at in the shift count
+func ShiftCountMask(operandSize int) int64 {
+	if operandSize == 8 {
+		return 63
+	}
+	return 31
+}
+
+// FoldInteger computes left op right for the arithmetic and bitwise instructions. size is the
+// size of the left operand in bytes. The caller wraps the result to the size of the left operand
+// and makes sure right isn't zero for Div and Mod.
+func FoldInteger(op InstType, left int64, right int64, size int, unsigned bool) int64 {
+	switch op {
+	case Add:
+		return left + right
+	case Sub:
+		return left - right
+	case Mult:
+		return left * right
+	case Div, Mod:
+		if unsigned {
+			if op == Mod {
+				return int64(uint64(left) % uint64(right))
+			}
+			return int64(uint64(left) / uint64(right))
+		}
+		if op == Mod {
+			return left % right
+		}
+		return left / right
+	case BitAnd:
+		return left & right
+	case BitOr:
+		return left | right
+	case BitXor:
+		return left ^ right
+	case ShiftLeft:
+		return left << uint(right&ShiftCountMask(size))
+	case ShiftRight:
+		count := uint(right & ShiftCountMask(size))
+		if unsigned {
+			return int64(uint64(left) >> count)
+		}
+		return left >> count
+	}
+	panic("ice: FoldInteger given an instruction that isn't arithmetic or bitwise")
+}
+
+func FoldCompare(how ComparisonMethod, left int64, right int64) bool {
+	switch how {
+	case Lesser:
+		return left < right
+	case LesserOrEqual:
+		return left <= right
+	case Greater:
+		return left > right
+	case GreaterOrEqual:
+		return left >= right
+	case AreEqual:
+		return left == right
+	case NotEqual:
+		return left != right
+	}
+	panic("ice: unknown comparison method")
+}
@@ -4,9 +4,9 @@ package ir
 
 import "strconv"
 
-const _InstType_name = "ZeroVarInstructionsReturnTranscludeJumpStartProcEndProcLabelOutsideLoopMutationsOutOfScopeMutationsOptionSelectStartOptionEndOptionSelectEndLoopEndMutateOnlyInstructionsCallAssignImmIncrementDecrementReadOnlyInstructionsJumpIfTrueJumpIfFalseShortJumpIfTrueShortJumpIfFalseCompareReadAndMutateInstructionsAssignTakeAddressArrayToPointerIndirectWriteIndirectLoadStructMemberPtrPeelStructNotTwoOperandUpdateInstructionsAddSubMultDivAndOr"
+const _InstType_name = "ZeroVarInstructionsReturnTranscludeJumpStartProcEndProcLabelOutsideLoopMutationsOutOfScopeMutationsOptionSelectStartOptionEndOptionSelectEndLoopEndMutateOnlyInstructionsCallAssignImmIncrementDecrementGlobalAddressProcAddressStructLiteralArrayLiteralReadOnlyInstructionsJumpIfTrueJumpIfFalseShortJumpIfTrueShortJumpIfFalseCompareSwitchBoundsCheckReadAndMutateInstructionsAssignTakeAddressArrayToPointerIndirectWriteIndirectLoadStructMemberPtrPeelStructTakeSliceNotBitNotCastMethodReceiverGlobalBaseTwoOperandUpdateInstructionsAddSubMultDivModAndOrBitAndBitOrBitXorShiftLeftShiftRight"
 
-var _InstType_index = [...]uint16{0, 19, 25, 35, 39, 48, 55, 60, 80, 99, 116, 125, 140, 147, 169, 173, 182, 191, 200, 220, 230, 241, 256, 272, 279, 304, 310, 321, 335, 348, 360, 375, 385, 388, 416, 419, 422, 426, 429, 432, 434}
+var _InstType_index = [...]uint16{0, 19, 25, 35, 39, 48, 55, 60, 80, 99, 116, 125, 140, 147, 169, 173, 182, 191, 200, 213, 224, 237, 249, 269, 279, 290, 305, 321, 328, 334, 345, 370, 376, 387, 401, 414, 426, 441, 451, 460, 463, 469, 473, 487, 497, 525, 528, 531, 535, 538, 541, 544, 546, 552, 557, 563, 572, 582}
 
 func (i InstType) String() string {
 	if i < 0 || i >= InstType(len(_InstType_index)-1) {
@@ -31,6 +31,10 @@ const (
 	AssignImm
 	Increment
 	Decrement
+	GlobalAddress
+	ProcAddress
+	StructLiteral
+	ArrayLiteral
 
 	ReadOnlyInstructions
 
@@ -39,6 +43,8 @@ const (
 	ShortJumpIfTrue
 	ShortJumpIfFalse
 	Compare
+	Switch
+	BoundsCheck
 
 	ReadAndMutateInstructions
 
@@ -49,7 +55,12 @@ const (
 	IndirectLoad
 	StructMemberPtr
 	PeelStruct
+	TakeSlice
 	Not
+	BitNot
+	Cast
+	MethodReceiver
+	GlobalBase
 
 	TwoOperandUpdateInstructions
 
@@ -57,8 +68,14 @@ const (
 	Sub
 	Mult
 	Div
+	Mod
 	And
 	Or
+	BitAnd
+	BitOr
+	BitXor
+	ShiftLeft
+	ShiftRight
 )
 
 func (i InstType) ZeroVar() bool {
@@ -91,6 +108,8 @@ type Inst struct {
 	ReadOperand   int
 	Extra         interface{}
 	GeneratedFrom parsing.ASTNode
+	// the first line of the statement the inst is for. Statements can span multiple lines
+	StatementLine int
 }
 
 func (i *Inst) Left() int {
@@ -118,16 +137,76 @@ func (i *Inst) Swap(original int, newVar int) {
 	}
 }
 
+// The first return value goes to the MutateOperand. ReturnTo gets the rest of them when
+// the proc returns more than one value.
+// Indirect calls go through the proc pointer in ProcVar. Name is the name of that var in that case.
+// Overload is filled in by the typer when there are many procs with the same name.
+// Calls to generic procs have TypeArgs. The compiler picks the instance to call for those.
+// Method calls have the receiver as the first argument. The typer changes Name to the full name of
+// the method, as in point.length, once it knows the type of the receiver.
 type CallExtra struct {
 	Name     string
 	ArgVars  []int
 	ReturnTo []int
+	Indirect bool
+	ProcVar  int
+	Overload int
+	TypeArgs []parsing.TypeDecl
+	Method   bool
+}
+
+// Names is empty when the values are in the order of the fields
+type StructLiteralExtra struct {
+	Type   string
+	Names  []string
+	Values []int
+}
+
+// Values can be shorter than the array
+type ArrayLiteralExtra struct {
+	Type   parsing.TypeDecl
+	Values []int
+}
+
+// The range is inclusive on both ends unless Exclusive is set, then High is one past the end
+type TakeSliceExtra struct {
+	Low       int
+	High      int
+	Exclusive bool
+}
+
+// Extra of the ir.StructMemberPtr that for-each loops use to find the "data" and "length" of what they
+// go over. Unlike a field access this also works on arrays.
+type ForEachMemberExtra struct {
+	Name string
+}
+
+// Of is what's being indexed. Only indexing slices is checked.
+type BoundsCheckExtra struct {
+	Of int
 }
 
 type ReturnExtra struct {
 	Values []int
 }
 
+type CaseValue struct {
+	Value int64
+	Enum  string // name of the enum the value is a member of. Empty for plain integers
+	From  parsing.ASTNode
+}
+
+type SwitchCase struct {
+	Values []CaseValue
+	Label  string
+}
+
+// the default label is always there even if the source doesn't have a default case
+type SwitchExtra struct {
+	Cases   []SwitchCase
+	Default string
+}
+
 //go:generate $GOPATH/bin/stringer -type=ComparisonMethod
 type ComparisonMethod int
 
@@ -194,13 +273,32 @@ func IterOverAllVars(opt Inst, cb func(vn int)) {
 		for _, vn := range opt.Extra.(CallExtra).ArgVars {
 			cb(vn)
 		}
+		for _, vn := range opt.Extra.(CallExtra).ReturnTo {
+			cb(vn)
+		}
+		if opt.Extra.(CallExtra).Indirect {
+			cb(opt.Extra.(CallExtra).ProcVar)
+		}
 	case Return:
 		for _, vn := range opt.Extra.(ReturnExtra).Values {
 			cb(vn)
 		}
+	case StructLiteral:
+		for _, vn := range opt.Extra.(StructLiteralExtra).Values {
+			cb(vn)
+		}
+	case ArrayLiteral:
+		for _, vn := range opt.Extra.(ArrayLiteralExtra).Values {
+			cb(vn)
+		}
 	case Compare:
 		cb(opt.Extra.(CompareExtra).Out)
 		cb(opt.Extra.(CompareExtra).Right)
+	case TakeSlice:
+		cb(opt.Extra.(TakeSliceExtra).Low)
+		cb(opt.Extra.(TakeSliceExtra).High)
+	case BoundsCheck:
+		cb(opt.Extra.(BoundsCheckExtra).Of)
 	}
 }
 
@@ -220,6 +318,12 @@ func IterAndMutate(opt *Inst, cb func(vn *int)) {
 		for i := range extra.ArgVars {
 			cb(&extra.ArgVars[i])
 		}
+		for i := range extra.ReturnTo {
+			cb(&extra.ReturnTo[i])
+		}
+		if extra.Indirect {
+			cb(&extra.ProcVar)
+		}
 		opt.Extra = extra
 	case Return:
 		extra := opt.Extra.(ReturnExtra)
@@ -227,16 +331,37 @@ func IterAndMutate(opt *Inst, cb func(vn *int)) {
 			cb(&extra.Values[i])
 		}
 		opt.Extra = extra
+	case StructLiteral:
+		extra := opt.Extra.(StructLiteralExtra)
+		for i := range extra.Values {
+			cb(&extra.Values[i])
+		}
+		opt.Extra = extra
+	case ArrayLiteral:
+		extra := opt.Extra.(ArrayLiteralExtra)
+		for i := range extra.Values {
+			cb(&extra.Values[i])
+		}
+		opt.Extra = extra
 	case Compare:
 		extra := opt.Extra.(CompareExtra)
 		cb(&extra.Out)
 		cb(&extra.Right)
 		opt.Extra = extra
+	case TakeSlice:
+		extra := opt.Extra.(TakeSliceExtra)
+		cb(&extra.Low)
+		cb(&extra.High)
+		opt.Extra = extra
+	case BoundsCheck:
+		extra := opt.Extra.(BoundsCheckExtra)
+		cb(&extra.Of)
+		opt.Extra = extra
 	}
 }
 
-// There is at most one mutation per instruction
-// not true if we do :multireturn
+// There is at most one mutation per instruction, except for calls that return more than one value.
+// The frontend always gives those fresh temporaries for ReturnTo so they don't need to be tracked like other mutations.
 func FindMutationVar(opt *Inst) int {
 	const noMutation = -1
 	if opt.Type == IndirectWrite {
@@ -267,12 +392,28 @@ func EnumerateAllReadOnlyVars(opt *Inst, cb func(vn int)) {
 		for _, vn := range opt.Extra.(CallExtra).ArgVars {
 			cb(vn)
 		}
+		if opt.Extra.(CallExtra).Indirect {
+			cb(opt.Extra.(CallExtra).ProcVar)
+		}
 	case Return:
 		for _, vn := range opt.Extra.(ReturnExtra).Values {
 			cb(vn)
 		}
+	case StructLiteral:
+		for _, vn := range opt.Extra.(StructLiteralExtra).Values {
+			cb(vn)
+		}
+	case ArrayLiteral:
+		for _, vn := range opt.Extra.(ArrayLiteralExtra).Values {
+			cb(vn)
+		}
 	case Compare:
 		cb(opt.Extra.(CompareExtra).Right)
+	case TakeSlice:
+		cb(opt.Extra.(TakeSliceExtra).Low)
+		cb(opt.Extra.(TakeSliceExtra).High)
+	case BoundsCheck:
+		cb(opt.Extra.(BoundsCheckExtra).Of)
 	}
 }
 
@@ -301,7 +442,36 @@ func Dump(insts []Inst) {
 		case Call:
 			extra := opt.Extra.(CallExtra)
 			fmt.Printf(" %s %v", extra.Name, extra.ArgVars)
-		case Label, Jump, JumpIfTrue, JumpIfFalse, StartProc, PeelStruct, StructMemberPtr:
+			if extra.Indirect {
+				fmt.Printf(" through %d", extra.ProcVar)
+			}
+			if len(extra.ReturnTo) > 0 {
+				fmt.Printf(" also returns to %v", extra.ReturnTo)
+			}
+		case StructLiteral:
+			extra := opt.Extra.(StructLiteralExtra)
+			fmt.Printf(" %s %v %v", extra.Type, extra.Names, extra.Values)
+		case ArrayLiteral:
+			fmt.Printf(" %v", opt.Extra.(ArrayLiteralExtra).Values)
+		case TakeSlice:
+			extra := opt.Extra.(TakeSliceExtra)
+			if extra.Exclusive {
+				fmt.Printf(" %d..<%d", extra.Low, extra.High)
+			} else {
+				fmt.Printf(" %d..%d", extra.Low, extra.High)
+			}
+		case BoundsCheck:
+			fmt.Printf(" of %d", opt.Extra.(BoundsCheckExtra).Of)
+		case Switch:
+			extra := opt.Extra.(SwitchExtra)
+			for _, switchCase := range extra.Cases {
+				fmt.Printf(" %s:", switchCase.Label)
+				for _, value := range switchCase.Values {
+					fmt.Printf(" %d", value.Value)
+				}
+			}
+			fmt.Printf(" default: %s", extra.Default)
+		case Label, Jump, JumpIfTrue, JumpIfFalse, StartProc, PeelStruct, StructMemberPtr, GlobalAddress, ProcAddress:
 			fmt.Printf(" %v", opt.Extra)
 		case AssignImm, OptionSelectStart, OutsideLoopMutations, OptionEnd:
 			fmt.Printf(" (%v)", opt.Extra)
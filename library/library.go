@@ -122,6 +122,119 @@ proc_print_int:
 	pop rbp
 	ret
 
+; prints xmm0 with 6 digits after the decimal point. The integer part and the fraction are
+; converted separately so the integer part can use all 64 bits. Magnitudes past that print
+; as overflow.
+proc_print_float:
+	push rbp
+	mov rbp, rsp
+	sub rsp, 32
+
+	mov rsi, rbp
+	dec rsi
+	mov byte [rsi], 10
+	xor r8, r8
+	movq rax, xmm0
+	btr rax, 63
+	adc r8, 0
+	movq xmm0, rax
+	ucomisd xmm0, [rel _float_two_to_64]
+	jp .nan
+	jae .too_big
+	ucomisd xmm0, [rel _float_two_to_63]
+	jae .top_bit_set
+	cvttsd2si rax, xmm0
+	cvtsi2sd xmm1, rax
+	subsd xmm0, xmm1
+	mov rcx, 1000000
+	cvtsi2sd xmm1, rcx
+	mulsd xmm0, xmm1
+	cvtsd2si r10, xmm0
+	; the fraction can round up to a whole
+	cmp r10, rcx
+	jb .digits
+	sub r10, rcx
+	inc rax
+	jmp .digits
+.top_bit_set:
+	; values this big have no fraction
+	subsd xmm0, [rel _float_two_to_63]
+	cvttsd2si rax, xmm0
+	btc rax, 63
+	xor r10, r10
+.digits:
+	mov r11, rax
+	mov rax, r10
+	mov rcx, 10
+	mov r9, 6
+.fraction:
+	xor rdx, rdx
+	div rcx
+	add dl, 48
+	dec rsi
+	mov [rsi], dl
+	dec r9
+	jnz .fraction
+	dec rsi
+	mov byte [rsi], 46
+	mov rax, r11
+.integer:
+	xor rdx, rdx
+	div rcx
+	add dl, 48
+	dec rsi
+	mov [rsi], dl
+	cmp rax, 0
+	jnz .integer
+
+	cmp r8, 0
+	jz .write
+	dec rsi
+	mov byte [rsi], 45
+.write:
+	mov rdi, rsi
+	mov rsi, rbp
+	sub rsi, rdi
+	call proc_writes
+	jmp .done
+.nan:
+	lea rdi, [rel _float_nan_message]
+	mov rsi, 4
+	call proc_writes
+	jmp .done
+.too_big:
+	; the messages start with a minus sign that's skipped for positive values
+	lea rdi, [rel _float_overflow_message]
+	mov rsi, 10
+	ucomisd xmm0, [rel _float_infinity]
+	jne .signed_message
+	lea rdi, [rel _float_infinity_message]
+	mov rsi, 5
+.signed_message:
+	cmp r8, 0
+	jnz .write_message
+	inc rdi
+	dec rsi
+.write_message:
+	call proc_writes
+.done:
+	mov rsp, rbp
+	pop rbp
+	ret
+
+_float_two_to_63:
+	dq 0x43e0000000000000
+_float_two_to_64:
+	dq 0x43f0000000000000
+_float_infinity:
+	dq 0x7ff0000000000000
+_float_nan_message:
+	db "nan", 10
+_float_overflow_message:
+	db "-overflow", 10
+_float_infinity_message:
+	db "-inf", 10
+
 _intrinsic_zero_mem:
 .write8:
     cmp rcx, 8
@@ -144,7 +257,20 @@ _intrinsic_zero_mem:
 _intrinsic_memcpy:
 	cld
 	rep movsb
-	ret`)
+	ret
+
+_intrinsic_out_of_bounds:
+	mov rax, 1
+	mov rdi, 2
+	lea rsi, [rel _out_of_bounds_message]
+	mov rdx, 20
+	syscall
+	mov eax, 60
+	mov edi, 1
+	syscall
+
+_out_of_bounds_message:
+	db "index out of bounds", 10`)
 }
 
 func WriteDecimalTable(out io.Writer) {
@@ -221,5 +347,5 @@ proc_binToDecTable:
 
 func AddLibcExtrasToEnv(env *typing.EnvRecord, typer *typing.Typer) {
 	environReturn := typing.BuildRecordWithIndirection(typer.Builtins[typing.U8Idx], 2)
-	env.Procs["environ"] = typing.ProcRecord{Return: &environReturn}
+	env.Procs["environ"] = []typing.ProcRecord{{Return: &environReturn}}
 }
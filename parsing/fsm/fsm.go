@@ -7,6 +7,8 @@ package fsm
 type StateMachine interface {
 	Feed(rune)
 	Accepted() bool
+	// true when no more input can make the machine accept
+	Failed() bool
 }
 
 type DFA struct {
@@ -53,6 +55,10 @@ func (d *DFA) Accepted() bool {
 	return d.state == d.acceptState
 }
 
+func (d *DFA) Failed() bool {
+	return d.state == FailState
+}
+
 func (d DFA) State() int {
 	return d.state
 }
@@ -85,6 +91,10 @@ func (m *NFA) Accepted() bool {
 	return false
 }
 
+func (m *NFA) Failed() bool {
+	return len(m.state) == 0
+}
+
 func NewBackwardNFA(desc *DFADescription) NFA {
 	var dfa NFA
 	dfa.state = []uint8{desc.AcceptState}
@@ -108,5 +118,14 @@ func AdvanceAll(c rune, dfas ...StateMachine) int {
 	return accepted
 }
 
+func AllFailed(machines ...StateMachine) bool {
+	for _, m := range machines {
+		if !m.Failed() {
+			return false
+		}
+	}
+	return true
+}
+
 // A state in every DFA that traps and is not an accept state.
 const FailState = -1
@@ -90,6 +90,8 @@ var validBinLiterals = []string{
 	"0b1111111",
 	"0b11",
 	"0b00",
+	"0b1111_0000",
+	"0b1_0_1",
 }
 
 var invalidBinLiterals = []string{
@@ -97,6 +99,23 @@ var invalidBinLiterals = []string{
 	"0B",
 	"0b0123",
 	"0B12",
+	"0b_1",
+	"0b1_",
+	"0b1__0",
+}
+
+var validOctalLiterals = []string{
+	"0o755",
+	"0o0",
+	"0o17_777",
+}
+
+var invalidOctalLiterals = []string{
+	"0o",
+	"0o8",
+	"0o_7",
+	"0o7_",
+	"0O7",
 }
 
 var validHexLiterals = []string{
@@ -107,6 +126,8 @@ var validHexLiterals = []string{
 	"0xabcdef",
 	"0xABCDEF",
 	"0xaBCDeF",
+	"0xFFFF_ffff",
+	"0x7f_00_00_01",
 }
 
 var invalidHexLiterals = []string{
@@ -114,6 +135,8 @@ var invalidHexLiterals = []string{
 	"0X",
 	"0xXXxxX",
 	"0xabcg",
+	"0x_ff",
+	"0xff_",
 }
 
 var validDecimalLiterals = []string{
@@ -121,6 +144,14 @@ var validDecimalLiterals = []string{
 	"0",
 	"0000",
 	"040506",
+	"1_000_000",
+	"1_0",
+}
+
+var invalidDecimalLiterals = []string{
+	"_1",
+	"1_",
+	"1__000",
 }
 
 var validFloatLiterals = []string{
@@ -148,6 +179,11 @@ func TestNumericLiterals(t *testing.T) {
 	testMachine(t, BinaryLiteral, validFloatLiterals, false)
 	testMachine(t, BinaryLiteral, validDecimalLiterals, false)
 
+	testMachine(t, OctalLiteral, validOctalLiterals, true)
+	testMachine(t, OctalLiteral, invalidOctalLiterals, false)
+	testMachine(t, OctalLiteral, validDecimalLiterals, false)
+	testMachine(t, OctalLiteral, validHexLiterals, false)
+
 	testMachine(t, HexLiteral, validHexLiterals, true)
 	testMachine(t, HexLiteral, invalidHexLiterals, false)
 	testMachine(t, HexLiteral, validIds, false)
@@ -160,6 +196,7 @@ func TestNumericLiterals(t *testing.T) {
 	testMachine(t, DecimalLiteral, validDecimalLiterals, true)
 	testMachine(t, DecimalLiteral, invalidFloatLiterals, false)
 	testMachine(t, DecimalLiteral, validHexLiterals, false)
+	testMachine(t, DecimalLiteral, invalidDecimalLiterals, false)
 }
 
 // Only works with ascii encoding. Why is this not in the standard lib?
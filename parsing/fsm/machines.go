@@ -53,11 +53,38 @@ func BinaryLiteral() *DFADescription {
 		{2, '1', 3},
 		{3, '0', 3},
 		{3, '1', 3},
+		// a digit separator has to be followed by a digit
+		{3, '_', 4},
+		{4, '0', 3},
+		{4, '1', 3},
 	}
 	desc.AcceptState = 3
 	return desc
 }
 
+var octalLiteralCache *DFADescription
+
+func OctalLiteral() *DFADescription {
+	if octalLiteralCache != nil {
+		return octalLiteralCache
+	}
+	desc := new(DFADescription)
+	desc.Rules = []TransitionRule{
+		{0, '0', 1},
+		{1, 'o', 2},
+		{3, '_', 4},
+	}
+	for _, c := range "01234567" {
+		desc.Rules = append(desc.Rules,
+			TransitionRule{2, c, 3},
+			TransitionRule{3, c, 3},
+			TransitionRule{4, c, 3})
+	}
+	desc.AcceptState = 3
+	octalLiteralCache = desc
+	return desc
+}
+
 var hexLiteralCache *DFADescription
 
 func HexLiteral() *DFADescription {
@@ -66,28 +93,29 @@ func HexLiteral() *DFADescription {
 	}
 	var desc DFADescription
 	hexDigits := []rune("abcdef")
-	desc.Rules = make([]TransitionRule, 2+2*(2*len(hexDigits)+len(digits)))
+	desc.Rules = make([]TransitionRule, 3+3*(2*len(hexDigits)+len(digits)))
 
 	desc.Rules[0] = TransitionRule{0, '0', 1}
 	desc.Rules[1] = TransitionRule{1, 'x', 2}
-	i := 2
+	desc.Rules[2] = TransitionRule{3, '_', 4}
+	i := 3
 
 	for _, c := range digits {
 		desc.Rules[i] = TransitionRule{2, c, 3}
 		i++
 		desc.Rules[i] = TransitionRule{3, c, 3}
 		i++
+		desc.Rules[i] = TransitionRule{4, c, 3}
+		i++
 	}
 	for _, c := range hexDigits {
-		desc.Rules[i] = TransitionRule{2, c, 3}
-		i++
-		desc.Rules[i] = TransitionRule{3, c, 3}
-		i++
 		upper := unicode.To(unicode.UpperCase, c)
-		desc.Rules[i] = TransitionRule{2, upper, 3}
-		i++
-		desc.Rules[i] = TransitionRule{3, upper, 3}
-		i++
+		for _, from := range []uint8{2, 3, 4} {
+			desc.Rules[i] = TransitionRule{from, c, 3}
+			i++
+			desc.Rules[i] = TransitionRule{from, upper, 3}
+			i++
+		}
 	}
 	desc.AcceptState = 3
 	hexLiteralCache = &desc
@@ -125,13 +153,16 @@ func DecimalLiteral() *DFADescription {
 		return decimalLiteralCache
 	}
 	desc := new(DFADescription)
-	desc.Rules = make([]TransitionRule, 2*len(digits))
-	i := 0
+	desc.Rules = make([]TransitionRule, 3*len(digits)+1)
+	desc.Rules[0] = TransitionRule{1, '_', 2}
+	i := 1
 	for _, c := range digits {
 		desc.Rules[i] = TransitionRule{0, c, 1}
 		i++
 		desc.Rules[i] = TransitionRule{1, c, 1}
 		i++
+		desc.Rules[i] = TransitionRule{2, c, 1}
+		i++
 	}
 	desc.AcceptState = 1
 	decimalLiteralCache = desc
@@ -144,11 +175,13 @@ func ForwardNumLiteralMachines() []StateMachine {
 	dec := NewForwardDFA(DecimalLiteral())
 	hex := NewForwardDFA(HexLiteral())
 	bin := NewForwardDFA(BinaryLiteral())
+	oct := NewForwardDFA(OctalLiteral())
 	fl := NewForwardDFA(FloatLiteral())
 	return []StateMachine{
 		StateMachine(&dec),
 		StateMachine(&hex),
 		StateMachine(&bin),
+		StateMachine(&oct),
 		StateMachine(&fl),
 	}
 }
@@ -157,11 +190,13 @@ func BackwardNumLiteralMachines() []StateMachine {
 	dec := NewBackwardNFA(DecimalLiteral())
 	hex := NewBackwardNFA(HexLiteral())
 	bin := NewBackwardNFA(BinaryLiteral())
+	oct := NewBackwardNFA(OctalLiteral())
 	fl := NewBackwardNFA(FloatLiteral())
 	return []StateMachine{
 		StateMachine(&dec),
 		StateMachine(&hex),
 		StateMachine(&bin),
+		StateMachine(&oct),
 		StateMachine(&fl),
 	}
 }
@@ -4,9 +4,9 @@ package parsing
 
 import "strconv"
 
-const _LiteralType_name = "NumberStringArrayBooleanNilPtr"
+const _LiteralType_name = "NumberStringArrayBooleanNilPtrFloatChar"
 
-var _LiteralType_index = [...]uint8{0, 6, 12, 17, 24, 30}
+var _LiteralType_index = [...]uint8{0, 6, 12, 17, 24, 30, 35, 39}
 
 func (i LiteralType) String() string {
 	i -= 1
@@ -1,59 +1,102 @@
 package parsing
 
 var tokToOp = map[string]Operator{
-	"::": ConstDeclare,
-	"..": Range,
-	"[":  ArrayAccess,
-	":=": Declare,
-	"<":  Lesser,
-	"<=": LesserEqual,
-	">":  Greater,
-	">=": GreaterEqual,
-	"==": DoubleEqual,
-	"!=": BangEqual,
-	"=":  Assign,
-	"+":  Plus,
-	"/":  Divide,
-	"+=": PlusEqual,
-	"-=": MinusEqual,
-	"@":  Dereference,
-	"*":  Star,
-	"-":  Minus,
-	".":  Dot,
-	"&":  AddressOf,
-	"&&": LogicalAnd,
-	"||": LogicalOr,
-	"!":  LogicalNot,
+	"::":  ConstDeclare,
+	"..":  Range,
+	"..<": RangeExclusive,
+	"[":   ArrayAccess,
+	":=":  Declare,
+	"<":   Lesser,
+	"<=":  LesserEqual,
+	">":   Greater,
+	">=":  GreaterEqual,
+	"==":  DoubleEqual,
+	"!=":  BangEqual,
+	"=":   Assign,
+	"+":   Plus,
+	"/":   Divide,
+	"%":   Modulo,
+	"+=":  PlusEqual,
+	"-=":  MinusEqual,
+	"@":   Dereference,
+	"*":   Star,
+	"-":   Minus,
+	".":   Dot,
+	"&":   AddressOf,
+	"&&":  LogicalAnd,
+	"||":  LogicalOr,
+	"!":   LogicalNot,
+	"|":   BitOr,
+	"^":   BitXor,
+	"~":   BitNot,
+	"<<":  ShiftLeft,
+	">>":  ShiftRight,
+	"&=":  BitAndEqual,
+	"|=":  BitOrEqual,
+	"^=":  BitXorEqual,
+	"<<=": ShiftLeftEqual,
+	">>=": ShiftRightEqual,
+	"%=":  ModuloEqual,
 }
 
 var precedence = map[Operator]int{
-	Dot:          0,
-	ArrayAccess:  0,
-	Dereference:  5,
-	AddressOf:    5,
-	Star:         10,
-	Divide:       10,
-	Plus:         20,
-	Minus:        20,
-	LogicalNot:   25,
-	Lesser:       30,
-	LesserEqual:  30,
-	Greater:      30,
-	GreaterEqual: 30,
-	DoubleEqual:  30,
-	BangEqual:    30,
-	LogicalAnd:   40,
-	LogicalOr:    40,
-	Range:        90,
-	PlusEqual:    100,
-	MinusEqual:   100,
-	Assign:       100,
-	Declare:      100,
-	ConstDeclare: 100,
+	Dot:             0,
+	ArrayAccess:     0,
+	Call:            0,
+	Dereference:     5,
+	AddressOf:       5,
+	BitNot:          5,
+	Star:            10,
+	Divide:          10,
+	Modulo:          10,
+	BitAnd:          10,
+	ShiftLeft:       10,
+	ShiftRight:      10,
+	Plus:            20,
+	Minus:           20,
+	BitOr:           20,
+	BitXor:          20,
+	LogicalNot:      25,
+	Lesser:          30,
+	LesserEqual:     30,
+	Greater:         30,
+	GreaterEqual:    30,
+	DoubleEqual:     30,
+	BangEqual:       30,
+	LogicalAnd:      40,
+	LogicalOr:       40,
+	Range:           90,
+	RangeExclusive:  90,
+	PlusEqual:       100,
+	MinusEqual:      100,
+	BitAndEqual:     100,
+	BitOrEqual:      100,
+	BitXorEqual:     100,
+	ShiftLeftEqual:  100,
+	ShiftRightEqual: 100,
+	ModuloEqual:     100,
+	Assign:          100,
+	Declare:         100,
+	ConstDeclare:    100,
 }
 
 var isUnary = map[Operator]bool{
 	Dereference: true,
 	AddressOf:   true,
 	LogicalNot:  true,
+	BitNot:      true,
+}
+
+var isAssignment = map[Operator]bool{
+	Declare:         true,
+	ConstDeclare:    true,
+	Assign:          true,
+	PlusEqual:       true,
+	MinusEqual:      true,
+	BitAndEqual:     true,
+	BitOrEqual:      true,
+	BitXorEqual:     true,
+	ShiftLeftEqual:  true,
+	ShiftRightEqual: true,
+	ModuloEqual:     true,
 }
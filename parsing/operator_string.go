@@ -4,9 +4,9 @@ package parsing
 
 import "strconv"
 
-const _Operator_name = "DotStarMinusPlusRangeDivideCallAssignDeclarePlusEqualMinusEqualLesserLesserEqualGreaterGreaterEqualDoubleEqualBangEqualLogicalAndLogicalOrLogicalNotConstDeclareDereferenceAddressOfArrayAccess"
+const _Operator_name = "DotStarMinusPlusRangeDivideCallAssignDeclarePlusEqualMinusEqualLesserLesserEqualGreaterGreaterEqualDoubleEqualBangEqualLogicalAndLogicalOrLogicalNotConstDeclareDereferenceAddressOfArrayAccessBitAndBitOrBitXorBitNotShiftLeftShiftRightBitAndEqualBitOrEqualBitXorEqualShiftLeftEqualShiftRightEqualModuloModuloEqualRangeExclusive"
 
-var _Operator_index = [...]uint8{0, 3, 7, 12, 16, 21, 27, 31, 37, 44, 53, 63, 69, 80, 87, 99, 110, 119, 129, 138, 148, 160, 171, 180, 191}
+var _Operator_index = [...]uint16{0, 3, 7, 12, 16, 21, 27, 31, 37, 44, 53, 63, 69, 80, 87, 99, 110, 119, 129, 138, 148, 160, 171, 180, 191, 197, 202, 208, 214, 223, 233, 244, 254, 265, 279, 294, 300, 311, 325}
 
 func (i Operator) String() string {
 	i -= 1
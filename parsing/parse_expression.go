@@ -4,12 +4,14 @@ import (
 	"container/heap"
 	"fmt"
 	"github.com/XrXr/alang/errors"
-	"strconv"
+	"strings"
 	"unicode"
 )
 
 var _ = fmt.Printf // for debugging. remove when done
 const invalidDeclNameMessage = "Invalid name"
+const typeParamMessage = "Type parameters look like $T"
+const enumMemberSyntaxMessage = "Enum members look like \"Name\" or \"Name = value\""
 
 type parsedNode struct {
 	node     ASTNode
@@ -21,6 +23,7 @@ type bracketType int
 const (
 	round bracketType = iota
 	square
+	curly
 )
 
 type bracketInfo struct { //index to the opening bracket and the closing
@@ -66,15 +69,21 @@ func (o *OpHeap) Pop() interface{} {
 	return x
 }
 
+// the tokens of one statement. The statement can span multiple lines
 type lineParse struct {
-	tokens     []string
-	indices    []int
-	lineNumber int
+	tokens  []string
+	indices []int
+	lines   []int // the line each token is on
 }
 
 // source location helpers
 func (l *lineParse) makeLocation(startToken, endToken int) sourceLocation {
-	return sourceLocation{line: l.lineNumber, startColumn: l.startOfTok(startToken), endColumn: l.endOfTok(endToken)}
+	return sourceLocation{
+		line:        l.lines[startToken],
+		startColumn: l.startOfTok(startToken),
+		endLine:     l.lines[endToken],
+		endColumn:   l.endOfTok(endToken),
+	}
 }
 
 func (l *lineParse) startOfTok(tokIdx int) int {
@@ -94,13 +103,36 @@ func (l *lineParse) singleTokError(tokIdx int, message string) error {
 }
 
 func (l *lineParse) errorFromTokIdx(start, end int, message string) error {
-	return errors.MakeError(l.startOfTok(start), l.endOfTok(end), message)
+	return &errors.UserError{
+		Line:        l.lines[start],
+		StartColumn: l.startOfTok(start),
+		EndLine:     l.lines[end],
+		EndColumn:   l.endOfTok(end),
+		Message:     message,
+	}
 }
 
 func (l *lineParse) makeIdent(idx int) IdName {
 	return IdName{l.singleTokSourceLocation(idx), l.tokens[idx]}
 }
 
+// statements that make sense to run on the way out of a block
+func deferrable(node ASTNode) bool {
+	switch node := node.(type) {
+	case ExprNode:
+		switch node.Op {
+		case Declare, ConstDeclare:
+			return false
+		}
+		return true
+	case MultiAssign:
+		return node.Op == Assign
+	case ProcCall:
+		return true
+	}
+	return false
+}
+
 // Errors from here are all based token index. The caller is responsible for translating the indices to source column numbers
 func (l *lineParse) parseInStatementContext() (ASTNode, error) {
 	parsed := make(map[int]parsedNode)
@@ -126,12 +158,13 @@ func (l *lineParse) parseInStatementContext() (ASTNode, error) {
 		if nTokens == 1 {
 			return ReturnNode{sourceLocation: l.singleTokSourceLocation(0)}, nil
 		}
-		parsed, err := l.parseExprWithParen(parsed, 1, nTokens)
+		values, err := l.parseExprList(parsed, 1, nTokens)
 		if err != nil {
 			return nil, err
 		}
 		return ReturnNode{
-			Values: []ASTNode{parsed},
+			sourceLocation: l.makeLocation(0, nTokens-1),
+			Values:         values,
 		}, nil
 	case firstToken == "for":
 		if tokens[nTokens-1] != "{" {
@@ -141,43 +174,171 @@ func (l *lineParse) parseInStatementContext() (ASTNode, error) {
 			// "for {"
 			return Loop{}, nil
 		}
-		parsed, err := l.parseExprWithParen(parsed, 1, nTokens-1)
+		exprEnd := nTokens - 1
+		var step ASTNode
+		if stepIdx := l.findStepClause(1, nTokens-1); stepIdx != -1 {
+			var err error
+			step, err = l.parseExprWithParen(parsed, stepIdx+1, nTokens-1)
+			if err != nil {
+				return nil, err
+			}
+			if step == nil {
+				return nil, l.singleTokError(stepIdx, "Expected a step after this")
+			}
+			exprEnd = stepIdx
+		}
+		loop := Loop{Step: step}
+		exprStart := 1
+		if inIdx := l.findForEachIn(exprEnd); inIdx != -1 {
+			if err := l.parseForEachNames(&loop, inIdx); err != nil {
+				return nil, err
+			}
+			exprStart = inIdx + 1
+			if exprStart == exprEnd {
+				return nil, l.singleTokError(inIdx, "Expected something to loop over after this")
+			}
+		}
+		parsed, err := l.parseExprWithParen(parsed, exprStart, exprEnd)
 		if err != nil {
 			return nil, err
 		}
-		return Loop{
-			Expression: parsed,
-		}, nil
+		loop.Expression = parsed
+		return loop, nil
 	case (firstToken == "else" && nTokens == 2 && tokens[1] == "{") ||
 		(firstToken == "}" && nTokens == 3 && tokens[1] == "else" && tokens[2] == "{"):
 		return ElseNode{}, nil
-	case firstToken == "struct" && nTokens == 3 && tokens[2] == "{":
+	case (firstToken == "struct" || firstToken == "union") && nTokens == 3 && tokens[2] == "{":
 		if tokenIsId(tokens[1]) {
 			loc := l.makeLocation(0, 2)
-			return StructDeclare{sourceLocation: loc, Name: l.makeIdent(1)}, nil
+			return StructDeclare{sourceLocation: loc, Name: l.makeIdent(1), IsUnion: firstToken == "union"}, nil
 		} else {
 			return nil, l.singleTokError(1, invalidDeclNameMessage)
 		}
+	case (firstToken == "struct" || firstToken == "union") && nTokens > 5 && tokens[2] == "(" && tokens[nTokens-2] == ")" && tokens[nTokens-1] == "{":
+		// struct list($T) {
+		if !tokenIsId(tokens[1]) {
+			return nil, l.singleTokError(1, invalidDeclNameMessage)
+		}
+		var params []IdName
+		for _, piece := range l.splitOnCommas(3, nTokens-2) {
+			if piece[1]-piece[0] != 2 || tokens[piece[0]] != "$" || !tokenIsId(tokens[piece[0]+1]) {
+				return nil, l.errorFromTokIdx(piece[0], piece[1]-1, typeParamMessage)
+			}
+			params = append(params, l.makeIdent(piece[0]+1))
+		}
+		loc := l.makeLocation(0, nTokens-1)
+		return StructDeclare{sourceLocation: loc, Name: l.makeIdent(1), TypeParams: params, IsUnion: firstToken == "union"}, nil
+	case firstToken == "switch":
+		if tokens[nTokens-1] != "{" {
+			return nil, l.singleTokError(0, "switch statement must end in \"{\"")
+		}
+		if nTokens < 3 {
+			return nil, l.singleTokError(0, "switch statements need to have an expression")
+		}
+		parsed, err := l.parseExprWithParen(parsed, 1, nTokens-1)
+		if err != nil {
+			return nil, err
+		}
+		return SwitchNode{
+			sourceLocation: l.makeLocation(0, nTokens-1),
+			Value:          parsed,
+		}, nil
+	case firstToken == "case":
+		if tokens[nTokens-1] != ":" {
+			return nil, l.singleTokError(0, "case must end in \":\"")
+		}
+		if nTokens < 3 {
+			return nil, l.singleTokError(0, "case needs at least one value")
+		}
+		values, err := l.parseExprList(parsed, 1, nTokens-1)
+		if err != nil {
+			return nil, err
+		}
+		return CaseNode{
+			sourceLocation: l.makeLocation(0, nTokens-1),
+			Values:         values,
+		}, nil
+	case firstToken == "default" && nTokens == 2 && tokens[1] == ":":
+		return CaseNode{
+			sourceLocation: l.makeLocation(0, 1),
+			IsDefault:      true,
+		}, nil
+	case firstToken == "enum":
+		return l.parseEnumDecl()
 	case firstToken == "var":
 		if nTokens < 3 {
 			return nil, l.errorFromTokIdx(0, nTokens-1, "Incomplete declaration")
 		}
 		return l.parseDecl(1, nTokens)
+	case firstToken == "defer":
+		if nTokens == 1 {
+			return nil, l.singleTokError(0, "defer needs a statement")
+		}
+		rest := lineParse{tokens: tokens[1:], indices: l.indices[1:], lines: l.lines[1:]}
+		statement, err := rest.parseInStatementContext()
+		if err != nil {
+			return nil, err
+		}
+		if !deferrable(statement) {
+			return nil, rest.errorFromTokIdx(0, nTokens-2, "Only assignments and calls can be deferred")
+		}
+		return DeferNode{
+			sourceLocation: l.makeLocation(0, nTokens-1),
+			Statement:      statement,
+		}, nil
 	case firstToken == "break" && nTokens == 1:
-		return BreakNode{l.singleTokSourceLocation(0)}, nil
+		return BreakNode{sourceLocation: l.singleTokSourceLocation(0)}, nil
 	case firstToken == "continue" && nTokens == 1:
-		return ContinueNode{l.singleTokSourceLocation(0)}, nil
+		return ContinueNode{sourceLocation: l.singleTokSourceLocation(0)}, nil
+	case (firstToken == "break" || firstToken == "continue") && nTokens == 2:
+		if !tokenIsId(tokens[1]) {
+			return nil, l.singleTokError(1, "Expected the label of a loop")
+		}
+		loc := l.makeLocation(0, 1)
+		if firstToken == "break" {
+			return BreakNode{sourceLocation: loc, Label: l.makeIdent(1)}, nil
+		}
+		return ContinueNode{sourceLocation: loc, Label: l.makeIdent(1)}, nil
+	case nTokens > 2 && tokens[1] == ":" && tokens[2] == "for":
+		// outer: for {
+		if !tokenIsId(firstToken) {
+			return nil, l.singleTokError(0, "Invalid loop label")
+		}
+		rest := lineParse{tokens: tokens[2:], indices: l.indices[2:], lines: l.lines[2:]}
+		statement, err := rest.parseInStatementContext()
+		if err != nil {
+			return nil, err
+		}
+		loop := statement.(Loop)
+		loop.Label = l.makeIdent(0)
+		return loop, nil
 	case firstToken == "}" && nTokens == 1:
 		return BlockEnd{l.singleTokSourceLocation(0)}, nil
 	}
+	depth := 0
 	for index, tok := range tokens {
+		switch tok {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		}
 		op := tokToOp[tok]
-		if op == Declare || op == ConstDeclare || op == Assign || op == PlusEqual || op == MinusEqual {
+		if depth > 0 {
+			// named fields in struct literals
+			continue
+		}
+		if isAssignment[op] && len(l.splitOnCommas(0, index)) > 1 {
+			return l.parseMultiAssign(parsed, op, index)
+		}
+		if isAssignment[op] {
 			left, err := l.parseExprWithParen(parsed, 0, index)
 			if err != nil {
 				return nil, err
 			}
-			if op == Declare || op == ConstDeclare {
+			// methods are declared as point.length :: proc
+			isMethod := op == ConstDeclare && index+1 < nTokens && l.tokens[index+1] == "proc" && IsMethodName(left)
+			if (op == Declare || op == ConstDeclare) && !isMethod {
 				if _, leftIsIdent := left.(IdName); !leftIsIdent {
 					return nil, ErrorFromNode(left, "This must be an identifier")
 				}
@@ -204,6 +365,77 @@ func (l *lineParse) parseInStatementContext() (ASTNode, error) {
 	return node, nil
 }
 
+// a, b := f() and a, b = f()
+func (l *lineParse) parseMultiAssign(parsed map[int]parsedNode, op Operator, opTokIdx int) (ASTNode, error) {
+	nTokens := len(l.tokens)
+	if op != Declare && op != Assign {
+		return nil, l.singleTokError(opTokIdx, "Only \"=\" and \":=\" can assign to more than one target")
+	}
+	targets, err := l.parseExprList(parsed, 0, opTokIdx)
+	if err != nil {
+		return nil, err
+	}
+	if op == Declare {
+		for _, target := range targets {
+			if _, isIdent := target.(IdName); !isIdent {
+				return nil, ErrorFromNode(target, "This must be an identifier")
+			}
+		}
+	}
+	value, err := l.parseExprWithParen(parsed, opTokIdx+1, nTokens)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, l.singleTokError(opTokIdx, "This operator needs an operand to the right")
+	}
+	return MultiAssign{
+		sourceLocation: l.makeLocation(0, nTokens-1),
+		Op:             op,
+		Targets:        targets,
+		Value:          value,
+	}, nil
+}
+
+// split [start, end) on the commas that are not inside brackets. The pieces are [start, end) as well.
+func (l *lineParse) splitOnCommas(start, end int) [][2]int {
+	var pieces [][2]int
+	depth := 0
+	pieceStart := start
+	for i := start; i < end; i++ {
+		switch l.tokens[i] {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		case ",":
+			if depth == 0 {
+				pieces = append(pieces, [2]int{pieceStart, i})
+				pieceStart = i + 1
+			}
+		}
+	}
+	return append(pieces, [2]int{pieceStart, end})
+}
+
+func (l *lineParse) parseExprList(parsed map[int]parsedNode, start, end int) ([]ASTNode, error) {
+	var nodes []ASTNode
+	for _, piece := range l.splitOnCommas(start, end) {
+		node, err := l.parseExprWithParen(parsed, piece[0], piece[1])
+		if err != nil {
+			return nil, err
+		}
+		if node == nil {
+			if piece[1] == len(l.tokens) {
+				return nil, l.singleTokError(piece[1]-1, "Expected a value after this")
+			}
+			return nil, l.singleTokError(piece[1], "Expected a value before this")
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
 func (l *lineParse) finishExprNode(node *ExprNode, opTokIdx int) error {
 	if node.Right == nil {
 		return l.singleTokError(opTokIdx, "This operator needs an operand to the right")
@@ -214,10 +446,11 @@ func (l *lineParse) finishExprNode(node *ExprNode, opTokIdx int) error {
 	}
 	if !unary {
 		// caller sets it in this case
+		node.line = node.Left.GetLineNumber()
 		node.startColumn = node.Left.GetStartColumn()
 	}
+	node.endLine = node.Right.GetEndLineNumber()
 	node.endColumn = node.Right.GetEndColumn()
-	node.line = l.lineNumber
 	return nil
 }
 
@@ -242,39 +475,166 @@ func (l *lineParse) parseInStructDeclContext() (ASTNode, error) {
 	return l.parseDecl(0, len(tokens))
 }
 
+// either "enum Name : u32 {" or the whole enum on one line
+func (l *lineParse) parseEnumDecl() (ASTNode, error) {
+	tokens := l.tokens
+	nTokens := len(tokens)
+	if nTokens < 5 || tokens[2] != ":" || tokens[4] != "{" {
+		return nil, l.errorFromTokIdx(0, nTokens-1, "Enums look like \"enum Name : u32 {\"")
+	}
+	if !tokenIsId(tokens[1]) {
+		return nil, l.singleTokError(1, invalidDeclNameMessage)
+	}
+	if !tokenIsId(tokens[3]) {
+		return nil, l.singleTokError(3, invalidDeclNameMessage)
+	}
+	decl := EnumDeclare{
+		sourceLocation: l.makeLocation(0, 4),
+		Name:           l.makeIdent(1),
+		Backing:        l.makeIdent(3),
+	}
+	if nTokens == 5 {
+		return decl, nil
+	}
+	if tokens[nTokens-1] != "}" {
+		return nil, l.errorFromTokIdx(5, nTokens-1, "Enum must end in \"}\" or continue on the next line")
+	}
+	members, err := l.parseEnumMembers(5, nTokens-1)
+	if err != nil {
+		return nil, err
+	}
+	decl.Members = members
+	return decl, nil
+}
+
+func (l *lineParse) parseInEnumContext() (ASTNode, error) {
+	tokens := l.tokens
+	if len(tokens) == 1 && tokens[0] == "}" {
+		return BlockEnd{l.singleTokSourceLocation(0)}, nil
+	}
+	members, err := l.parseEnumMembers(0, len(tokens))
+	if err != nil {
+		return nil, err
+	}
+	return EnumMembers{sourceLocation: l.makeLocation(0, len(tokens)-1), Members: members}, nil
+}
+
+// comma separated. A trailing comma is fine
+func (l *lineParse) parseEnumMembers(start, end int) ([]EnumMember, error) {
+	tokens := l.tokens
+	parsed := make(map[int]parsedNode)
+	var members []EnumMember
+	memberStart := start
+	for i := start; i <= end; i++ {
+		if i < end && tokens[i] != "," {
+			continue
+		}
+		if memberStart == i {
+			if i < end || memberStart == start {
+				return nil, l.singleTokError(i, enumMemberSyntaxMessage)
+			}
+			break
+		}
+		if !tokenIsId(tokens[memberStart]) {
+			return nil, l.singleTokError(memberStart, invalidDeclNameMessage)
+		}
+		member := EnumMember{sourceLocation: l.makeLocation(memberStart, i-1), Name: l.makeIdent(memberStart)}
+		if i-memberStart > 1 {
+			if tokens[memberStart+1] != "=" || i-memberStart == 2 {
+				return nil, l.errorFromTokIdx(memberStart, i-1, enumMemberSyntaxMessage)
+			}
+			value, err := l.parseExprWithParen(parsed, memberStart+2, i)
+			if err != nil {
+				return nil, err
+			}
+			member.Value = value
+		}
+		members = append(members, member)
+		memberStart = i + 1
+	}
+	return members, nil
+}
+
 func (l *lineParse) parseTypeDecl(start, end int) (TypeDecl, error) {
 	tokens := l.tokens
 	indirect := 0
 	var sizes []int
+	var sizeExprs []ASTNode
 	i := start
 	for i < end {
 		tok := tokens[i]
 		if tok == "*" {
 			indirect++
 		} else {
-			if i+2 < end && tok == "[" && tokens[i+2] == "]" {
-				arraySize, err := strconv.Atoi(tokens[i+1])
-				if err != nil {
-					return TypeDecl{}, l.errorFromTokIdx(i, i+2, "Array size must be an integer literal")
+			if closing := l.arraySizeEnd(i, end); closing != -1 {
+				var arraySize int
+				var sizeExpr ASTNode
+				value, _ := ParseIntLiteral(tokens[i+1])
+				if size, isInt := value.(int64); isInt && closing == i+2 {
+					arraySize = int(size)
+				} else {
+					var err error
+					sizeExpr, err = l.parseExprWithParen(make(map[int]parsedNode), i+1, closing)
+					if err != nil {
+						return TypeDecl{}, err
+					}
 				}
-				// three because that's the number of tokens [323] parses to
-				if i+3 >= end {
+				if closing+1 >= end {
 					return TypeDecl{}, l.errorFromTokIdx(i, end-1, "Arrays must contain some type")
 				}
+				if sizeExpr != nil && sizeExprs == nil {
+					sizeExprs = make([]ASTNode, len(sizes))
+				}
+				if sizeExprs != nil {
+					sizeExprs = append(sizeExprs, sizeExpr)
+				}
 				sizes = append(sizes, arraySize)
-				if tokens[i+3] == "[" {
-					i = i + 3
+				if tokens[closing+1] == "[" && l.arraySizeEnd(closing+1, end) != -1 {
+					i = closing + 1
 					continue
 				}
-				containedType, err := l.parseTypeDecl(i+3, end)
+				containedType, err := l.parseTypeDecl(closing+1, end)
 				if err != nil {
 					return TypeDecl{}, err
 				}
 				return TypeDecl{
 					LevelOfIndirection: indirect,
 					ArraySizes:         sizes,
+					ArraySizeExprs:     sizeExprs,
 					ArrayBase:          &containedType,
 				}, nil
+			} else if i+1 < end && tok == "proc" && tokens[i+1] == "(" {
+				procType, err := l.parseProcTypeDecl(i, end)
+				if err != nil {
+					return TypeDecl{}, err
+				}
+				return TypeDecl{LevelOfIndirection: indirect, Proc: procType}, nil
+			} else if i+1 < end && tok == "[" && tokens[i+1] == "]" {
+				if i+2 >= end {
+					return TypeDecl{}, l.errorFromTokIdx(i, end-1, "Slices must contain some type")
+				}
+				containedType, err := l.parseTypeDecl(i+2, end)
+				if err != nil {
+					return TypeDecl{}, err
+				}
+				return TypeDecl{LevelOfIndirection: indirect, SliceOf: &containedType}, nil
+			} else if i+1 < end && tokenIsId(tok) && tokens[i+1] == "(" {
+				// list(int)
+				if tokens[end-1] != ")" {
+					return TypeDecl{}, l.errorFromTokIdx(start, end-1, "This needs to be a type declaration")
+				}
+				var typeArgs []TypeDecl
+				for _, piece := range l.splitOnCommas(i+2, end-1) {
+					if piece[0] == piece[1] {
+						return TypeDecl{}, l.singleTokError(piece[1], "Expected a type before this")
+					}
+					arg, err := l.parseTypeDecl(piece[0], piece[1])
+					if err != nil {
+						return TypeDecl{}, err
+					}
+					typeArgs = append(typeArgs, arg)
+				}
+				return TypeDecl{LevelOfIndirection: indirect, Base: l.makeIdent(i), TypeArgs: typeArgs}, nil
 			} else if i != end-1 {
 				return TypeDecl{}, l.errorFromTokIdx(start, end-1, "This needs to be a type declaration")
 			}
@@ -288,6 +648,80 @@ func (l *lineParse) parseTypeDecl(start, end int) (TypeDecl, error) {
 	return TypeDecl{LevelOfIndirection: indirect, Base: l.makeIdent(end - 1)}, nil
 }
 
+// The index of the "]" that ends the size of an array type starting at open, -1 if the brackets
+// there don't hold an array size. The size can be any constant expression, as in [N * 2]int.
+func (l *lineParse) arraySizeEnd(open, end int) int {
+	tokens := l.tokens
+	if tokens[open] != "[" || open+1 >= end || tokens[open+1] == "]" {
+		return -1
+	}
+	depth := 0
+	for i := open; i < end; i++ {
+		switch tokens[i] {
+		case "(", "[":
+			depth++
+		case ")", "]":
+			depth--
+			if depth == 0 {
+				if tokens[i] != "]" {
+					return -1
+				}
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// proc(int, *u8) -> bool
+func (l *lineParse) parseProcTypeDecl(start, end int) (*ProcTypeDecl, error) {
+	tokens := l.tokens
+	closing := -1
+	depth := 0
+	for i := start + 1; i < end && closing == -1; i++ {
+		switch tokens[i] {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				closing = i
+			}
+		}
+	}
+	if closing == -1 {
+		return nil, l.errorFromTokIdx(start, end-1, "Unclosed list of argument types")
+	}
+	var procType ProcTypeDecl
+	if closing != start+2 {
+		for _, piece := range l.splitOnCommas(start+2, closing) {
+			if piece[0] == piece[1] {
+				return nil, l.singleTokError(piece[1], "Expected a type before this")
+			}
+			arg, err := l.parseTypeDecl(piece[0], piece[1])
+			if err != nil {
+				return nil, err
+			}
+			procType.Args = append(procType.Args, arg)
+		}
+	}
+	procType.Return = TypeDecl{Base: IdName{Name: "void"}}
+	if closing+1 < end {
+		if tokens[closing+1] != "->" {
+			return nil, l.errorFromTokIdx(start, end-1, "This needs to be a type declaration")
+		}
+		if closing+2 >= end {
+			return nil, l.singleTokError(closing+1, "A return type should come after this")
+		}
+		returnType, err := l.parseTypeDecl(closing+2, end)
+		if err != nil {
+			return nil, err
+		}
+		procType.Return = returnType
+	}
+	return &procType, nil
+}
+
 func (l *lineParse) genParenInfo(start int, end int) ([]bracketInfo, error) {
 	tokens := l.tokens
 	parenInfo := make([]bracketInfo, 0)
@@ -296,9 +730,14 @@ func (l *lineParse) genParenInfo(start int, end int) ([]bracketInfo, error) {
 	for i < end {
 		tok := tokens[i]
 		switch tok {
+		case "{":
+			// a "{" at the end opens a block rather than a literal
+			if i != end-1 {
+				openStack = append(openStack, i)
+			}
 		case "(", "[":
 			openStack = append(openStack, i)
-		case ")", "]":
+		case ")", "]", "}":
 			if len(openStack) == 0 {
 				return nil, l.singleTokError(i, fmt.Sprintf(`unmatched "%s"`, tok))
 			}
@@ -308,9 +747,15 @@ func (l *lineParse) genParenInfo(start int, end int) ([]bracketInfo, error) {
 			if tok == "]" && tokens[openStack[len(openStack)-1]] != "[" {
 				return nil, l.singleTokError(i, `unmatched "]"`)
 			}
+			if tok == "}" && tokens[openStack[len(openStack)-1]] != "{" {
+				return nil, l.singleTokError(i, `unmatched "}"`)
+			}
 			kind := round
-			if tok == "]" {
+			switch tok {
+			case "]":
 				kind = square
+			case "}":
+				kind = curly
 			}
 			parenInfo = append(parenInfo, bracketInfo{kind, openStack[len(openStack)-1], i})
 			openStack = openStack[:len(openStack)-1]
@@ -333,8 +778,93 @@ func (l *lineParse) parseExprWithParen(parsed map[int]parsedNode, start, end int
 		return nil, err
 	}
 
+	// the square brackets in the type of an array literal don't make an expression
+	arrayTypeStarts := make(map[int]int)
+	inArrayType := func(paren bracketInfo) bool {
+		for curlyOpen, typeStart := range arrayTypeStarts {
+			if paren.open >= typeStart && paren.open < curlyOpen {
+				return true
+			}
+		}
+		return false
+	}
+	for _, paren := range parenInfo {
+		if paren.kind == curly {
+			if typeStart := l.arrayTypeStart(start, paren.open); typeStart != -1 {
+				arrayTypeStarts[paren.open] = typeStart
+			}
+		}
+	}
+
+	// the argument types of a proc expression can have brackets of their own
+	var procArgLists []bracketInfo
 	for _, paren := range parenInfo {
-		if paren.kind == square {
+		if paren.kind == round && paren.open-1 >= start && tokens[paren.open-1] == "proc" {
+			procArgLists = append(procArgLists, paren)
+		}
+	}
+	inProcArgList := func(paren bracketInfo) bool {
+		for _, list := range procArgLists {
+			if paren.open > list.open && paren.end < list.end {
+				return true
+			}
+		}
+		return false
+	}
+
+	// the arguments of calls and the values of literals are parsed from scratch when the call or the
+	// literal is parsed. Brackets inside are left to that since parsing twice trips over the first results.
+	var argLists []bracketInfo
+	for _, paren := range parenInfo {
+		if paren.kind == curly {
+			argLists = append(argLists, paren)
+		} else if paren.kind == round && paren.open-1 >= start {
+			before := tokens[paren.open-1]
+			if before == "]" || before == ")" || (tokenIsId(before) && before != "proc") {
+				argLists = append(argLists, paren)
+			}
+		}
+	}
+	inArgList := func(paren bracketInfo) bool {
+		for _, list := range argLists {
+			if paren.open > list.open && paren.end < list.end {
+				return true
+			}
+		}
+		return false
+	}
+
+	procExprEnd := -1
+	for _, paren := range parenInfo {
+		if paren.open < procExprEnd || inProcArgList(paren) {
+			// part of the types of a proc expression
+			continue
+		}
+		if inArgList(paren) {
+			continue
+		}
+		if paren.kind == curly {
+			if typeStart, isArray := arrayTypeStarts[paren.open]; isArray {
+				literal, err := l.parseArrayLiteral(parsed, typeStart, paren)
+				if err != nil {
+					return nil, err
+				}
+				parsed[typeStart] = parsedNode{*literal, paren.end}
+				parsed[paren.end] = parsedNode{*literal, typeStart}
+				continue
+			}
+			if paren.open-1 < start || !tokenIsId(tokens[paren.open-1]) {
+				return nil, l.singleTokError(paren.open, "Expected the name of a struct before this")
+			}
+			literal, err := l.parseStructLiteral(parsed, paren)
+			if err != nil {
+				return nil, err
+			}
+			parsed[paren.open-1] = parsedNode{*literal, paren.end}
+			parsed[paren.end] = parsedNode{*literal, paren.open - 1}
+		} else if paren.kind == square && inArrayType(paren) {
+			continue
+		} else if paren.kind == square {
 			if paren.open == start {
 				panic("ice: asked to parse an expression that starts with [. What?")
 			}
@@ -361,10 +891,15 @@ func (l *lineParse) parseExprWithParen(parsed map[int]parsedNode, start, end int
 					parsedStart = paren.open - 2
 				}
 				parsedEnd = afterProcExpr
-				proc.line = l.lineNumber
-				proc.startColumn = parsedStart
-				proc.endColumn = afterProcExpr - 1
+				procExprEnd = afterProcExpr
+				proc.sourceLocation = l.makeLocation(parsedStart, afterProcExpr-1)
 				node = *proc
+			} else if tokens[paren.open-1] == "cast" {
+				cast, err := l.parseCast(parsed, paren)
+				if err != nil {
+					return nil, err
+				}
+				node = *cast
 			} else {
 				call, err := l.parseCallList(parsed, paren)
 				if err != nil {
@@ -374,6 +909,14 @@ func (l *lineParse) parseExprWithParen(parsed map[int]parsedNode, start, end int
 			}
 			parsed[parsedStart] = parsedNode{node, parsedEnd}
 			parsed[parsedEnd] = parsedNode{node, parsedStart}
+		} else if paren.kind == round && paren.open-1 >= start && (tokens[paren.open-1] == "]" || tokens[paren.open-1] == ")") {
+			// calling the value of the expression before. The expression is the left of a Call operator.
+			call, err := l.parseArgList(parsed, paren)
+			if err != nil {
+				return nil, err
+			}
+			parsed[paren.open] = parsedNode{*call, paren.end}
+			parsed[paren.end] = parsedNode{*call, paren.open}
 		} else {
 			loc := l.makeLocation(paren.open, paren.end)
 			node, err := l.parseExprUnit(parsed, paren.open+1, paren.end, &loc)
@@ -405,14 +948,28 @@ func (l *lineParse) parseExprUnit(parsed map[int]parsedNode, start, end int, loc
 	heap.Init(&ops)
 
 	i := start
+	afterOperand := false
 	for i < end {
 		parsed, found := parsed[i]
 		tok := tokens[i]
-		if found && tok != "[" {
+		if found && tok == "(" && i > start && (tokens[i-1] == "]" || tokens[i-1] == ")") {
+			// arguments to a call to the operand before
+			heap.Push(&ops, opToken{i, Call})
 			i = parsed.otherEnd + 1
 			continue
 		}
+		// the "[" that starts an array literal is an operand. Others are array accesses.
+		if found && (tok != "[" || tokens[parsed.otherEnd] == "}") {
+			i = parsed.otherEnd + 1
+			afterOperand = true
+			continue
+		}
 		op, good := tokToOp[tok]
+		if good && op == AddressOf && afterOperand {
+			// "&" right after an operand is a bitwise and rather than taking the address
+			op = BitAnd
+		}
+		afterOperand = !good
 		if good {
 			if _, hasPrecendence := precedence[op]; !hasPrecendence {
 				println(op.String())
@@ -429,11 +986,28 @@ func (l *lineParse) parseExprUnit(parsed map[int]parsedNode, start, end int, loc
 				panic("ice: stuff inside [] should always be parsed already")
 			}
 			i = parsed.otherEnd + 1
+			afterOperand = true
 		}
 	}
 	var lastNode *ExprNode
 	for len(ops) > 0 {
 		opTok := heap.Pop(&ops).(opToken)
+		if opTok.op == Call {
+			left, leftParsed := parsed[opTok.index-1]
+			args := parsed[opTok.index]
+			if !leftParsed {
+				panic("ice: the callee of a call to an expression should be parsed")
+			}
+			call := args.node.(ProcCall)
+			call.Target = left.node
+			call.line = left.node.GetLineNumber()
+			call.startColumn = left.node.GetStartColumn()
+			parsed[left.otherEnd] = parsedNode{call, args.otherEnd}
+			parsed[args.otherEnd] = parsedNode{call, left.otherEnd}
+			// the call isn't an ExprNode so it can't take a location override
+			lastNode = nil
+			continue
+		}
 		leftI := opTok.index - 1
 		rightI := opTok.index + 1
 		unaryOp := isUnary[opTok.op]
@@ -478,6 +1052,7 @@ func (l *lineParse) parseExprUnit(parsed map[int]parsedNode, start, end int, loc
 			return nil, err
 		}
 		if unaryOp {
+			newNode.line = l.lines[leftI]
 			newNode.startColumn = l.startOfTok(leftI)
 		}
 		lastNode = &newNode
@@ -517,11 +1092,19 @@ func (l *lineParse) parseToken(tokIdx int, locationOverride *sourceLocation) AST
 	}
 	switch {
 	case unicode.IsDigit(rune(token[0])) || token[0] == '-':
+		if strings.ContainsRune(token, '.') {
+			return Literal{loc, Float, token}
+		}
 		return Literal{loc, Number, token}
 	case token == "true" || token == "false":
 		return Literal{loc, Boolean, token}
 	case token[0] == '"':
-		return Literal{loc, String, token[1 : len(token)-1]}
+		// the tokenizer already rejected bad escapes
+		value, _ := unescape(token, 1, len(token)-1)
+		return Literal{loc, String, value}
+	case token[0] == '\'':
+		value, _ := unescape(token, 1, len(token)-1)
+		return Literal{loc, Char, value}
 	case token == "nil":
 		return Literal{sourceLocation: loc, Type: NilPtr}
 	case tokenIsId(token):
@@ -531,35 +1114,211 @@ func (l *lineParse) parseToken(tokIdx int, locationOverride *sourceLocation) AST
 }
 
 func (l *lineParse) parseCallList(parsed map[int]parsedNode, paren bracketInfo) (*ProcCall, error) {
-	tokens := l.tokens
-	i := paren.open + 1
+	call, err := l.parseArgList(parsed, paren)
+	if err != nil {
+		return nil, err
+	}
+	// caller checks whether this is valid ident
+	call.Callee = l.makeIdent(paren.open - 1)
+	call.sourceLocation = l.makeLocation(paren.open-1, paren.end)
+	return call, nil
+}
 
+// A call with only the arguments filled in
+func (l *lineParse) parseArgList(parsed map[int]parsedNode, paren bracketInfo) (*ProcCall, error) {
 	args := make([]ASTNode, 0)
-	for j := i; j <= paren.end; j++ {
-		if paren.open+1 == paren.end { // call with no arguments
+	if paren.open+1 != paren.end { // call with arguments
+		var err error
+		args, err = l.parseExprList(parsed, paren.open+1, paren.end)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &ProcCall{sourceLocation: l.makeLocation(paren.open, paren.end), Args: args}, nil
+}
+
+// The index of the "step" in a loop header, -1 if there isn't one. A "step" that comes right after an
+// operator is a variable, as in 0..step
+func (l *lineParse) findStepClause(start, end int) int {
+	depth := 0
+	for i := start; i < end; i++ {
+		switch l.tokens[i] {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		case "step":
+			if depth == 0 && i > start && !tokenIsOperator(l.tokens[i-1]) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// The index of the "in" in for x in arr, -1 if the loop header isn't like that.
+// The names before "in" look like x, &x, i, x or i, &x
+func (l *lineParse) findForEachIn(end int) int {
+	depth := 0
+	for i := 1; i < end; i++ {
+		switch l.tokens[i] {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		case "in":
+			if depth == 0 && i > 1 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func (l *lineParse) parseForEachNames(loop *Loop, inIdx int) error {
+	tokens := l.tokens
+	pieces := l.splitOnCommas(1, inIdx)
+	if len(pieces) > 2 {
+		return l.errorFromTokIdx(1, inIdx-1, "Expected at most an index and an element before \"in\"")
+	}
+	if len(pieces) == 2 {
+		index := pieces[0]
+		if index[1]-index[0] != 1 || !tokenIsId(tokens[index[0]]) {
+			return l.singleTokError(index[0], "Expected the name of the index")
+		}
+		loop.Index = l.makeIdent(index[0])
+	}
+	element := pieces[len(pieces)-1]
+	nameIdx := element[0]
+	if tokens[nameIdx] == "&" {
+		loop.ByPointer = true
+		nameIdx++
+	}
+	if nameIdx != element[1]-1 || !tokenIsId(tokens[nameIdx]) {
+		return l.singleTokError(element[0], "Expected the name of the element")
+	}
+	loop.Element = l.makeIdent(nameIdx)
+	return nil
+}
+
+// cast(T, value). The type isn't an expression so it's parsed on its own
+func (l *lineParse) parseCast(parsed map[int]parsedNode, paren bracketInfo) (*Cast, error) {
+	pieces := l.splitOnCommas(paren.open+1, paren.end)
+	if len(pieces) != 2 || pieces[0][0] == pieces[0][1] {
+		return nil, l.errorFromTokIdx(paren.open-1, paren.end, "A cast looks like cast(type, value)")
+	}
+	typeDecl, err := l.parseTypeDecl(pieces[0][0], pieces[0][1])
+	if err != nil {
+		return nil, err
+	}
+	value, err := l.parseExprList(parsed, pieces[1][0], pieces[1][1])
+	if err != nil {
+		return nil, err
+	}
+	return &Cast{sourceLocation: l.makeLocation(paren.open-1, paren.end), Type: typeDecl, Value: value[0]}, nil
+}
+
+// The index of the "[" that starts the type of an array literal, -1 when the "{" doesn't start one.
+// The type looks like [3]int or [2][N]*u8
+func (l *lineParse) arrayTypeStart(start, curlyOpen int) int {
+	tokens := l.tokens
+	i := curlyOpen - 1
+	if i < start || !tokenIsId(tokens[i]) {
+		return -1
+	}
+	i--
+	for i >= start && tokens[i] == "*" {
+		i--
+	}
+	typeStart := -1
+	for i >= start && tokens[i] == "]" {
+		open := i - 1
+		for depth := 1; open >= start; open-- {
+			if tokens[open] == "]" || tokens[open] == ")" {
+				depth++
+			} else if tokens[open] == "[" || tokens[open] == "(" {
+				depth--
+			}
+			if depth == 0 {
+				break
+			}
+		}
+		if open < start || tokens[open] != "[" || open+1 == i {
 			break
 		}
-		parsedInfo, found := parsed[i]
-		if found {
-			args = append(args, parsedInfo.node)
-			i = parsedInfo.otherEnd + 2 // +1 would step on ","
-			j = i
-			continue
+		typeStart = open
+		i = open - 1
+	}
+	return typeStart
+}
+
+// [3]int{1, 2, 3}
+func (l *lineParse) parseArrayLiteral(parsed map[int]parsedNode, typeStart int, paren bracketInfo) (*ArrayLiteral, error) {
+	typeDecl, err := l.parseTypeDecl(typeStart, paren.open)
+	if err != nil {
+		return nil, err
+	}
+	literal := ArrayLiteral{
+		sourceLocation: l.makeLocation(typeStart, paren.end),
+		Type:           typeDecl,
+	}
+	if paren.open+1 == paren.end {
+		return &literal, nil
+	}
+	end := paren.end
+	if l.tokens[end-1] == "," && end-1 > paren.open+1 {
+		// trailing comma
+		end--
+	}
+	literal.Values, err = l.parseExprList(parsed, paren.open+1, end)
+	if err != nil {
+		return nil, err
+	}
+	return &literal, nil
+}
+
+// point{x = 1, y = 2} or point{1, 2}
+func (l *lineParse) parseStructLiteral(parsed map[int]parsedNode, paren bracketInfo) (*StructLiteral, error) {
+	tokens := l.tokens
+	literal := StructLiteral{
+		sourceLocation: l.makeLocation(paren.open-1, paren.end),
+		Type:           l.makeIdent(paren.open - 1),
+	}
+	if paren.open+1 == paren.end {
+		return &literal, nil
+	}
+	pieces := l.splitOnCommas(paren.open+1, paren.end)
+	if last := pieces[len(pieces)-1]; last[0] == last[1] && len(pieces) > 1 {
+		// trailing comma
+		pieces = pieces[:len(pieces)-1]
+	}
+	named := pieces[0][1]-pieces[0][0] > 1 && tokens[pieces[0][0]+1] == "="
+	for _, piece := range pieces {
+		if piece[0] == piece[1] {
+			return nil, l.singleTokError(piece[1], "Expected a value before this")
 		}
-		tok := tokens[j]
-		if tok == "," || j == paren.end {
-			node, err := l.parseExprWithParen(parsed, i, j)
-			if err != nil {
-				return nil, err
+		pieceIsNamed := piece[1]-piece[0] > 1 && tokens[piece[0]+1] == "="
+		if pieceIsNamed != named {
+			return nil, l.errorFromTokIdx(piece[0], piece[1]-1, "Can't mix named and positional fields")
+		}
+		valueStart := piece[0]
+		if named {
+			if !tokenIsId(tokens[piece[0]]) {
+				return nil, l.singleTokError(piece[0], invalidDeclNameMessage)
 			}
-			i = j + 1
-			args = append(args, node)
+			literal.Names = append(literal.Names, l.makeIdent(piece[0]))
+			valueStart += 2
+		}
+		value, err := l.parseExprWithParen(parsed, valueStart, piece[1])
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			return nil, l.singleTokError(piece[0]+1, "Expected a value after this")
 		}
+		literal.Values = append(literal.Values, value)
 	}
-	// caller checks whether this is valid ident
-	idNode := l.makeIdent(paren.open - 1)
-	loc := l.makeLocation(paren.open-1, paren.end)
-	return &ProcCall{sourceLocation: loc, Callee: idNode, Args: args}, nil
+	return &literal, nil
 }
 
 func (l *lineParse) parseProcExpr(parsed map[int]parsedNode, paren bracketInfo, requireBlock bool) (*ProcDecl, int, error) {
@@ -581,45 +1340,65 @@ func (l *lineParse) parseProcExpr(parsed map[int]parsedNode, paren bracketInfo,
 	i := paren.open + 1
 
 	var args []Declaration
-	leftBoundary := i
-	for j := i; j <= paren.end; j++ {
-		tok := tokens[j]
-		if paren.open+1 == paren.end { // no arguments
-			break
-		}
-		tokIsComma := tok == ","
-		if tokIsComma || j == paren.end {
-			var decl Declaration
-			if j-leftBoundary == 1 {
-				return nil, 0, l.singleTokError(j-1, `This should be a type declaration`)
-			} else {
-				parsed, err := l.parseDecl(leftBoundary, j)
-				if err != nil {
-					return nil, 0, err
+	var typeParams []IdName
+	if paren.open+1 != paren.end { // no arguments otherwise
+		for _, piece := range l.splitOnCommas(i, paren.end) {
+			if tokens[piece[0]] == "$" {
+				// $T type
+				if piece[1]-piece[0] != 3 || !tokenIsId(tokens[piece[0]+1]) || tokens[piece[0]+2] != "type" {
+					return nil, 0, l.errorFromTokIdx(piece[0], piece[1]-1, `Type parameters look like $T type`)
 				}
-				decl = parsed.(Declaration)
+				if len(args) > 0 {
+					return nil, 0, l.errorFromTokIdx(piece[0], piece[1]-1, "Type parameters must come before the other parameters")
+				}
+				typeParams = append(typeParams, l.makeIdent(piece[0]+1))
+				continue
 			}
-			args = append(args, decl)
-		}
-		if tokIsComma {
-			leftBoundary = j + 1
+			if piece[1]-piece[0] == 1 {
+				return nil, 0, l.singleTokError(piece[1]-1, `This should be a type declaration`)
+			}
+			parsed, err := l.parseDecl(piece[0], piece[1])
+			if err != nil {
+				return nil, 0, err
+			}
+			args = append(args, parsed.(Declaration))
 		}
 	}
 	returnType := TypeDecl{Base: IdName{Name: "void"}}
+	var extraReturns []TypeDecl
 	if paren.end+1 < len(tokens) && tokens[paren.end+1] == "->" {
 		if paren.end+2 >= len(tokens) || tokens[paren.end+2] == "{" {
 			return nil, 0, l.errorFromTokIdx(paren.end+1, paren.end+1, "A return type should come after this")
 		}
-		var err error
-		returnType, err = l.parseTypeDecl(paren.end+2, declEnd)
-		if err != nil {
-			return nil, 0, err
+		typesStart := paren.end + 2
+		typesEnd := declEnd
+		if tokens[typesStart] == "(" {
+			// -> (int, bool)
+			if tokens[declEnd-1] != ")" {
+				return nil, 0, l.errorFromTokIdx(typesStart, declEnd-1, "Unclosed list of return types")
+			}
+			typesStart++
+			typesEnd--
+		}
+		for i, piece := range l.splitOnCommas(typesStart, typesEnd) {
+			if piece[0] == piece[1] {
+				return nil, 0, l.errorFromTokIdx(paren.end+1, declEnd-1, "Missing a return type")
+			}
+			typeDecl, err := l.parseTypeDecl(piece[0], piece[1])
+			if err != nil {
+				return nil, 0, err
+			}
+			if i == 0 {
+				returnType = typeDecl
+			} else {
+				extraReturns = append(extraReturns, typeDecl)
+			}
 		}
 	}
 	if !requireBlock {
 		declEnd = len(tokens) - 1
 	}
-	return &ProcDecl{Return: returnType, Args: args}, declEnd, nil
+	return &ProcDecl{Return: returnType, ExtraReturns: extraReturns, Args: args, TypeParams: typeParams}, declEnd, nil
 }
 
 func tokenIsOperator(token string) bool {
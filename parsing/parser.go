@@ -13,11 +13,15 @@ type Parser struct {
 	OutBuffer       []statement
 	incompleteStack []*ASTNode
 	contextStack    []parsingContext
+	// a statement that continues on the next line
+	pending   *lineParse
+	tokenizer Tokenizer
 }
 
 const (
 	globalContext parsingContext = iota + 1
 	structContext
+	enumContext
 )
 
 type statement struct {
@@ -31,7 +35,10 @@ func (p *Parser) FeedLine(line string, lineNumber int) (int, error) {
 	err := p.processLine(line, lineNumber)
 	if err != nil {
 		if userError, isUserError := err.(*errors.UserError); isUserError {
-			userError.Line = lineNumber
+			if userError.Line == -1 {
+				userError.Line = lineNumber
+				userError.EndLine = lineNumber
+			}
 			return 0, userError
 		}
 		return 0, err
@@ -39,64 +46,186 @@ func (p *Parser) FeedLine(line string, lineNumber int) (int, error) {
 	return len(p.OutBuffer) - before, nil
 }
 
-func (p *Parser) currentContext() parsingContext {
-	return p.contextStack[len(p.contextStack)-1]
+// Finish reports the block comment or the statement left unfinished at the end of the input, if there is one
+func (p *Parser) Finish() error {
+	if err := p.tokenizer.Finish(); err != nil {
+		p.pending = nil
+		return err
+	}
+	return p.finishStatement()
 }
 
-func (p *Parser) processLine(line string, lineNumber int) error {
-	var parent *ASTNode
-	getParent := func() *ASTNode {
-		l := len(p.incompleteStack)
-		if l >= 1 {
-			return p.incompleteStack[l-1]
-		}
+func (p *Parser) finishStatement() error {
+	if p.pending == nil {
 		return nil
 	}
-	addOne := func(isComplete bool, nodePtr *ASTNode, parent *ASTNode) {
-		p.OutBuffer = append(p.OutBuffer, statement{isComplete, nodePtr, parent})
+	pending := p.pending
+	p.pending = nil
+	_, err := pending.parseInStatementContext()
+	if err == nil {
+		err = pending.errorFromTokIdx(0, len(pending.tokens)-1, "Unfinished statement")
 	}
-	startNewBlock := func(node *ASTNode) {
-		parent = getParent()
-		p.incompleteStack = append(p.incompleteStack, node)
+	return err
+}
+
+// keywords that can only start a statement. Seeing one means the statement on the line before is done.
+var statementKeywords = map[string]bool{
+	"if": true, "else": true, "for": true, "switch": true, "case": true, "default": true, "return": true,
+	"break": true, "continue": true, "defer": true, "var": true, "struct": true, "union": true, "enum": true,
+	"}": true,
+}
+
+// a statement continues on the next line when it has unclosed brackets or ends in a comma or a binary operator
+func continuesOnNextLine(tokens []string) bool {
+	depth := 0
+	for _, tok := range tokens {
+		switch tok {
+		case "(", "[":
+			depth++
+		case ")", "]":
+			depth--
+		}
+	}
+	if depth > 0 {
+		return true
 	}
-	tokens, indices, err := Tokenize(line)
+	last := tokens[len(tokens)-1]
+	_, isOperator := tokToOp[last]
+	return last == "," || (isOperator && last != "@" && last != "!" && last != "~")
+}
+
+func (p *Parser) currentContext() parsingContext {
+	return p.contextStack[len(p.contextStack)-1]
+}
+
+func (p *Parser) processLine(line string, lineNumber int) error {
+	tokens, indices, err := p.tokenizer.Tokenize(line, lineNumber)
 	if err != nil {
+		p.pending = nil
 		return err
 	}
 	// fmt.Printf("%#v\n", tokens) // Dump(tokens)
 	if len(tokens) == 0 {
 		return nil
 	}
-	if tokens[0] == "//" {
-		return nil
+	lines := make([]int, len(tokens))
+	for i := range lines {
+		lines[i] = lineNumber
 	}
 	lp := lineParse{
-		tokens:     tokens,
-		indices:    indices,
-		lineNumber: lineNumber,
+		tokens:  tokens,
+		indices: indices,
+		lines:   lines,
 	}
 	var n ASTNode
-	if p.currentContext() == structContext {
+	switch p.currentContext() {
+	case structContext:
 		n, err = lp.parseInStructDeclContext()
-	} else {
-		n, err = lp.parseInStatementContext()
+	case enumContext:
+		n, err = lp.parseInEnumContext()
+	default:
+		if p.pending != nil && statementKeywords[tokens[0]] {
+			// the statement before was never finished. This line still goes in so the blocks stay balanced
+			err := p.finishStatement()
+			p.feedStatement(lp)
+			return err
+		}
+		if p.pending != nil {
+			pending := p.pending
+			p.pending = nil
+			lp = lineParse{
+				tokens:  append(pending.tokens, tokens...),
+				indices: append(pending.indices, indices...),
+				lines:   append(pending.lines, lines...),
+			}
+		}
+		return p.feedStatement(lp)
 	}
 	if err != nil {
 		return err
 	}
+	return p.placeNode(lp, n)
+}
+
+// parse a statement and place it in the output, unless it continues on the next line
+func (p *Parser) feedStatement(lp lineParse) error {
+	if continuesOnNextLine(lp.tokens) {
+		p.pending = &lp
+		return nil
+	}
+	n, err := lp.parseInStatementContext()
+	if err != nil {
+		if lp.tokens[len(lp.tokens)-1] == "{" && lp.tokens[0] != "}" {
+			// the line that opens a block didn't parse. Stand in for it so the closing brace of the block
+			// doesn't show up as unmatched.
+			var unparsed ASTNode
+			p.incompleteStack = append(p.incompleteStack, &unparsed)
+		}
+		return err
+	}
+	return p.placeNode(lp, n)
+}
+
+func (p *Parser) placeNode(lp lineParse, n ASTNode) error {
+	var parent *ASTNode
+	getParent := func() *ASTNode {
+		l := len(p.incompleteStack)
+		if l >= 1 {
+			return p.incompleteStack[l-1]
+		}
+		return nil
+	}
+	addOne := func(isComplete bool, nodePtr *ASTNode, parent *ASTNode) {
+		p.OutBuffer = append(p.OutBuffer, statement{isComplete, nodePtr, parent})
+	}
+	startNewBlock := func(node *ASTNode) {
+		parent = getParent()
+		p.incompleteStack = append(p.incompleteStack, node)
+	}
+	tokens := lp.tokens
 	// fmt.Printf("Line \"%s\" gave:\n", line)
 	// Dump(n)
+	if top := getParent(); top != nil {
+		_, inSwitch := (*top).(SwitchNode)
+		_, isCase := n.(CaseNode)
+		_, isEnd := n.(BlockEnd)
+		if inSwitch && !isCase && !isEnd {
+			return lp.errorFromTokIdx(0, len(tokens)-1, "Statements in a switch must be under a case")
+		}
+	}
 	switch t := n.(type) {
 	case ExprNode:
 		if t.Op == ConstDeclare {
-			_, good := t.Right.(ProcDecl)
-			if good {
+			// foreign procs don't have a body
+			procDecl, good := t.Right.(ProcDecl)
+			if good && !procDecl.IsForeign {
 				startNewBlock(&n)
 				addOne(false, &n, parent)
 				return nil
 			}
 		}
-	case IfNode, Loop:
+	case IfNode, Loop, SwitchNode:
+		startNewBlock(&n)
+		addOne(false, &n, parent)
+		return nil
+	case CaseNode:
+		// each case ends the one before it
+		top := getParent()
+		if top != nil {
+			if _, topIsCase := (*top).(CaseNode); topIsCase {
+				p.incompleteStack = p.incompleteStack[:len(p.incompleteStack)-1]
+				var end ASTNode
+				end = BlockEnd{lp.singleTokSourceLocation(0)}
+				addOne(true, &end, top)
+				top = getParent()
+			}
+		}
+		if top == nil {
+			return lp.errorFromTokIdx(0, len(tokens)-1, "case outside of a switch")
+		}
+		if _, inSwitch := (*top).(SwitchNode); !inSwitch {
+			return lp.errorFromTokIdx(0, len(tokens)-1, "case outside of a switch")
+		}
 		startNewBlock(&n)
 		addOne(false, &n, parent)
 		return nil
@@ -120,6 +249,13 @@ func (p *Parser) processLine(line string, lineNumber int) error {
 		addOne(false, &n, parent)
 		startNewBlock(&n)
 		return nil
+	case EnumDeclare:
+		if tokens[len(tokens)-1] == "{" {
+			p.contextStack = append(p.contextStack, enumContext)
+			addOne(false, &n, parent)
+			startNewBlock(&n)
+			return nil
+		}
 	case Declaration:
 	case BlockEnd:
 		l := len(p.incompleteStack)
@@ -128,8 +264,16 @@ func (p *Parser) processLine(line string, lineNumber int) error {
 		}
 		top := p.incompleteStack[l-1]
 		p.incompleteStack = p.incompleteStack[:l-1]
-		if _, parentIsStruct := (*top).(StructDeclare); parentIsStruct {
+		switch (*top).(type) {
+		case StructDeclare, EnumDeclare:
 			p.contextStack = p.contextStack[:len(p.contextStack)-1]
+		case CaseNode:
+			// the brace closes both the last case and the switch
+			var caseEnd ASTNode
+			caseEnd = BlockEnd{lp.singleTokSourceLocation(0)}
+			addOne(true, &caseEnd, top)
+			top = p.incompleteStack[l-2]
+			p.incompleteStack = p.incompleteStack[:l-2]
 		}
 		addOne(true, &n, top)
 		return nil
@@ -2,18 +2,29 @@ package parsing
 
 import (
 	"github.com/XrXr/alang/errors"
+	"github.com/XrXr/alang/parsing/fsm"
+	"math"
+	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 // since the match happens from top to bottom, longer ones should come first
 var bounderies = [...]string{
-	"//",
+	"<<=",
+	">>=",
 	"->",
 	"+=",
 	"-=",
+	"&=",
+	"|=",
+	"^=",
+	"%=",
 	"==",
 	"+",
+	"<<",
+	">>",
 	"<=",
 	"<",
 	">=",
@@ -22,6 +33,8 @@ var bounderies = [...]string{
 	"||",
 	":=",
 	"::",
+	":",
+	"..<",
 	"..",
 	".",
 	">",
@@ -34,6 +47,7 @@ var bounderies = [...]string{
 	"%",
 	"$",
 	"|",
+	"~",
 	"@",
 	"(",
 	")",
@@ -46,7 +60,40 @@ var bounderies = [...]string{
 	"}",
 }
 
+// Tokenizer splits source lines into tokens. Block comments can span multiple lines, so feed it
+// every line in order.
+type Tokenizer struct {
+	// Comments come out as tokens that start with "//" or "/*" when this is set. A block comment that
+	// spans multiple lines gives one token per line.
+	KeepComments bool
+	commentDepth int
+	// where the outermost unfinished block comment starts
+	commentLine   int
+	commentColumn int
+}
+
+// Tokenize a single line that is not in a block comment
 func Tokenize(in string) ([]string, []int, error) {
+	var t Tokenizer
+	return t.Tokenize(in, 0)
+}
+
+// Finish reports a block comment that is still open at the end of the input
+func (t *Tokenizer) Finish() error {
+	if t.commentDepth == 0 {
+		return nil
+	}
+	t.commentDepth = 0
+	return &errors.UserError{
+		Line:        t.commentLine,
+		StartColumn: t.commentColumn,
+		EndLine:     t.commentLine,
+		EndColumn:   t.commentColumn + 1,
+		Message:     "Unterminated block comment",
+	}
+}
+
+func (t *Tokenizer) Tokenize(in string, lineNumber int) ([]string, []int, error) {
 	if in[len(in)-1] != '\n' {
 		in = in + "\n"
 	}
@@ -61,6 +108,29 @@ func Tokenize(in string) ([]string, []int, error) {
 		startNewToken = true
 		i = end
 	}
+	// go to the end of the block comment, or the end of the line if the comment doesn't end on this line.
+	// Block comments nest.
+	skipBlockComment := func(commentStart int, scanStart int) {
+		k := scanStart
+		for t.commentDepth > 0 && in[k] != '\n' {
+			switch {
+			case strings.HasPrefix(in[k:], "/*"):
+				t.commentDepth++
+				k += 2
+			case strings.HasPrefix(in[k:], "*/"):
+				t.commentDepth--
+				k += 2
+			default:
+				k++
+			}
+		}
+		if t.KeepComments {
+			addToken(commentStart, k)
+		} else {
+			startNewToken = true
+			i = k
+		}
+	}
 tokenize:
 	for {
 		if startNewToken {
@@ -71,6 +141,10 @@ tokenize:
 		if i >= len(in) {
 			break
 		}
+		if t.commentDepth > 0 {
+			skipBlockComment(i, i)
+			continue
+		}
 		char := in[i]
 		if char == '\n' {
 			addToken(tokenStart, i)
@@ -81,29 +155,67 @@ tokenize:
 			continue
 		}
 		if i == tokenStart {
-			if char == '-' && isDigit(safeCharAt(in, i+1)) {
-				numLitEnd := iAfterNumLiteral(in, i+1)
+			if isDigit(char) || (char == '-' && isDigit(safeCharAt(in, i+1))) {
+				numStart := i
+				if char == '-' {
+					numStart++
+				}
+				numLitEnd := iAfterNumLiteral(in, numStart)
+				if numLitEnd < len(in) && isIdChar(in[numLitEnd]) {
+					// otherwise the rest becomes a name and the error is confusing
+					badEnd := numLitEnd
+					for badEnd < len(in) && isIdChar(in[badEnd]) {
+						badEnd++
+					}
+					return nil, nil, errors.MakeError(tokenStart, badEnd-1, "Invalid number literal")
+				}
 				addToken(tokenStart, numLitEnd)
 				continue tokenize
 			}
-			if char == '"' {
+			if char == '"' || char == '\'' {
 				k := i + 1
-				for k < len(in) {
+				for k < len(in) && in[k] != '\n' {
 					this := in[k]
 					if this == '\\' {
-						//TODO: multi-character escape sequence
+						// the rest of the escape sequence is checked by unescape
 						k += 2
 						continue
 					}
-					if this == '"' {
+					if this == char {
+						value, err := unescape(in, i+1, k)
+						if err != nil {
+							return nil, nil, err
+						}
+						if char == '\'' && len(value) != 1 {
+							return nil, nil, errors.MakeError(i, k, "Character literals must be exactly one byte")
+						}
 						addToken(tokenStart, k+1)
 						continue tokenize
 					}
 					k++
 				}
-				return nil, nil, errors.MakeError(i, i, "unmatched \"")
+				return nil, nil, errors.MakeError(i, i, "unmatched "+string(char))
 			}
 		}
+		if strings.HasPrefix(in[i:], "//") {
+			if i > tokenStart {
+				addToken(tokenStart, i)
+			}
+			if t.KeepComments {
+				addToken(i, len(in)-1)
+			}
+			break
+		}
+		if strings.HasPrefix(in[i:], "/*") {
+			if i > tokenStart {
+				addToken(tokenStart, i)
+			}
+			t.commentDepth = 1
+			t.commentLine = lineNumber
+			t.commentColumn = i
+			skipBlockComment(i, i+2)
+			continue
+		}
 		if char == '.' && isDigit(safeCharAt(in, i+1)) {
 			for j := tokenStart; j < i; j++ {
 				if !isDigit(in[j]) {
@@ -135,6 +247,61 @@ tokenize:
 	return tokenList, indexList, nil
 }
 
+// unescape gives the bytes that in[start:end] stands for. Errors point at the bad escape sequence.
+func unescape(in string, start, end int) (string, error) {
+	var out []byte
+	for i := start; i < end; i++ {
+		if in[i] != '\\' {
+			out = append(out, in[i])
+			continue
+		}
+		escapeStart := i
+		i++
+		if i >= end {
+			return "", errors.MakeError(escapeStart, escapeStart, "Unfinished escape sequence")
+		}
+		switch in[i] {
+		case 'n':
+			out = append(out, '\n')
+		case 't':
+			out = append(out, '\t')
+		case 'r':
+			out = append(out, '\r')
+		case '0':
+			out = append(out, 0)
+		case '\\', '"', '\'':
+			out = append(out, in[i])
+		case 'x':
+			if i+2 >= end || !isHexDigit(in[i+1]) || !isHexDigit(in[i+2]) {
+				return "", errors.MakeError(escapeStart, i, "\\x needs two hex digits")
+			}
+			value, _ := strconv.ParseUint(in[i+1:i+3], 16, 8)
+			out = append(out, byte(value))
+			i += 2
+		case 'u':
+			closing := strings.IndexByte(in[i:end], '}')
+			if i+1 >= end || in[i+1] != '{' || closing == -1 {
+				return "", errors.MakeError(escapeStart, i, "Unicode escapes look like \\u{1F600}")
+			}
+			closing += i
+			digits := in[i+2 : closing]
+			codePoint, err := strconv.ParseUint(digits, 16, 32)
+			if len(digits) == 0 || len(digits) > 6 || err != nil || !utf8.ValidRune(rune(codePoint)) {
+				return "", errors.MakeError(escapeStart, closing, "Invalid unicode code point")
+			}
+			out = append(out, string(rune(codePoint))...)
+			i = closing
+		default:
+			return "", errors.MakeError(escapeStart, i, "Unknown escape sequence")
+		}
+	}
+	return string(out), nil
+}
+
+func isHexDigit(b byte) bool {
+	return isDigit(b) || (b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F')
+}
+
 func safeCharAt(s string, i int) byte {
 	if i >= 0 && i < len(s) {
 		return s[i]
@@ -150,22 +317,59 @@ func iAfterWs(s string, i int) int {
 	return i
 }
 
+// the end of the longest number literal that starts at i
 func iAfterNumLiteral(s string, i int) int {
-	seenDot := false
-	for ; i < len(s); i++ {
-		if s[i] == '.' {
-			if seenDot {
-				break
-			} else {
-				seenDot = true
-			}
-			continue
+	machines := fsm.ForwardNumLiteralMachines()
+	end := i
+	for k := i; k < len(s); k++ {
+		if fsm.AdvanceAll(rune(s[k]), machines...) > -1 {
+			end = k + 1
 		}
-		if !unicode.IsDigit(rune(s[i])) {
+		if fsm.AllFailed(machines...) {
 			break
 		}
 	}
-	return i
+	return end
+}
+
+// ParseIntLiteral gives the value of an integer literal token as an int64, or an uint64 when it's too big
+// for int64. ok is false when the value doesn't fit in 64 bits.
+func ParseIntLiteral(literal string) (value interface{}, ok bool) {
+	negative := strings.HasPrefix(literal, "-")
+	digits := strings.TrimPrefix(literal, "-")
+	base := 10
+	if len(digits) > 2 && digits[0] == '0' {
+		switch digits[1] {
+		case 'x':
+			base = 16
+		case 'o':
+			base = 8
+		case 'b':
+			base = 2
+		}
+	}
+	if base != 10 {
+		digits = digits[2:]
+	}
+	digits = strings.Replace(digits, "_", "", -1)
+	magnitude, err := strconv.ParseUint(digits, base, 64)
+	if err != nil {
+		return nil, false
+	}
+	if negative {
+		if magnitude > 1<<63 {
+			return nil, false
+		}
+		return -int64(magnitude), true
+	}
+	if magnitude > math.MaxInt64 {
+		return magnitude, true
+	}
+	return int64(magnitude), true
+}
+
+func isIdChar(b byte) bool {
+	return b == '_' || unicode.IsLetter(rune(b)) || unicode.IsDigit(rune(b))
 }
 
 func isSpace(b byte) bool {
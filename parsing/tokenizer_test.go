@@ -1,6 +1,7 @@
 package parsing
 
 import (
+	"github.com/XrXr/alang/errors"
 	"reflect"
 	"testing"
 )
@@ -16,6 +17,7 @@ var fixture = map[string][]string{
 	"minus.food.cat * pop":         {"minus", ".", "food", ".", "cat", "*", "pop"},
 	"34..65":                       {"34", "..", "65"},
 	"hap..jo":                      {"hap", "..", "jo"},
+	"0..<count":                    {"0", "..<", "count"},
 	"123.20 * pop":                 {"123.20", "*", "pop"},
 	"-3231.20 * pop":               {"-3231.20", "*", "pop"}, // negative in front
 	"-3231 * pop":                  {"-3231", "*", "pop"},
@@ -43,6 +45,135 @@ var fixture = map[string][]string{
 	` "progress 3+4" `:             {`"progress 3+4"`},
 	` "progress" + 3 `:             {`"progress"`, `+`, `3`},
 	"\t\t   {":                     {"{"},
+	"flags & mask":                 {"flags", "&", "mask"},
+	"a|b^~c":                       {"a", "|", "b", "^", "~", "c"},
+	"a<<=2":                        {"a", "<<=", "2"},
+	"a >> b <= c":                  {"a", ">>", "b", "<=", "c"},
+	"bits &= mask":                 {"bits", "&=", "mask"},
+	"a%b %= 3":                     {"a", "%", "b", "%=", "3"},
+	"enum Kind: u8 {":              {"enum", "Kind", ":", "u8", "{"},
+	"a := b :: c":                  {"a", ":=", "b", "::", "c"},
+	"0xff & mask":                  {"0xff", "&", "mask"},
+	"a+-0b1010":                    {"a", "+", "-0b1010"},
+	"x := 1_000 * 0o17":            {"x", ":=", "1_000", "*", "0o17"},
+	"[0x10]u8":                     {"[", "0x10", "]", "u8"},
+	"a := b // trailing comment":   {"a", ":=", "b"},
+	"a/*b*/+ /* c /* d */ e */ f":  {"a", "+", "f"},
+	"// whole line":                nil,
+	`url := "http://a/*b*/"`:       {"url", ":=", `"http://a/*b*/"`},
+	"half/2":                       {"half", "/", "2"},
+	"c == 'a'":                     {"c", "==", "'a'"},
+	`c = '\''`:                     {"c", "=", `'\''`},
+	`"tab\there \u{1F600}\x41"`:    {`"tab\there \u{1F600}\x41"`},
+}
+
+var unescapeFixture = map[string]string{
+	`plain`:      "plain",
+	`a\nb`:       "a\nb",
+	`\t\r\0`:     "\t\r\x00",
+	`\\ \" \'`:   "\\ \" '",
+	`\x41\x7f`:   "A\x7f",
+	`\u{e9}`:     "\u00e9",
+	`\u{1F600}!`: "\U0001F600!",
+}
+
+func TestUnescape(t *testing.T) {
+	for in, expect := range unescapeFixture {
+		out, err := unescape(in, 0, len(in))
+		if err != nil {
+			t.Errorf("Failed to unescape %#v: %v", in, err)
+			continue
+		}
+		if out != expect {
+			t.Errorf("Unescaping %#v gave %#v instead of %#v", in, out, expect)
+		}
+	}
+}
+
+func TestParseIntLiteral(t *testing.T) {
+	fixture := map[string]interface{}{
+		"42":                     int64(42),
+		"040":                    int64(40),
+		"-0x10":                  int64(-16),
+		"0o755":                  int64(493),
+		"0b1010_1010":            int64(170),
+		"1_000_000":              int64(1000000),
+		"-0x8000_0000_0000_0000": int64(-1 << 63),
+		"0xffff_ffff_ffff_ffff":  uint64(1<<64 - 1),
+	}
+	for in, expect := range fixture {
+		value, ok := ParseIntLiteral(in)
+		if !ok || value != expect {
+			t.Errorf("%#v parsed to %#v instead of %#v", in, value, expect)
+		}
+	}
+	for _, in := range []string{"0x1_0000_0000_0000_0000", "-0x8000_0000_0000_0001", "18446744073709551616"} {
+		if _, ok := ParseIntLiteral(in); ok {
+			t.Errorf("%#v should not fit in 64 bits", in)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	lines := []string{
+		"a := 1 // one",
+		"b /* two",
+		"  /* nested */ still two",
+		"*/ c",
+	}
+	expect := [][]string{
+		{"a", ":=", "1", "// one"},
+		{"b", "/* two"},
+		{"/* nested */ still two"},
+		{"*/", "c"},
+	}
+	tokenizer := Tokenizer{KeepComments: true}
+	for i, line := range lines {
+		tokens, indices, err := tokenizer.Tokenize(line, i)
+		if err != nil {
+			t.Errorf("Failed to tokenize %#v", line)
+			continue
+		}
+		if !reflect.DeepEqual(tokens, expect[i]) {
+			t.Errorf("Tokenizing %#v gave %#v", line, tokens)
+		}
+		for j, token := range tokens {
+			if line[indices[j]:indices[j]+len(token)] != token {
+				t.Errorf("Bad index for token %#v", token)
+			}
+		}
+	}
+	if err := tokenizer.Finish(); err != nil {
+		t.Errorf("Comment should be closed")
+	}
+
+	var unterminated Tokenizer
+	unterminated.Tokenize("x /* /* */", 3)
+	unterminated.Tokenize("y", 4)
+	err, isUserError := unterminated.Finish().(*errors.UserError)
+	if !isUserError || err.Line != 3 || err.StartColumn != 2 {
+		t.Errorf("Unterminated block comment should point at the opener. Got %#v", err)
+	}
+}
+
+func TestBadEscape(t *testing.T) {
+	fixture := map[string]int{
+		`"ab\q"`:       3,
+		`"\x4"`:        1,
+		`"\u{110000}"`: 1,
+		`x := "a\u12"`: 7,
+	}
+	for in, column := range fixture {
+		_, _, err := Tokenize(in)
+		userError, isUserError := err.(*errors.UserError)
+		if !isUserError {
+			t.Errorf("Expected an error for %#v", in)
+			continue
+		}
+		if userError.StartColumn != column {
+			t.Errorf("Error for %#v starts at column %d instead of %d", in, userError.StartColumn, column)
+		}
+	}
 }
 
 func TestTokenizer(t *testing.T) {
@@ -5,16 +5,24 @@ import "github.com/XrXr/alang/errors"
 type ASTNode interface {
 	GetLineNumber() int
 	GetStartColumn() int
+	GetEndLineNumber() int
 	GetEndColumn() int
 }
 
 func ErrorFromNode(node ASTNode, message string) *errors.UserError {
-	return &errors.UserError{Line: node.GetLineNumber(), StartColumn: node.GetStartColumn(), EndColumn: node.GetEndColumn(), Message: message}
+	return &errors.UserError{
+		Line:        node.GetLineNumber(),
+		StartColumn: node.GetStartColumn(),
+		EndLine:     node.GetEndLineNumber(),
+		EndColumn:   node.GetEndColumn(),
+		Message:     message,
+	}
 }
 
 type sourceLocation struct {
 	line        int
 	startColumn int
+	endLine     int
 	endColumn   int
 }
 
@@ -22,6 +30,10 @@ func (s sourceLocation) GetLineNumber() int {
 	return s.line
 }
 
+func (s sourceLocation) GetEndLineNumber() int {
+	return s.endLine
+}
+
 func (s sourceLocation) GetStartColumn() int {
 	return s.startColumn
 }
@@ -35,35 +47,64 @@ type IdName struct {
 	Name string
 }
 
-// Either Base is set or ArraySizes and ArrayBase is set.
+// Either Base is set, ArraySizes and ArrayBase is set, SliceOf is set, or Proc is set.
 // Indirection always happens before the base/array
+// Sizes that aren't integer literals are constant expressions in ArraySizeExprs and need to be resolved
+// before use. It has the same length as ArraySizes when it's not empty. Literal sizes are nil in it.
+// TypeArgs are the types in list(int) when Base names a generic struct.
 type TypeDecl struct {
 	sourceLocation
 	Base               IdName
+	TypeArgs           []TypeDecl
 	ArraySizes         []int
+	ArraySizeExprs     []ASTNode
 	ArrayBase          *TypeDecl
+	SliceOf            *TypeDecl
+	Proc               *ProcTypeDecl
 	LevelOfIndirection int
 }
 
+// proc(int, bool) -> int. Return is void when there is no "->"
+type ProcTypeDecl struct {
+	Args   []TypeDecl
+	Return TypeDecl
+}
+
 type Declaration struct {
 	sourceLocation
 	Type TypeDecl
 	Name IdName
 }
 
+// TypeParams are the "$T type" parameters of generic procs. They come before all the other parameters.
 type ProcDecl struct {
 	sourceLocation
-	Args      []Declaration
-	Return    TypeDecl
-	IsForeign bool
+	TypeParams   []IdName
+	Args         []Declaration
+	Return       TypeDecl
+	ExtraReturns []TypeDecl // the types after the first one in "-> (int, bool)"
+	IsForeign    bool
 }
 
+// Calls to the value of an expression, as in table[i](x), have Target instead of Callee
 type ProcCall struct {
 	sourceLocation
 	Callee IdName
+	Target ASTNode
 	Args   []ASTNode
 }
 
+// IsMethodName tells whether node looks like the point.length in point.length :: proc
+func IsMethodName(node ASTNode) bool {
+	expr, isExpr := node.(ExprNode)
+	if !isExpr || expr.Op != Dot {
+		return false
+	}
+	_, receiverIsIdent := expr.Left.(IdName)
+	_, methodIsIdent := expr.Right.(IdName)
+	return receiverIsIdent && methodIsIdent
+}
+
 const Invalid = 0
 
 //go:generate $GOPATH/bin/stringer -type=Operator
@@ -94,6 +135,20 @@ const (
 	Dereference
 	AddressOf
 	ArrayAccess
+	BitAnd
+	BitOr
+	BitXor
+	BitNot
+	ShiftLeft
+	ShiftRight
+	BitAndEqual
+	BitOrEqual
+	BitXorEqual
+	ShiftLeftEqual
+	ShiftRightEqual
+	Modulo
+	ModuloEqual
+	RangeExclusive
 )
 
 //go:generate $GOPATH/bin/stringer -type=LiteralType
@@ -105,6 +160,8 @@ const (
 	Array
 	Boolean
 	NilPtr
+	Float
+	Char // Value is the one byte the literal stands for
 )
 
 type Literal struct {
@@ -113,6 +170,28 @@ type Literal struct {
 	Value string
 }
 
+// [3]int{1, 2, 3}. Type is the whole array type
+type ArrayLiteral struct {
+	sourceLocation
+	Type   TypeDecl
+	Values []ASTNode
+}
+
+// cast(*u8, p)
+type Cast struct {
+	sourceLocation
+	Type  TypeDecl
+	Value ASTNode
+}
+
+// Names is empty when the values are given in the order of the fields
+type StructLiteral struct {
+	sourceLocation
+	Type   IdName
+	Names  []IdName
+	Values []ASTNode
+}
+
 type ExprNode struct {
 	sourceLocation
 	Op    Operator
@@ -120,9 +199,34 @@ type ExprNode struct {
 	Right ASTNode
 }
 
+// TypeParams is set for generic structs, as in struct list($T) {
+// also used for unions
 type StructDeclare struct {
 	sourceLocation
-	Name IdName
+	Name       IdName
+	TypeParams []IdName
+	IsUnion    bool
+}
+
+// Members is only filled in when the whole enum is on one line.
+// Otherwise they come in EnumMembers nodes inside the enum's block
+type EnumDeclare struct {
+	sourceLocation
+	Name    IdName
+	Backing IdName
+	Members []EnumMember
+}
+
+// Value is nil when the member doesn't give one
+type EnumMember struct {
+	sourceLocation
+	Name  IdName
+	Value ASTNode
+}
+
+type EnumMembers struct {
+	sourceLocation
+	Members []EnumMember
 }
 
 type IfNode struct {
@@ -130,9 +234,30 @@ type IfNode struct {
 	Condition ASTNode
 }
 
+type SwitchNode struct {
+	sourceLocation
+	Value ASTNode
+}
+
+// Values is empty for the default case
+type CaseNode struct {
+	sourceLocation
+	Values    []ASTNode
+	IsDefault bool
+}
+
+// Step is only for ranges, as in for i := 10..0 step -2. Label is empty unless the loop looks like
+// outer: for {
+// Element is empty unless the loop goes over the elements of Expression, as in
+// for x in arr, for i, x in arr or for &x in arr
 type Loop struct {
 	sourceLocation
 	Expression ASTNode
+	Step       ASTNode
+	Label      IdName
+	Index      IdName
+	Element    IdName
+	ByPointer  bool
 }
 
 type ReturnNode struct {
@@ -140,16 +265,34 @@ type ReturnNode struct {
 	Values []ASTNode
 }
 
+// a, b := f() and a, b = f()
+type MultiAssign struct {
+	sourceLocation
+	Op      Operator // Declare or Assign
+	Targets []ASTNode
+	Value   ASTNode
+}
+
+// defer <statement>. Statement runs when control leaves the enclosing block
+type DeferNode struct {
+	sourceLocation
+	Statement ASTNode
+}
+
 type ElseNode struct {
 	sourceLocation
 }
 
+// Label is empty when continuing the innermost loop
 type ContinueNode struct {
 	sourceLocation
+	Label IdName
 }
 
+// Label is empty when breaking out of the innermost loop
 type BreakNode struct {
 	sourceLocation
+	Label IdName
 }
 
 type BlockEnd struct {
@@ -0,0 +1,96 @@
+struct holder {
+    tag u8
+    values [3]int
+}
+
+struct pair {
+    a int
+    b s32
+}
+
+COUNT :: 4
+
+sum :: proc (values [3]int) -> int {
+    total := 0
+    for i := 0..2 {
+        total = total + values[i]
+    }
+    values[0] = 1000
+    return total
+}
+
+squares :: proc (n int) -> [4]int {
+    var result [4]int
+    for i := 0..3 {
+        result[i] = (n + i) * (n + i)
+    }
+    return result
+}
+
+small :: proc () -> [2]s32 {
+    return [2]s32{-5, 9}
+}
+
+many :: proc (a int, b int, c int, d int, e int, f int, values [3]int, last int) -> int {
+    return values[0] + values[1] + values[2] + last + a + f
+}
+
+swap :: proc (p pair) -> pair {
+    return pair{p.b, p.a}
+}
+
+main :: proc () {
+    a := [3]int{1, 2, 3}
+    print_int(a[0] + a[1] + a[2])
+
+    n := 10
+    b := [3]int{n, n + 1}
+    print_int(b[0])
+    print_int(b[1])
+    print_int(b[2])
+
+    bytes := [COUNT]u8{250, 251, 252, 253}
+    print_int(bytes[3])
+
+    a = b
+    b[0] = 99
+    print_int(a[0])
+    print_int(b[0])
+
+    print_int(sum(a))
+    print_int(a[0])
+
+    sq := squares(3)
+    print_int(sq[0])
+    print_int(sq[3])
+
+    pairOfNumbers := small()
+    print_int(pairOfNumbers[1])
+    if pairOfNumbers[0] == -5 {
+        puts("negative element\n")
+    }
+
+    var h holder
+    h.values = [3]int{7, 8, 9}
+    h.tag = 1
+    print_int(h.values[2])
+    copied := h.values
+    copied[2] = 0
+    print_int(h.values[2])
+
+    grid := [2][2]int{1, 2, 3}
+    print_int(grid[2])
+    print_int(grid[3])
+
+    floats := [2]f64{1.5, 2.25}
+    print_float(floats[0] + floats[1])
+
+    print_int(many(1, 2, 3, 4, 5, 6, [3]int{10, 20, 30}, 40))
+
+    swapped := swap(pair{1, 2})
+    print_int(swapped.a)
+    print_int(swapped.b)
+
+    empty := [3]u8{}
+    print_int(empty[0] + empty[1] + empty[2])
+}
@@ -0,0 +1,55 @@
+identity :: proc (a int) -> int {
+    return a
+}
+
+main :: proc () {
+    // known at compile time
+    a := 12
+    b := 10
+    print_int(a & b)
+    print_int(a | b)
+    print_int(a ^ b)
+    print_int(~a)
+    print_int(1 << 10)
+    print_int(-64 >> 2)
+    if a & 4 == 4 {
+        puts("precedence good\n")
+    }
+
+    // only known at runtime
+    c := identity(12)
+    d := identity(10)
+    print_int(c & d)
+    print_int(c | d)
+    print_int(c ^ d)
+    print_int(~c)
+    print_int(c << d)
+    negative := 0 - c
+    print_int(negative >> 2)
+    print_int(c & 4095)
+
+    e := identity(6)
+    e &= 3
+    print_int(e)
+    e |= 8
+    print_int(e)
+    e ^= c
+    print_int(e)
+    e <<= identity(2)
+    print_int(e)
+    e >>= 3
+    print_int(e)
+
+    // shifting right fills with zeros when unsigned
+    var f u8
+    f = 200
+    f >>= identity(2)
+    print_int(f)
+    var g s8
+    g = -56
+    g >>= identity(2)
+    print_int(g)
+    f = 255
+    f <<= 4
+    print_int(f)
+}
@@ -0,0 +1,57 @@
+struct point {
+	x int
+	y int
+}
+
+widen :: proc (b s8) -> s64 {
+	return cast(s64, b)
+}
+
+truncate :: proc (n int) -> u8 {
+	return cast(u8, n)
+}
+
+truthy :: proc (n int) -> bool {
+	return cast(bool, n)
+}
+
+main :: proc () {
+	var small s8
+	small = -5
+	print_int(cast(u8, small))
+	print_int(cast(s64, small) + 10)
+	print_int(widen(-1) + 2)
+	var big u32
+	big = 4000000000
+	print_int(cast(u64, big))
+	print_int(cast(s64, cast(s32, big)) + 294967297)
+	wide := 0x1234
+	print_int(cast(u8, wide))
+	print_int(cast(u8, 0x1ff))
+	print_int(cast(s64, cast(s8, 255)) + 2)
+
+	p := point{3, 4}
+	pp := &p
+	raw := cast(*u8, pp)
+	back := cast(*point, raw)
+	print_int(back.y)
+	address := cast(u64, pp)
+	again := cast(*int, address + 8)
+	print_int(@again)
+
+	yes := cast(bool, wide)
+	no := cast(bool, 0)
+	if yes && !no {
+		puts("bools\n")
+	}
+	print_int(cast(int, yes) + cast(int, no))
+	var zero u8
+	print_int(cast(int, cast(bool, zero)))
+	print_int(cast(int, 2.5))
+	print_int(truncate(0x2_0005))
+	if truthy(-3) && !truthy(0) {
+		puts("runtime bools\n")
+	}
+	print_int(u8(wide))
+	print_int(int(small) + 6)
+}
@@ -0,0 +1,32 @@
+// a line comment at the top
+/* a block comment
+   spanning lines /* with a nested one */
+   still in the outer one
+*/
+
+struct point { // trailing comment on a struct header
+	x int // the x part
+	/* between fields */ y int
+}
+
+half :: proc (n int) -> int { // comment after a brace
+	return n / 2 // integer division, not a comment
+}
+
+main :: proc () {
+	p := point{x = 8, y = /* inline */ 2}
+	url := "http://example.com /* not a comment */"
+	puts(url)
+	puts("\n")
+	print_int(half(p.x) + p.y) // prints 6
+	total := half(10) +
+		// a comment in the middle of a statement
+		p.y /* and one at the end */
+	print_int(total)
+	c := '/'
+	print_int(c)
+	/*
+	print_int(999)
+	*/
+	print_int(1/*no space*/+1)
+}
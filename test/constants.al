@@ -0,0 +1,51 @@
+SIZE :: COUNT * 2
+COUNT :: 4
+MASK :: (1 << 4) - 1
+GREETING :: "hi from a constant\n"
+DEBUG :: true
+
+var table [SIZE]int
+
+struct Buffer {
+	data [COUNT]u8
+	len int
+}
+
+sum :: proc () -> int {
+	total := 0
+	for i := 0..SIZE-1 {
+		total = total + table[i]
+	}
+	return total
+}
+
+main :: proc () {
+	print_int(SIZE)
+	print_int(MASK)
+	puts(GREETING)
+	if DEBUG {
+		puts("debug\n")
+	}
+	for i := 0..SIZE-1 {
+		table[i] = i
+	}
+	print_int(sum())
+	LOCAL :: SIZE + 1
+	print_int(LOCAL)
+	var b Buffer
+	b.len = COUNT
+	print_int(b.len)
+	var local [LOCAL]int
+	local[LOCAL - 1] = 42
+	print_int(local[8])
+	var doubled [COUNT * 2 + (SIZE - COUNT)]u8
+	doubled[11] = 3
+	print_int(doubled[11])
+	literal := [COUNT - 1]int{5, 6, 7}
+	print_int(literal[2])
+	if true {
+		COUNT :: 100
+		print_int(COUNT)
+	}
+	print_int(COUNT)
+}
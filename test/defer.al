@@ -0,0 +1,61 @@
+count := 0
+
+bump :: proc (by int) {
+	count = count + by
+}
+
+early :: proc (stop bool) -> int {
+	defer puts("early: first deferred, runs last\n")
+	defer puts("early: second deferred, runs first\n")
+	if stop {
+		defer puts("early: leaving the if\n")
+		return 1
+	}
+	puts("early: fell through\n")
+	return 2
+}
+
+returned :: proc () -> int {
+	x := 10
+	defer x = 99
+	return x
+}
+
+pair :: proc () -> (int, int) {
+	a := 1
+	b := 2
+	defer a = 100
+	defer b = 200
+	return a, b
+}
+
+main :: proc () {
+	print_int(early(true))
+	print_int(early(false))
+	print_int(returned())
+	one, two := pair()
+	print_int(one)
+	print_int(two)
+
+	for i := 1..5 {
+		defer bump(1)
+		if i == 2 {
+			continue
+		}
+		if i == 4 {
+			defer puts("breaking\n")
+			break
+		}
+		print_int(i)
+	}
+	print_int(count)
+
+	total := 0
+	for j := 1..3 {
+		defer total += j
+	}
+	print_int(total)
+
+	defer puts("end of main\n")
+	puts("about to leave main\n")
+}
@@ -0,0 +1,80 @@
+LAST :: 40
+
+enum Color : u8 {
+	Red,
+	Green = 5,
+	Blue
+}
+
+enum Event : s32 { Expose = 12, KeyPress = 2, Other = LAST + 2, After }
+
+enum Big : u64 {
+	Small
+	Larger = Small + 1000
+}
+
+struct Message {
+	kind Event
+	color Color
+	length int
+}
+
+var current Color
+
+describe :: proc (c Color) -> int {
+	if c == Color.Red {
+		return 1
+	}
+	if c == Color.Blue {
+		return 3
+	}
+	return 0
+}
+
+pick :: proc (n int) -> Color {
+	return Color(n)
+}
+
+main :: proc () {
+	var m Message
+	m.kind = Event.KeyPress
+	m.color = Color.Blue
+	m.length = 9
+	print_int(int(m.kind))
+	print_int(int(m.color))
+	print_int(m.length)
+	if m.kind == Event.KeyPress {
+		puts("key press\n")
+	}
+	if m.kind != Event.Expose {
+		puts("not expose\n")
+	}
+	print_int(int(Event.Other))
+	print_int(int(Event.After))
+	print_int(int(Big.Larger))
+	print_int(describe(Color.Red))
+	print_int(describe(pick(6)))
+	print_int(describe(Color.Green))
+	c := Color.Green
+	if c > Color.Red {
+		puts("green after red\n")
+	}
+	current = c
+	print_int(int(current))
+	var zero Color
+	if zero == Color.Red {
+		puts("zero is red\n")
+	}
+	var raw u8
+	raw = u8(Color.Blue)
+	print_int(raw)
+	var e Event
+	e = Event(12)
+	if e == Event.Expose {
+		puts("cast back\n")
+	}
+	for i := 0..2 {
+		k := Color(i)
+		print_int(int(k))
+	}
+}
@@ -0,0 +1,30 @@
+NEWLINE :: '\n'
+letter := 'z'
+
+main :: proc () {
+	puts("tab:\tend\n")
+	puts("quote: \" backslash: \\ single: \' \n")
+	puts("hex: \x41\x42\x43\n")
+	puts("unicode: \u{e9} \u{1F600}\n")
+	puts("crlf\r\n")
+	s := "a\0b"
+	print_int(s.length)
+	print_int("\u{e9}".length)
+	c := 'a'
+	print_int(c)
+	var d u8
+	d = '\n'
+	print_int(d)
+	print_int(NEWLINE)
+	print_int(letter)
+	print_int('\x7f')
+	switch c {
+	case 'a':
+		puts("switch on a char\n")
+	default:
+		puts("bad\n")
+	}
+	if c == 'a' {
+		puts("compare works\n")
+	}
+}
@@ -0,0 +1,123 @@
+struct point {
+    tag u8
+    x f32
+    y f64
+}
+
+half :: proc (a f64) -> f64 {
+    return a / 2.0
+}
+
+narrow :: proc (a f64) -> f32 {
+    return a
+}
+
+sum :: proc (a f64, b f32, n int, c f64, d f64, e f64, f f64, g f64, h f64, i f64, j f32) -> f64 {
+    return a + b + c + d + e + f + g + h + i + j + f64(n)
+}
+
+identity :: proc (a int) -> int {
+    return a
+}
+
+identity_u64 :: proc (a u64) -> u64 {
+    return a
+}
+
+main :: proc () {
+    a := 1.5
+    b := 2.25
+    print_float(a + b)
+    print_float(a - b)
+    print_float(a * b)
+    print_float(b / a)
+    print_float(0.1)
+
+    var small f32
+    small = 0.5
+    small = small + 0.25
+    print_float(small)
+    wide := b + small
+    print_float(wide)
+
+    print_float(half(7.0))
+    print_float(narrow(1.125))
+    print_float(sum(1.0, 2.0, 3, 4.0, 5.0, 6.0, 7.0, 8.0, 9.0, 10.0, 11.0))
+
+    count := 7
+    print_float(f64(count))
+    halfCount := f32(count) / 2.0
+    print_float(halfCount)
+    var neg s32
+    neg = 0 - 3
+    print_float(f64(neg))
+    print_int(int(9.75))
+    print_int(u8(b))
+    third := 1.0 / 3.0
+    print_float(third)
+
+    if a < b {
+        puts("a < b\n")
+    }
+    if b <= a {
+        puts("bad\n")
+    }
+    if b > a {
+        puts("b > a\n")
+    }
+    if a >= a {
+        puts("a >= a\n")
+    }
+    if small == 0.75 {
+        puts("small == 0.75\n")
+    }
+    if a != b {
+        puts("a != b\n")
+    }
+
+    p := point()
+    p.x = 3.5
+    p.y = p.x * 2.0
+    pp := &p
+    pp.y = pp.y + 1.0
+    print_float(p.x)
+    print_float(pp.y)
+
+    runtime := identity(0 - 12)
+    print_float(f64(runtime))
+    var zero f64
+    print_float(zero)
+    nan := zero / zero
+    if nan == nan {
+        puts("bad\n")
+    }
+    if nan < 1.0 {
+        puts("bad\n")
+    }
+    if nan != nan {
+        puts("nan != nan\n")
+    }
+
+    top := identity_u64(1) << 63
+    top_float := f64(top)
+    if top_float > 0.0 {
+        puts("top bit set stays positive\n")
+    }
+    print_float(top_float / 1099511627776.0)
+    print_int(int(u64(top_float) - top))
+    odd := top + 1025
+    print_int(int(u64(f64(odd)) - top))
+    print_int(int(u64(f32(odd)) - top))
+    print_int(int(u64(f64(identity_u64(12)))))
+    print_int(int(u64(half(19.0))))
+
+    print_float(10000000000000.0)
+    print_float(0.0 - 123456789.125)
+    print_float(0.9999999)
+    print_float(top_float)
+    print_float(top_float * 4.0)
+    print_float(0.0 - top_float * 4.0)
+    print_float(1.0 / zero)
+    print_float(-1.0 / zero)
+    print_float(nan)
+}
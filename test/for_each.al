@@ -0,0 +1,88 @@
+var table [3]int
+
+struct point {
+	x int
+	y int
+}
+
+total :: proc (numbers []int) -> int {
+	sum := 0
+	for n in numbers {
+		sum += n
+	}
+	return sum
+}
+
+main :: proc () {
+	numbers := [4]int{3, 1, 4, 1}
+	sum := 0
+	for n in numbers {
+		sum += n
+	}
+	print_int(sum)
+
+	for i, n in numbers {
+		print_int(i * 10 + n)
+	}
+
+	for &n in numbers {
+		@n = @n * 2
+	}
+	print_int(numbers[2])
+	numbers_ptr := &numbers
+	for n in numbers_ptr {
+		sum += n
+	}
+	print_int(sum)
+
+	word := "hey"
+	for c in word {
+		print_int(c)
+	}
+	for i, &c in word {
+		if i == 0 {
+			print_int(@c)
+		}
+	}
+
+	points := [2]point{point{1, 2}, point{3, 4}}
+	for &p in points {
+		p.y += p.x
+	}
+	for p in points {
+		print_int(p.y)
+	}
+
+	print_int(total(numbers[1..2]))
+
+	found := -1
+	for i, n in numbers {
+		if n == 8 {
+			found = i
+			break
+		}
+	}
+	print_int(found)
+
+	bytes := [3]u8{7, 8, 9}
+	for b in bytes {
+		if b == 8 {
+			continue
+		}
+		print_int(b)
+	}
+
+	table[0] = 5
+	table[2] = 6
+	rows: for a in table {
+		for b in "xy" {
+			if a == 0 {
+				continue rows
+			}
+			print_int(a + b)
+		}
+	}
+	for c in "" {
+		print_int(c)
+	}
+}
@@ -0,0 +1,116 @@
+struct point {
+	x int
+	y int
+}
+
+struct pair($A, $B) {
+	first A
+	second B
+}
+
+struct list($T) {
+	items [8]T
+	count int
+	next *list(T)
+}
+
+max :: proc ($T type, a T, b T) -> T {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+push :: proc ($T type, l *list(T), value T) {
+	l.items[l.count] = value
+	l.count = l.count + 1
+}
+
+sum :: proc ($T type, l *list(T)) -> T {
+	var total T
+	for i := 0..l.count-1 {
+		total = total + l.items[i]
+	}
+	return total
+}
+
+clamp :: proc ($T type, value T, high T) -> T {
+	if max(T, value, high) == value {
+		return high
+	}
+	return value
+}
+
+main :: proc () {
+	print_int(max(int, 3, 9))
+	var small s64
+	small = -4
+	print_int(max(s64, small, 7))
+	print_int(clamp(int, 50, 10))
+	print_int(clamp(int, 5, 10))
+
+	var numbers list(int)
+	push(int, &numbers, 4)
+	push(int, &numbers, 5)
+	print_int(sum(int, &numbers))
+	print_int(numbers.count)
+
+	var bytes list(u8)
+	push(u8, &bytes, 250)
+	push(u8, &bytes, 10)
+	print_int(sum(u8, &bytes))
+
+	var points list(point)
+	var p point
+	p.x = 3
+	p.y = 4
+	points.items[2] = p
+	print_int(points.items[2].y)
+
+	var both pair(int, bool)
+	both.first = 12
+	both.second = true
+	if both.second {
+		print_int(both.first)
+	}
+	more()
+}
+
+struct holder {
+	tag int
+	inner box(point)
+}
+
+struct box($T) {
+	value T
+	other *box(T)
+}
+
+var global box(int)
+
+first :: proc (b *box(point)) -> int {
+	return b.value.x
+}
+
+wrap :: proc ($T type, value T) -> box(T) {
+	var b box(T)
+	b.value = value
+	return b
+}
+
+more :: proc () {
+	var h holder
+	h.tag = 1
+	h.inner.value.x = 41
+	h.inner.value.y = 2
+	print_int(first(&h.inner))
+	global.value = 17
+	print_int(global.value)
+	b := wrap(int, 99)
+	print_int(b.value)
+	var p point
+	p.y = 8
+	c := wrap(point, p)
+	print_int(c.value.y)
+	print_int(h.inner.value.y)
+}
@@ -0,0 +1,6 @@
+strlen :: foreign proc (str *u8) -> u64
+limit := 5
+
+main :: proc () {
+	print_int(limit)
+}
@@ -0,0 +1,112 @@
+struct counter {
+    hits int
+    misses u16
+}
+
+counter_calls := 0
+greeting := "hello from a global\n"
+ratio := 0.5
+enabled := true
+var table [8]int
+var stats counter
+var last *int
+var current *counter
+var row *[8]int
+var chosen *int
+next_id := LIMIT + 1
+three := 1 + 2
+squares := [5]int{0, 1, 4, 9}
+start := counter{misses = 3, hits = LIMIT * 2}
+
+struct entry {
+    tag u8
+    weight s32
+    name string
+    scale f32
+}
+
+entries := [3]entry{entry{'a', 0 - 5, "first", 1.5}, entry{name = "second", weight = 12}}
+small := [LIMIT]u16{65535, 7}
+
+LIMIT :: 4
+
+bump :: proc () {
+    counter_calls = counter_calls + 1
+    stats.hits += 2
+}
+
+fill :: proc (n int) {
+    for i := 0..7 {
+        table[i] = i * n
+    }
+}
+
+main :: proc () {
+    puts(greeting)
+    print_int(counter_calls)
+    bump()
+    bump()
+    bump()
+    print_int(counter_calls)
+    print_int(stats.hits)
+    stats.misses = 65535
+    print_int(stats.misses)
+
+    fill(3)
+    print_int(table[7])
+    sum := 0
+    for i := 0..7 {
+        sum = sum + table[i]
+    }
+    print_int(sum)
+
+    p := &counter_calls
+    @p = 40
+    bump()
+    print_int(counter_calls)
+    last = &table[2]
+    print_int(@last)
+
+    current = &stats
+    current.hits = 11
+    print_int(stats.hits)
+    print_int(current.misses)
+    row = &table
+    row[1] = 4
+    print_int(table[1])
+    print_int(row[7])
+    chosen = &table[5]
+    @chosen = 7
+    print_int(table[5])
+
+    print_int(next_id)
+    print_int(three)
+    print_int(squares[3])
+    print_int(squares[4])
+    print_int(start.hits)
+    print_int(start.misses)
+    squares[4] = 16
+    print_int(squares[4])
+    print_int(entries[0].tag)
+    print_int(entries[0].weight)
+    puts(entries[0].name)
+    print_float(entries[0].scale * 2.0)
+    puts(entries[1].name)
+    print_int(entries[1].weight)
+    print_int(entries[1].tag)
+    print_int(entries[2].weight)
+    print_int(small[0])
+    print_int(small[1])
+    print_int(small[3])
+
+    print_float(ratio * 3.0)
+    if enabled {
+        puts("enabled\n")
+    }
+
+    counter_calls := 7
+    print_int(counter_calls)
+    print_int(later)
+}
+
+later := 99
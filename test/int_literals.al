@@ -0,0 +1,32 @@
+PROT_READ :: 0x1
+PROT_WRITE :: 0x2
+MAP_ANONYMOUS :: 0x20
+mask := 0xFF_FF
+
+main :: proc () {
+	print_int(0xff)
+	print_int(0xfF - 0xF0)
+	print_int(0o755)
+	print_int(0b1010)
+	print_int(1_000_000)
+	print_int(0b1111_0000)
+	print_int(-0x10 + 0x20)
+	print_int(PROT_READ | PROT_WRITE | MAP_ANONYMOUS)
+	print_int(mask)
+	print_int(0x7fff_ffff_ffff_ffff)
+	print_int(-0x8000_0000_0000_0000 + 1)
+	var big u64
+	big = 0xffff_ffff_ffff_ffff
+	print_int(big)
+	var arr [0x10]u8
+	arr[15] = 7
+	print_int(arr[0xf])
+	print_int(040)
+	for i := 0..0b11 {
+		print_int(i)
+	}
+	switch 0x2a {
+	case 42:
+		puts("forty two\n")
+	}
+}
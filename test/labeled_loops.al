@@ -0,0 +1,41 @@
+main :: proc () {
+	grid := [9]int{1, 2, 3, 4, 5, 6, 7, 8, 9}
+	found := 0
+	search: for row := 0..2 {
+		for col := 0..2 {
+			if grid[row * 3 + col] == 6 {
+				found = row * 10 + col
+				break search
+			}
+		}
+	}
+	print_int(found)
+
+	count := 0
+	rows: for row := 0..3 {
+		for col := 0..3 {
+			if col > row {
+				continue rows
+			}
+			count += 1
+		}
+	}
+	print_int(count)
+
+	outer: for i := 0..2 {
+		defer puts("leaving outer body\n")
+		inner: for j := 0..2 {
+			defer puts("leaving inner body\n")
+			if j == 1 {
+				continue outer
+			}
+			if i == 2 {
+				break outer
+			}
+			if j == 0 {
+				continue inner
+			}
+		}
+	}
+	puts("done\n")
+}
@@ -0,0 +1,129 @@
+struct point {
+	x int
+	y int
+}
+
+struct segment {
+	from point
+	to point
+}
+
+point.length :: proc (self *point) -> int {
+	return self.x + self.y
+}
+
+point.scale :: proc (self *point, by int) {
+	self.x = self.x * by
+	self.y = self.y * by
+}
+
+point.scale :: proc (self *point, x int, y int) {
+	self.x = self.x * x
+	self.y = self.y * y
+}
+
+point.flipped :: proc (self point) -> point {
+	return point{self.y, self.x}
+}
+
+point.parts :: proc (self point) -> (int, int) {
+	return self.x, self.y
+}
+
+point.show :: proc (self *point) {
+	print_int(self.x)
+	print_int(self.y)
+}
+
+segment.width :: proc (self *segment) -> int {
+	return self.to.x - self.from.x
+}
+
+struct stepper {
+	step proc(int) -> int
+	report proc(int)
+	count int
+}
+
+struct machine {
+	inner stepper
+}
+
+stepper.run :: proc (self *stepper, n int) -> int {
+	self.count = self.count + 1
+	return self.step(n)
+}
+
+// the method wins over the member with the same name
+stepper.report :: proc (self stepper, n int) {
+	print_int(n + 1000)
+}
+
+double :: proc (n int) -> int {
+	return n * 2
+}
+
+square :: proc (n int) -> int {
+	return n * n
+}
+
+show_int :: proc (n int) {
+	print_int(n)
+}
+
+var origin point
+var global_stepper stepper
+
+main :: proc () {
+	p := point{1, 2}
+	print_int(p.length())
+	p.scale(3)
+	p.show()
+	p.scale(2, 10)
+	p.show()
+
+	ptr := &p
+	print_int(ptr.length())
+	flipped := ptr.flipped()
+	flipped.show()
+	print_int(p.flipped().length())
+
+	x, y := p.parts()
+	print_int(x)
+	print_int(y)
+
+	s := segment{point{1, 1}, point{5, 9}}
+	print_int(s.width())
+	print_int(s.to.length())
+	s.to.scale(2)
+	s.to.show()
+
+	origin.x = 7
+	print_int(origin.length())
+	origin.scale(2)
+	print_int(origin.x)
+
+	total := 0
+	for i := 0..2 {
+		p.scale(1, 2)
+		total += p.y
+	}
+	print_int(total)
+
+	st := stepper{&double, &show_int, 0}
+	print_int(st.step(21))
+	print_int(st.run(5))
+	st.report(1)
+	st.step = &square
+	sp := &st
+	print_int(sp.step(9))
+	print_int(sp.run(4))
+	print_int(st.count)
+	m := machine{st}
+	print_int(m.inner.step(6))
+	global_stepper.step = &double
+	print_int(global_stepper.step(50))
+
+	defer p.show()
+	p.x = 0
+}
@@ -0,0 +1,50 @@
+identity :: proc (a int) -> int {
+    return a
+}
+
+main :: proc () {
+    // known at compile time
+    a := 47
+    print_int(a % 10)
+    print_int(a / 10)
+    a %= 5
+    print_int(a)
+    b := -47
+    print_int(b % 10)
+
+    // only known at runtime
+    c := identity(47)
+    print_int(c % 10)
+    print_int(c % identity(10))
+    d := identity(-47)
+    print_int(d / identity(10))
+    print_int(d % identity(10))
+    d %= 4
+    print_int(d)
+
+    // unsigned values use div and mul
+    var big u64
+    big = 0
+    big = big - 2
+    big = big + identity(0)
+    print_int(big / 2)
+    print_int(big % 10)
+    print_int(big / identity(3))
+
+    var e u8
+    e = 250
+    e = e + identity(0)
+    print_int(e / 7)
+    print_int(e % 7)
+    var f u8
+    f = 3
+    f = f + identity(0)
+    e = e * f
+    print_int(e)
+
+    var g s8
+    g = -100
+    g = g + identity(0)
+    print_int(g / identity(7))
+    print_int(g % 7)
+}
@@ -0,0 +1,46 @@
+struct point {
+	x int
+	y int
+}
+
+add3 :: proc (a int,
+              b int,
+              c int) -> int {
+	return a +
+		b +
+		c
+}
+
+pair :: proc () -> (int, int) {
+	return 7,
+		8
+}
+
+main :: proc () {
+	total := add3(1,
+		2,
+		3)
+	print_int(total)
+
+	if total == 6 &&
+		total > 5 ||
+		total < 0 {
+		puts("long condition\n")
+	}
+
+	arr := [3]int{10,
+		20,
+		30}
+	print_int(arr[0] +
+		arr[
+			2])
+
+	a, b := pair()
+	print_int(a * 10 + b)
+
+	p := point{x = 1,
+		y = 2}
+	print_int(p.x +
+
+		p.y)
+}
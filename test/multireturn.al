@@ -0,0 +1,87 @@
+struct pair {
+    a int
+    b int
+    c u8
+}
+
+g := 0
+
+divmod :: proc (a int, b int) -> (int, int) {
+    return a / b, a % b
+}
+
+find :: proc (needle int) -> (int, bool) {
+    for i := 0..9 {
+        if i * i == needle {
+            return i, true
+        }
+    }
+    return -1, false
+}
+
+scaled :: proc (n int) -> (f64, int) {
+    return f64(n) * 1.5, n + 1
+}
+
+small :: proc () -> (u8, s64) {
+    var x u8
+    x = 200
+    return x, 7
+}
+
+many :: proc (n int) -> (int, pair, bool, f32) {
+    var p pair
+    p.a = n
+    p.b = n * 2
+    p.c = 3
+    return n + 100, p, n > 5, 0.5
+}
+
+lots :: proc (a int, b int, c int, d int, e int, f int, h int) -> (int, int, int) {
+    return a + b + c, d + e + f, h
+}
+
+main :: proc () {
+    q, r := divmod(17, 5)
+    print_int(q)
+    print_int(r)
+
+    root, ok := find(49)
+    if ok {
+        print_int(root)
+    }
+    root, ok = find(50)
+    if !ok {
+        puts("50 is not a square\n")
+    }
+
+    f, n := scaled(3)
+    print_float(f)
+    print_int(n)
+
+    x, y := small()
+    print_int(x)
+    print_int(y)
+
+    v, p, big, half := many(7)
+    print_int(v)
+    print_int(p.a)
+    print_int(p.b)
+    print_int(p.c)
+    if big {
+        puts("big\n")
+    }
+    print_float(half)
+
+    var holder pair
+    g, holder.b = divmod(9, 2)
+    print_int(g)
+    print_int(holder.b)
+
+    sum := 0
+    for i := 1..3 {
+        a, b, c := lots(i, i, i, i, i, i, i)
+        sum = sum + a + b + c
+    }
+    print_int(sum)
+}
@@ -0,0 +1,36 @@
+show :: proc (a int) {
+	puts("int ")
+	print_int(a)
+}
+
+show :: proc (a bool) {
+	if a {
+		puts("true\n")
+	} else {
+		puts("false\n")
+	}
+}
+
+show :: proc (a int, b int) -> int {
+	return a + b
+}
+
+show :: proc (s string) {
+	puts(s)
+}
+
+puts :: proc (a int, b int) {
+	print_int(a * b)
+}
+
+main :: proc () {
+	show(3)
+	show(true)
+	print_int(show(1, 2))
+	show("str\n")
+	var small u8
+	small = 9
+	show(small)
+	puts(6, 7)
+	puts("done\n")
+}
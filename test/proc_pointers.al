@@ -0,0 +1,81 @@
+var op proc(int, int) -> int
+
+add :: proc (a int, b int) -> int {
+	return a + b
+}
+
+pick :: proc () -> proc(int, int) -> int {
+	return &add
+}
+
+struct Handler {
+	id int
+	run proc(int) -> int
+}
+
+double :: proc (a int) -> int {
+	return a * 2
+}
+
+square :: proc (a int) -> int {
+	return a * a
+}
+
+apply :: proc (f proc(int) -> int, a int) -> int {
+	return f(a)
+}
+
+greet :: proc () {
+	puts("hi\n")
+}
+
+struct Point {
+	x int
+	y int
+}
+
+sum :: proc (p Point, scale int) -> int {
+	return (p.x + p.y) * scale
+}
+
+main :: proc () {
+	f := &double
+	print_int(f(21))
+	f = &square
+	print_int(f(9))
+	print_int(apply(&double, 5))
+	print_int(apply(f, 5))
+
+	var h Handler
+	h.id = 3
+	h.run = &square
+	print_int(h.run(h.id))
+	hp := &h
+	print_int(hp.run(5) + 1)
+
+	var table [2]proc(int) -> int
+	table[0] = &double
+	table[1] = &square
+	for i := 0..1 {
+		print_int(table[i](7))
+	}
+	print_int(table[0](table[1](3)))
+	table[1](2)
+
+	var g proc()
+	g = &greet
+	g()
+
+	s := &sum
+	var p Point
+	p.x = 2
+	p.y = 3
+	print_int(s(p, 10))
+
+	op = pick()
+	print_int(op(3, 4))
+	print_int(pick()(5, 6))
+	print_int((&double)(8))
+	print_int(double(table[1](3) - 1) + 1)
+	print_int(pick()(double(double(2) - 1), table[0](1)))
+}
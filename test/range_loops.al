@@ -0,0 +1,49 @@
+LAST :: 9
+
+main :: proc () {
+	for i := 10..0 step -2 {
+		print_int(i)
+	}
+	puts("exclusive\n")
+	for i := 0..<3 {
+		print_int(i)
+	}
+	for i := 10..<4 step -3 {
+		print_int(i)
+	}
+	puts("skip\n")
+	for i := 0..LAST {
+		if i == 2 {
+			i = 6
+		}
+		print_int(i)
+	}
+	puts("bump\n")
+	for i := 0..10 step 4 {
+		i += 1
+		print_int(i)
+	}
+	total := 0
+	for 1..<5 {
+		total += 1
+	}
+	print_int(total)
+	n := 3
+	for i := n..0 step -1 {
+		if i == 1 {
+			continue
+		}
+		print_int(i)
+	}
+	puts("slices\n")
+	numbers := [5]int{1, 2, 3, 4, 5}
+	s := numbers[1..<3]
+	print_int(s.length)
+	print_int(s[1])
+	e := numbers[2..<2]
+	print_int(e.length)
+	count := 5
+	all := numbers[0..<count]
+	print_int(all[4])
+	print_int(count)
+}
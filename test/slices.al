@@ -0,0 +1,103 @@
+struct point {
+	x int
+	y int
+}
+
+struct holder {
+	tag u8
+	items []int
+}
+
+var global_numbers [4]int
+
+sum :: proc (numbers []int) -> int {
+	total := 0
+	for i := 0..numbers.length-1 {
+		total = total + numbers[i]
+	}
+	return total
+}
+
+print_bytes :: proc (bytes []u8) {
+	writes(bytes.data, bytes.length)
+	puts("\n")
+}
+
+many :: proc (a int, b []int, c []int, d int, e []int) -> int {
+	return a + sum(b) + sum(c) + d + sum(e)
+}
+
+main :: proc () {
+	numbers := [5]int{1, 2, 3, 4, 5}
+	all := numbers[0..4]
+	print_int(all.length)
+	print_int(sum(all))
+	middle := numbers[1..3]
+	print_int(middle.length)
+	print_int(sum(middle))
+	middle[0] = 20
+	print_int(numbers[1])
+
+	inner := middle[1..2]
+	print_int(inner[0])
+	print_int(inner.length)
+
+	empty := numbers[2..1]
+	print_int(empty.length)
+	print_int(sum(empty))
+
+	n := 0
+	none := numbers[0..n-1]
+	print_int(none.length)
+	print_int(sum(none))
+	past := numbers[5..4]
+	print_int(past.length)
+	none = none[0..n-1]
+	print_int(none.length)
+	print_int(numbers[0..<0].length)
+	print_int(numbers[0..<n].length)
+	print_int(none[0..<n].length)
+	print_int(numbers[5..<5].length)
+	print_int(sum(numbers[0..<5]))
+
+	greeting := "hello world"
+	print_bytes(greeting[6..10])
+	print_bytes(greeting[0..4])
+
+	p := &numbers[2]
+	print_int(sum(p[0..2]))
+
+	var h holder
+	h.items = numbers[3..4]
+	print_int(h.items[1])
+	print_int(h.items.length)
+	hp := &h
+	print_int(hp.items[0])
+	hs := hp.items[0..0]
+	print_int(hs[0])
+
+	global_numbers[0] = 7
+	global_numbers[3] = 9
+	g := global_numbers[0..3]
+	print_int(sum(g))
+
+	points := [3]point{point{1, 2}, point{3, 4}, point{5, 6}}
+	ps := points[1..2]
+	print_int(ps[1].y)
+	ps[0].x = 30
+	print_int(points[1].x)
+
+	var s []int
+	print_int(s.length)
+	print_int(s[0..<0].length)
+	s = all
+	print_int(s[4])
+	print_int(many(1, all, middle, 2, inner))
+
+	lo := 1
+	hi := 2
+	print_int(sum(numbers[lo..hi]))
+	var idx u8
+	idx = 4
+	print_int(all[idx])
+}
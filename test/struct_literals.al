@@ -0,0 +1,73 @@
+struct point {
+    x int
+    y int
+}
+
+struct pixel {
+    tag u8
+    at point
+    brightness f32
+    weight f64
+    next *pixel
+}
+
+struct grid {
+    cells [4]u8
+    size s32
+}
+
+sum :: proc (p *point) -> int {
+    return p.x + p.y
+}
+
+main :: proc () {
+    a := point{x = 1, y = 2}
+    print_int(a.x)
+    print_int(a.y)
+
+    b := point{3, 4}
+    print_int(sum(&b))
+
+    c := point{y = 9}
+    print_int(c.x)
+    print_int(c.y)
+
+    empty := point{}
+    print_int(empty.x + empty.y)
+
+    n := 10
+    px := pixel{tag = 200, at = point{n, n * 2}, brightness = 0.5, weight = 2.25}
+    print_int(px.tag)
+    print_int(px.at.x)
+    print_int(px.at.y)
+    print_float(px.brightness)
+    print_float(px.weight)
+    if px.next == nil {
+        puts("next is nil\n")
+    }
+
+    other := pixel{1, b, 1.0, 3.0, &px}
+    print_int(other.next.at.y)
+    print_int(other.at.x)
+
+    var cells [4]u8
+    cells[2] = 7
+    g := grid{cells = cells, size = -3}
+    print_int(g.cells[2])
+    print_int(g.size + 5)
+
+    a = point{a.y, a.x}
+    print_int(a.x)
+    print_int(a.y)
+
+    nested := pixel{at = point{x = 100, y = 11}, tag = 1}
+    print_int(nested.at.x - nested.at.y)
+    print_int(point{5, 6}.y)
+
+    total := 0
+    for i := 1..3 {
+        p := point{i, i * 10}
+        total = total + sum(&p)
+    }
+    print_int(total)
+}
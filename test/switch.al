@@ -0,0 +1,91 @@
+enum Color : u8 {
+	Red,
+	Green,
+	Blue,
+}
+
+DOUBLE :: 2
+
+name :: proc (n int) -> string {
+	var s string
+	switch n {
+	case 0:
+		s = "zero"
+	case 1:
+		s = "one"
+	case 2, 3:
+		s = "two or three"
+	case 4:
+		s = "four"
+	case 5, 6:
+		s = "five or six"
+	default:
+		s = "many"
+	}
+	return s
+}
+
+sparse :: proc (n int) -> int {
+	result := 0
+	switch n {
+	case -1000:
+		result = 1
+	case 77:
+		result = 2
+	case 100000 * DOUBLE:
+		result = 3
+	}
+	return result
+}
+
+color_name :: proc (c Color) {
+	switch c {
+	case Color.Red:
+		puts("red\n")
+	case Color.Green, Color.Blue:
+		puts("green or blue\n")
+	}
+}
+
+main :: proc () {
+	for j := 0..9 {
+		i := j - 1
+		puts(name(i))
+		puts("\n")
+	}
+	print_int(sparse(-1000))
+	print_int(sparse(77))
+	print_int(sparse(200000))
+	print_int(sparse(5))
+	color_name(Color.Red)
+	color_name(Color.Blue)
+	total := 0
+	for i := 0..9 {
+		switch i % 3 {
+		case 0:
+			total = total + 100
+		case 1:
+			total = total + 10
+			continue
+		default:
+			total = total + 1
+		}
+		total = total + 1000
+	}
+	print_int(total)
+	known := 3
+	switch known {
+	case 1, 2:
+		puts("low\n")
+	case 3:
+		puts("three\n")
+	}
+	var b u8
+	b = 200
+	switch b {
+	case 200:
+		puts("two hundred\n")
+	case 1:
+		puts("one\n")
+	}
+}
@@ -0,0 +1,54 @@
+identity :: proc (a int) -> int {
+	return a
+}
+
+id8 :: proc (a s8) -> s8 {
+	return a
+}
+
+main :: proc () {
+	a := 0
+	b := 5
+	c := identity(7)
+	for i := 0..7 {
+		switch identity(i) {
+		case 0, 1, 2, 3:
+			a = a + c
+			b = b * 2
+		case 4:
+			a = a - 1
+		case 5, 6:
+			c = c + b
+		}
+		print_int(a + b + c)
+	}
+
+	var s s8
+	s = id8(-3)
+	switch s {
+	case -4:
+		puts("-4\n")
+	case -3:
+		puts("-3\n")
+	case -2, -1, 0:
+		puts("small\n")
+	}
+
+	x := identity(9)
+	y := 10
+	switch x {
+	case 1:
+		y = 1
+	case 2:
+		y = 2
+	}
+	print_int(y)
+
+	for i := 0..3 {
+		switch i {
+		case 2:
+			break
+		}
+		print_int(i)
+	}
+}
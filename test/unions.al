@@ -0,0 +1,51 @@
+union number {
+	whole s64
+	bytes [8]u8
+	half u32
+}
+
+union wide {
+	small u8
+	pair [3]u16
+}
+
+struct tagged {
+	tag u8
+	value number
+	after s64
+}
+
+main :: proc () {
+	var n number
+	n.whole = 0x0403_0201
+	print_int(n.bytes[0])
+	print_int(n.bytes[3])
+	print_int(n.half)
+	n.bytes[0] = 0xff
+	print_int(n.whole & 0xffff)
+
+	var w wide
+	w.pair[0] = 0xabcd
+	w.pair[2] = 7
+	print_int(w.small)
+	print_int(w.pair[2])
+
+	var t tagged
+	t.tag = 3
+	t.after = 99
+	t.value.whole = -1
+	print_int(t.tag)
+	print_int(t.value.bytes[3])
+	print_int(t.after)
+
+	var numbers [3]number
+	numbers[0].whole = 1
+	numbers[1].half = 2
+	numbers[2].bytes[1] = 1
+	print_int(numbers[0].bytes[0])
+	print_int(numbers[1].whole)
+	print_int(numbers[2].whole)
+
+	m := number{half = 5}
+	print_int(m.whole)
+}
@@ -3,6 +3,7 @@ package typing
 import (
 	"fmt"
 	"github.com/XrXr/alang/parsing"
+	"strings"
 )
 
 type TypeRecord interface {
@@ -26,10 +27,26 @@ type StringDataPointer struct{ normalType }
 func (_ StringDataPointer) Size() int {
 	return 8
 }
+
 func (_ StringDataPointer) Rep() string {
 	return "pointer-to-string-data"
 }
 
+// What a for-each loop's ir.StructMemberPtr gives for the data and length of an array. Loading through
+// it gives Member.
+type ArrayMemberPointer struct {
+	normalType
+	Member TypeRecord
+}
+
+func (_ ArrayMemberPointer) Size() int {
+	return 8
+}
+
+func (a ArrayMemberPointer) Rep() string {
+	return "*" + a.Member.Rep()
+}
+
 type Int struct{ integerType }
 
 func (_ Int) Size() int {
@@ -129,18 +146,39 @@ func (_ S64) Rep() string {
 	return "s64"
 }
 
+type F32 struct{ floatType }
+
+func (_ F32) Size() int {
+	return 4
+}
+func (_ F32) Rep() string {
+	return "f32"
+}
+
+type F64 struct{ floatType }
+
+func (_ F64) Size() int {
+	return 8
+}
+func (_ F64) Rep() string {
+	return "f64"
+}
+
 type StructField struct {
 	Type   TypeRecord
 	Offset int
 }
 
+// Unions are structs where all the members are at offset 0
 type StructRecord struct {
 	Name                   string
 	Members                map[string]*StructField
 	MemberOrder            []*StructField
+	IsUnion                bool
 	SizeAndOffsetsResolved bool
 	size                   int
 	alignment              int
+	resolving              bool
 	normalType
 }
 
@@ -155,6 +193,22 @@ func (s *StructRecord) ResolveSizeAndOffset() {
 	if s.SizeAndOffsetsResolved {
 		return
 	}
+	if s.resolving {
+		panic("Embed cycle is not allowed")
+	}
+	// instances of generic structs are not part of the embed graph, so we make sure whatever we
+	// embed is laid out first
+	s.resolving = true
+	for _, field := range s.MemberOrder {
+		embedded := field.Type
+		if array, isArray := embedded.(Array); isArray {
+			embedded = array.OfWhat
+		}
+		if embeddedStruct, isStruct := embedded.(*StructRecord); isStruct {
+			embeddedStruct.ResolveSizeAndOffset()
+		}
+	}
+	s.resolving = false
 	s.size = 0
 	if len(s.MemberOrder) == 0 {
 		s.SizeAndOffsetsResolved = true
@@ -170,13 +224,22 @@ func (s *StructRecord) ResolveSizeAndOffset() {
 			switch arrContentType := fieldType.OfWhat.(type) {
 			case *StructRecord:
 				alignment = arrContentType.alignment
+			case Slice:
+				alignment = 8
 			default:
 				alignment = arrContentType.Size()
 			}
 		case *StructRecord:
 			alignment = fieldType.alignment
+		case Slice:
+			alignment = 8
 		}
-		if i > 0 {
+		if s.IsUnion {
+			field.Offset = 0
+			if fieldSize > s.size {
+				s.size = fieldSize
+			}
+		} else if i > 0 {
 			if (s.size % alignment) == 0 {
 				s.MemberOrder[i].Offset = s.size
 			} else {
@@ -184,7 +247,9 @@ func (s *StructRecord) ResolveSizeAndOffset() {
 				s.MemberOrder[i].Offset = s.size - (s.size % alignment) + alignment
 			}
 		}
-		s.size = s.MemberOrder[i].Offset + fieldSize
+		if !s.IsUnion {
+			s.size = s.MemberOrder[i].Offset + fieldSize
+		}
 		if alignment > biggestAlignment {
 			biggestAlignment = alignment
 		}
@@ -199,7 +264,11 @@ func (s *StructRecord) ResolveSizeAndOffset() {
 }
 
 func (s *StructRecord) PrintLayout() {
-	fmt.Printf("struct \"%s\", size: %d, alignment: %d\n", s.Name, s.size, s.alignment)
+	kind := "struct"
+	if s.IsUnion {
+		kind = "union"
+	}
+	fmt.Printf("%s \"%s\", size: %d, alignment: %d\n", kind, s.Name, s.size, s.alignment)
 	for _, field := range s.MemberOrder {
 		var name string
 		for _name, _field := range s.Members {
@@ -211,6 +280,22 @@ func (s *StructRecord) PrintLayout() {
 	}
 }
 
+// Enums are integers that only mix with members of the same enum.
+// They count as numbers so the backend sizes and extends them like their backing type.
+type EnumRecord struct {
+	Name    string
+	Backing TypeRecord
+	Members map[string]int64
+	integerType
+}
+
+func (e *EnumRecord) Size() int {
+	return e.Backing.Size()
+}
+func (e *EnumRecord) Rep() string {
+	return e.Name
+}
+
 type Unresolved struct {
 	normalType
 	Decl parsing.TypeDecl
@@ -255,6 +340,105 @@ func (a Array) Rep() string {
 	return fmt.Sprintf("[%d]%s", a.Nesting[0], a.OfWhat.Rep())
 }
 
+// Slices are a pointer to the first element followed by the number of elements.
+type Slice struct {
+	normalType
+	OfWhat TypeRecord
+}
+
+func (_ Slice) Size() int {
+	return 16
+}
+
+func (s Slice) Rep() string {
+	return "[]" + s.OfWhat.Rep()
+}
+
+// Members lays out .data and .length the same way a struct would
+func (s Slice) Members() *StructRecord {
+	data := &StructField{Type: Pointer{ToWhat: s.OfWhat}, Offset: 0}
+	length := &StructField{Type: Int{}, Offset: 8}
+	return &StructRecord{
+		Name:                   s.Rep(),
+		Members:                map[string]*StructField{"data": data, "length": length},
+		MemberOrder:            []*StructField{data, length},
+		SizeAndOffsetsResolved: true,
+		size:                   16,
+		alignment:              8,
+	}
+}
+
+// AsStruct gives the layout of types that have members
+func AsStruct(record TypeRecord) (*StructRecord, bool) {
+	switch record := record.(type) {
+	case *StructRecord:
+		return record, true
+	case Slice:
+		return record.Members(), true
+	}
+	return nil, false
+}
+
+// ProcPointer is the address of a proc. Calling through one checks arguments the same way calling by name does.
+type ProcPointer struct {
+	normalType
+	Args   []TypeRecord
+	Return *TypeRecord
+}
+
+func (_ ProcPointer) Size() int {
+	return 8
+}
+
+func (p ProcPointer) Rep() string {
+	args := make([]string, len(p.Args))
+	for i, arg := range p.Args {
+		args[i] = arg.Rep()
+	}
+	rep := "proc(" + strings.Join(args, ", ") + ")"
+	if _, returnsVoid := (*p.Return).(Void); !returnsVoid {
+		rep += " -> " + (*p.Return).Rep()
+	}
+	return rep
+}
+
+// ir.PeelStruct gives the value of members that are addresses instead of a pointer to the member
+func PeeledByValue(member TypeRecord) bool {
+	switch member.(type) {
+	case Pointer, ProcPointer:
+		return true
+	}
+	return false
+}
+
+// Tuple is the return type of procs that return more than one value. No var has this type.
+// When the values come back through memory each of them starts on an 8 byte boundary.
+type Tuple struct {
+	normalType
+	Types []TypeRecord
+}
+
+func (t Tuple) Size() int {
+	return t.Offset(len(t.Types))
+}
+
+// Offset returns where the ith value starts. Passing the number of values gives the size of the whole tuple.
+func (t Tuple) Offset(i int) int {
+	offset := 0
+	for _, record := range t.Types[:i] {
+		offset += (record.Size() + 7) / 8 * 8
+	}
+	return offset
+}
+
+func (t Tuple) Rep() string {
+	reps := make([]string, len(t.Types))
+	for i, record := range t.Types {
+		reps[i] = record.Rep()
+	}
+	return "(" + strings.Join(reps, ", ") + ")"
+}
+
 type normalType struct{}
 
 func (_ normalType) IsNumber() bool {
@@ -266,3 +450,9 @@ type integerType struct{}
 func (_ integerType) IsNumber() bool {
 	return true
 }
+
+type floatType struct{}
+
+func (_ floatType) IsNumber() bool {
+	return true
+}
@@ -14,16 +14,119 @@ type ProcRecord struct {
 	IsForeign bool
 }
 
+// Procs with the same name are overloads of each other. They are in the order they are declared in.
 type EnvRecord struct {
-	Procs map[string]ProcRecord
-	Types map[string]TypeRecord
+	Procs          map[string][]ProcRecord
+	Types          map[string]TypeRecord
+	Globals        map[string]TypeRecord
+	GenericStructs map[string]*GenericStruct
+}
+
+// WithTypeArgs gives an environment where the type parameters name the types in args. Everything else
+// is shared with env.
+func (env *EnvRecord) WithTypeArgs(params []string, args []TypeRecord) *EnvRecord {
+	scoped := *env
+	scoped.Types = make(map[string]TypeRecord, len(env.Types)+len(params))
+	for name, record := range env.Types {
+		scoped.Types[name] = record
+	}
+	for i, name := range params {
+		scoped.Types[name] = args[i]
+	}
+	return &scoped
+}
+
+// GenericStruct is a struct declared with type parameters. Each list of type arguments it's used with
+// makes a separate struct.
+type GenericStruct struct {
+	Name       string
+	TypeParams []string
+	FieldNames []string
+	FieldDecls []parsing.TypeDecl
+	IsUnion    bool
+	Instances  map[string]*StructRecord // keyed by name, as in list(int)
+}
+
+func (g *GenericStruct) instantiate(t *Typer, env *EnvRecord, args []TypeRecord) (*StructRecord, error) {
+	name := g.Name + RepForListOfTypes(args, nil)
+	if instance, exists := g.Instances[name]; exists {
+		return instance, nil
+	}
+	instance := &StructRecord{Name: name, Members: make(map[string]*StructField), IsUnion: g.IsUnion}
+	// register it before doing the fields so the fields can point to it
+	g.Instances[name] = instance
+	scoped := env.WithTypeArgs(g.TypeParams, args)
+	for i, decl := range g.FieldDecls {
+		field := &StructField{Type: t.TypeRecordFromDecl(decl)}
+		if err := t.ResolveUserTypes(scoped, &field.Type); err != nil {
+			return nil, err
+		}
+		instance.Members[g.FieldNames[i]] = field
+		instance.MemberOrder = append(instance.MemberOrder, field)
+	}
+	return instance, nil
+}
+
+// ResolveInstanceSizes lays out the instances of generic structs that are not laid out yet.
+// This needs to wait until all the other structs are laid out.
+func (env *EnvRecord) ResolveInstanceSizes() {
+	for _, generic := range env.GenericStructs {
+		for _, instance := range generic.Instances {
+			instance.ResolveSizeAndOffset()
+		}
+	}
+}
+
+// ResolveUserTypes fills in the unresolved user types inside record, making instances of generic structs
+// as needed.
+func (t *Typer) ResolveUserTypes(env *EnvRecord, record *TypeRecord) error {
+	var err error
+	ForEachUnresolved(record, func(slot *TypeRecord) {
+		if err != nil {
+			return
+		}
+		unresolved := (*slot).(Unresolved)
+		decl := innermostDecl(unresolved.Decl)
+		name := decl.Base.Name
+		var base TypeRecord
+		if generic, isGeneric := env.GenericStructs[name]; isGeneric {
+			if len(decl.TypeArgs) != len(generic.TypeParams) {
+				err = fmt.Errorf("%s takes %d type arguments", name, len(generic.TypeParams))
+				return
+			}
+			args := make([]TypeRecord, len(decl.TypeArgs))
+			for i, argDecl := range decl.TypeArgs {
+				args[i] = t.TypeRecordFromDecl(argDecl)
+				if err = t.ResolveUserTypes(env, &args[i]); err != nil {
+					return
+				}
+			}
+			base, err = generic.instantiate(t, env, args)
+			if err != nil {
+				return
+			}
+		} else {
+			var found bool
+			base, found = env.Types[name]
+			if !found {
+				err = fmt.Errorf(`"%s" does not name a type`, name)
+				return
+			}
+			if len(decl.TypeArgs) > 0 {
+				err = fmt.Errorf("%s doesn't take type arguments", name)
+				return
+			}
+		}
+		*slot = BuildRecordAccordingToUnresolved(base, unresolved)
+	})
+	return err
 }
 
 type Typer struct {
 	Builtins []TypeRecord
 }
 
-func (t *Typer) checkAndInferOpt(env *EnvRecord, opt ir.Inst, typeTable []TypeRecord) error {
+func (t *Typer) checkAndInferOpt(env *EnvRecord, currentProc ProcRecord, opt *ir.Inst, typeTable []TypeRecord) error {
 	bail := func(message string) {
 		panic(parsing.ErrorFromNode(opt.GeneratedFrom, message))
 	}
@@ -58,7 +161,10 @@ func (t *Typer) checkAndInferOpt(env *EnvRecord, opt ir.Inst, typeTable []TypeRe
 		if baseIsPointer {
 			baseType = basePointer.ToWhat
 		}
-		baseStruct, baseIsStruct := baseType.(*StructRecord)
+		if _, baseIsSlice := baseType.(Slice); baseIsSlice && fieldName != "data" && fieldName != "length" {
+			bailRight("Slices don't have this field")
+		}
+		baseStruct, baseIsStruct := AsStruct(baseType)
 		baseIsString := baseType == t.Builtins[StringIdx]
 
 		if !baseIsStruct && !baseIsString {
@@ -84,6 +190,13 @@ func (t *Typer) checkAndInferOpt(env *EnvRecord, opt ir.Inst, typeTable []TypeRe
 		panic("ice: encountered a struct member that we don't know how to find the type of")
 		return nil
 	}
+	resolveUserType := func(record TypeRecord) TypeRecord {
+		if err := t.ResolveUserTypes(env, &record); err != nil {
+			bail(err.Error())
+		}
+		env.ResolveInstanceSizes()
+		return record
+	}
 	giveTypeOrVerify := func(target int, typeRecord TypeRecord) {
 		currentType := typeTable[target]
 		if currentType == nil {
@@ -94,31 +207,73 @@ func (t *Typer) checkAndInferOpt(env *EnvRecord, opt ir.Inst, typeTable []TypeRe
 	}
 	switch opt.Type {
 	case ir.AssignImm:
-		finalType := t.typeImmediate(opt.Extra)
-		if unresolved, isUnresolved := finalType.(Unresolved); isUnresolved {
-			name := GrabUnresolvedName(unresolved)
-			structRecord, ok := env.Types[name]
-			if !ok {
-				bail(fmt.Sprintf(`"%s" does not name a type`, name))
-			}
-			finalType = BuildRecordAccordingToUnresolved(structRecord, unresolved)
-		}
-		giveTypeOrVerify(opt.Out(), finalType)
+		giveTypeOrVerify(opt.Out(), resolveUserType(t.TypeImmediate(opt.Extra)))
 	case ir.TakeAddress:
 		varType := mustHaveType(opt.In())
 		typeTable[opt.Out()] = Pointer{ToWhat: varType}
+	case ir.GlobalAddress:
+		giveTypeOrVerify(opt.Out(), Pointer{ToWhat: env.Globals[opt.Extra.(string)]})
+	case ir.GlobalBase:
+		// arrays and structs are used in place. Other globals are used through their value.
+		global := mustHaveType(opt.In()).(Pointer).ToWhat
+		switch global.(type) {
+		case Array, *StructRecord:
+			opt.Type = ir.Assign
+			giveTypeOrVerify(opt.Out(), Pointer{ToWhat: global})
+		default:
+			opt.Type = ir.IndirectLoad
+			giveTypeOrVerify(opt.Out(), global)
+		}
+	case ir.ProcAddress:
+		overloads, isProc := env.Procs[opt.Extra.(string)]
+		if !isProc {
+			bailRight("Undefined name")
+		}
+		if len(overloads) > 1 {
+			bailRight("Can't take the address of an overloaded proc")
+		}
+		procRecord := overloads[0]
+		if procRecord.IsForeign {
+			bailRight("Can't take the address of a foreign proc")
+		}
+		giveTypeOrVerify(opt.Out(), ProcPointer{Args: procRecord.Args, Return: procRecord.Return})
 	case ir.PeelStruct:
 		fieldName := opt.Extra.(string)
 		fieldType := checkAndFindStructMemberType(opt.In(), fieldName)
-		_, fieldIsPointer := fieldType.(Pointer)
 		var outType TypeRecord
-		if fieldIsPointer {
+		if PeeledByValue(fieldType) {
 			outType = fieldType
 		} else {
 			outType = Pointer{ToWhat: fieldType}
 		}
 		giveTypeOrVerify(opt.Out(), outType)
 	case ir.StructMemberPtr:
+		baseType := mustHaveType(opt.In())
+		if pointer, isPointer := baseType.(Pointer); isPointer {
+			baseType = pointer.ToWhat
+		}
+		member, forEach := opt.Extra.(ir.ForEachMemberExtra)
+		if forEach {
+			switch baseType.(type) {
+			case Array, Slice, String:
+			default:
+				bail(fmt.Sprintf("Can't loop over %s", mustHaveType(opt.In()).Rep()))
+			}
+			opt.Extra = member.Name
+		}
+		// arrays don't keep their data pointer and length in memory. For loops both are computed in
+		// place and the load through the member pointer becomes a copy.
+		if array, isArray := baseType.(Array); isArray && forEach {
+			if member.Name == "data" {
+				opt.Type = ir.ArrayToPointer
+				giveTypeOrVerify(opt.Out(), ArrayMemberPointer{Member: Pointer{ToWhat: array.OfWhat}})
+			} else {
+				opt.Type = ir.AssignImm
+				opt.Extra = int64(array.Size() / array.OfWhat.Size())
+				giveTypeOrVerify(opt.Out(), ArrayMemberPointer{Member: t.Builtins[IntIdx]})
+			}
+			break
+		}
 		getDoublePtrToStringData := false
 		if opt.Extra.(string) == "data" {
 			switch inType := typeTable[opt.In()].(type) {
@@ -136,12 +291,63 @@ func (t *Typer) checkAndInferOpt(env *EnvRecord, opt ir.Inst, typeTable []TypeRe
 			outType = Pointer{ToWhat: outType}
 			giveTypeOrVerify(opt.Out(), outType)
 		}
+	case ir.MethodReceiver:
+		// pointers to structs are auto-dereferenced the same way they are for field access
+		baseType := mustHaveType(opt.In())
+		basePointer, baseIsPointer := baseType.(Pointer)
+		if baseIsPointer {
+			baseType = basePointer.ToWhat
+		}
+		baseStruct, baseIsStruct := baseType.(*StructRecord)
+		if !baseIsStruct {
+			bail(fmt.Sprintf("Method call on a non struct (%s)", typeTable[opt.In()].Rep()))
+		}
+		overloads, found := env.Procs[baseStruct.Name+"."+opt.Extra.(string)]
+		if !found {
+			// calling a member that holds a proc goes through the pointer in it
+			if field, isMember := baseStruct.Members[opt.Extra.(string)]; isMember {
+				if _, isProcPointer := field.Type.(ProcPointer); isProcPointer {
+					opt.Type = ir.PeelStruct
+					giveTypeOrVerify(opt.Out(), field.Type)
+					break
+				}
+			}
+			bailRight(fmt.Sprintf("Not a method of struct %s", baseStruct.Name))
+		}
+		// every overload takes the receiver the same way
+		receiverType := overloads[0].Args[0]
+		_, wantsPointer := receiverType.(Pointer)
+		switch {
+		case wantsPointer && !baseIsPointer:
+			opt.Type = ir.TakeAddress
+		case !wantsPointer && baseIsPointer:
+			opt.Type = ir.IndirectLoad
+		default:
+			opt.Type = ir.Assign
+		}
+		giveTypeOrVerify(opt.Out(), receiverType)
 	case ir.Call:
 		out := opt.Out()
 		extra := opt.Extra.(ir.CallExtra)
+		if extra.Method {
+			receiverType := mustHaveType(extra.ArgVars[0])
+			if _, isMember := receiverType.(ProcPointer); isMember {
+				// ir.MethodReceiver found a member instead of a method. The member isn't an argument.
+				extra.Indirect = true
+				extra.ProcVar = extra.ArgVars[0]
+				extra.ArgVars = extra.ArgVars[1:]
+			} else {
+				if pointer, isPointer := receiverType.(Pointer); isPointer {
+					receiverType = pointer.ToWhat
+				}
+				extra.Name = receiverType.(*StructRecord).Name + "." + extra.Name
+			}
+			extra.Method = false
+			opt.Extra = extra
+		}
 		callee := extra.Name
 		typeRecord, callToType := env.Types[callee]
-		if callToType {
+		if callToType && !extra.Indirect {
 			switch record := typeRecord.(type) {
 			case *StructRecord:
 				// making a struct
@@ -151,25 +357,66 @@ func (t *Typer) checkAndInferOpt(env *EnvRecord, opt ir.Inst, typeTable []TypeRe
 				if len(extra.ArgVars) != 1 {
 					bail("Type casting only operates on one operand")
 				}
-				if typeRecord.Size() != typeTable[extra.ArgVars[0]].Size() {
-					bail("Invalid cast: size of the types must match")
+				argType := typeTable[extra.ArgVars[0]]
+				if !t.castable(argType, typeRecord) {
+					bail(fmt.Sprintf("Invalid cast: %s to %s", argType.Rep(), typeRecord.Rep()))
 				}
 				giveTypeOrVerify(out, typeRecord)
 			}
 		} else {
-			procRecord, ok := env.Procs[callee]
-			if !ok {
+			overloads, ok := env.Procs[callee]
+			var procRecord ProcRecord
+			if extra.Indirect {
+				pointer, isProcPointer := mustHaveType(extra.ProcVar).(ProcPointer)
+				if !isProcPointer && callee == "" {
+					// calling the value of an expression
+					bail(fmt.Sprintf("Can't call a value of type %s", typeTable[extra.ProcVar].Rep()))
+				}
+				if !isProcPointer {
+					bail(fmt.Sprintf("%s is not a proc", callee))
+				}
+				procRecord = ProcRecord{Return: pointer.Return, Args: pointer.Args}
+			} else if !ok {
 				bail("Call to undefined procedure " + extra.Name)
 			}
 			failed := false
 			var message string
+			for _, vn := range extra.ArgVars {
+				mustHaveType(vn)
+			}
+			// the compiler already picked the instance for calls to generic procs
+			if !extra.Indirect && len(extra.TypeArgs) == 0 && len(overloads) > 1 {
+				passed := make([]TypeRecord, 0, len(extra.ArgVars))
+				for _, vn := range extra.ArgVars {
+					passed = append(passed, typeTable[vn])
+				}
+				best, candidates := t.pickOverload(overloads, passed)
+				if best == -1 {
+					message = "No overload of " + callee + " takes these arguments"
+					if len(candidates) > 1 {
+						message = "Ambiguous call to " + callee
+					} else {
+						candidates = candidates[:0]
+						for i := range overloads {
+							candidates = append(candidates, i)
+						}
+					}
+					for _, i := range candidates {
+						message += "\n    could be " + RepForListOfTypes(overloads[i].Args, nil)
+					}
+					message += "\n    have     " + RepForListOfTypes(passed, nil)
+					bail(message)
+				}
+				extra.Overload = best
+				opt.Extra = extra
+			}
+			if !extra.Indirect {
+				procRecord = overloads[extra.Overload]
+			}
 			if len(extra.ArgVars) != len(procRecord.Args) {
 				failed = true
 				message = "Wrong number of arguments"
 			}
-			for _, vn := range extra.ArgVars {
-				mustHaveType(vn)
-			}
 			if !failed {
 				for i, vn := range extra.ArgVars {
 					if !t.Assignable(typeTable[vn], procRecord.Args[i]) {
@@ -198,19 +445,83 @@ func (t *Typer) checkAndInferOpt(env *EnvRecord, opt ir.Inst, typeTable []TypeRe
 				bail(message)
 			}
 
+			if tuple, isTuple := (*procRecord.Return).(Tuple); isTuple {
+				if len(extra.ReturnTo)+1 != len(tuple.Types) {
+					bail(fmt.Sprintf("%s returns %d values", callee, len(tuple.Types)))
+				}
+				giveTypeOrVerify(out, tuple.Types[0])
+				for i, vn := range extra.ReturnTo {
+					giveTypeOrVerify(vn, tuple.Types[i+1])
+				}
+				return nil
+			}
+			if len(extra.ReturnTo) > 0 {
+				bail(fmt.Sprintf("%s only returns one value", callee))
+			}
 			giveTypeOrVerify(out, *procRecord.Return)
 			return nil
 		}
+	case ir.Return:
+		values := opt.Extra.(ir.ReturnExtra).Values
+		tuple, isTuple := (*currentProc.Return).(Tuple)
+		if !isTuple {
+			if len(values) > 1 {
+				bail("This proc only returns one value")
+			}
+			break
+		}
+		if len(values) != len(tuple.Types) {
+			bail(fmt.Sprintf("Wrong number of return values. Want %d, have %d", len(tuple.Types), len(values)))
+		}
+		for i, vn := range values {
+			if valueType := mustHaveType(vn); !t.Assignable(tuple.Types[i], valueType) {
+				bail(fmt.Sprintf("Type mismatch: returning %s in place of %s", valueType.Rep(), tuple.Types[i].Rep()))
+			}
+		}
+	case ir.StructLiteral:
+		extra := opt.Extra.(ir.StructLiteralExtra)
+		literal := opt.GeneratedFrom.(parsing.StructLiteral)
+		record, isStruct := env.Types[extra.Type].(*StructRecord)
+		if !isStruct {
+			panic(parsing.ErrorFromNode(literal.Type, fmt.Sprintf(`"%s" is not a struct`, extra.Type)))
+		}
+		fields := StructLiteralFields(record, extra.Names, literal)
+		for i, vn := range extra.Values {
+			field := fields[i]
+			if valueType := mustHaveType(vn); !t.Assignable(field.Type, valueType) {
+				panic(parsing.ErrorFromNode(literal.Values[i], fmt.Sprintf("Type mismatch: using a value of type %s for a member of type %s", valueType.Rep(), field.Type.Rep())))
+			}
+		}
+		giveTypeOrVerify(opt.Out(), record)
+	case ir.Cast:
+		to := resolveUserType(t.TypeRecordFromDecl(opt.Extra.(parsing.TypeDecl)))
+		from := mustHaveType(opt.In())
+		if !t.castable(from, to) {
+			bail(fmt.Sprintf("Invalid cast: %s to %s", from.Rep(), to.Rep()))
+		}
+		giveTypeOrVerify(opt.Out(), to)
+	case ir.ArrayLiteral:
+		extra := opt.Extra.(ir.ArrayLiteralExtra)
+		literal := opt.GeneratedFrom.(parsing.ArrayLiteral)
+		array := resolveUserType(t.TypeRecordFromDecl(extra.Type)).(Array)
+		checkArrayLiteralLength(array, literal)
+		for i, vn := range extra.Values {
+			if valueType := mustHaveType(vn); !t.Assignable(array.OfWhat, valueType) {
+				panic(parsing.ErrorFromNode(literal.Values[i], fmt.Sprintf("Type mismatch: using a value of type %s for an element of type %s", valueType.Rep(), array.OfWhat.Rep())))
+			}
+		}
+		giveTypeOrVerify(opt.Out(), array)
 	case ir.Compare:
 		extra := opt.Extra.(ir.CompareExtra)
 		l := mustHaveType(opt.In())
 		r := mustHaveType(extra.Right)
-		if !(l.IsNumber() && r.IsNumber()) {
-			good := false
+		if !t.sameKindOfNumber(l, r) {
+			_, lIsEnum := l.(*EnumRecord)
+			good := lIsEnum && l == r
 			if extra.How == ir.AreEqual || extra.How == ir.NotEqual {
 				_, lIsBool := l.(Boolean)
 				_, rIsBool := r.(Boolean)
-				good = lIsBool && rIsBool
+				good = good || (lIsBool && rIsBool)
 				_, lIsPointer := l.(Pointer)
 				_, rIsPointer := r.(Pointer)
 				good = good || (lIsPointer && rIsPointer)
@@ -220,6 +531,33 @@ func (t *Typer) checkAndInferOpt(env *EnvRecord, opt ir.Inst, typeTable []TypeRe
 			}
 		}
 		typeTable[extra.Out] = t.Builtins[BoolIdx]
+	case ir.Switch:
+		valueType := mustHaveType(opt.In())
+		enum, isEnum := valueType.(*EnumRecord)
+		if !isEnum && !t.isInteger(valueType) {
+			bail(fmt.Sprintf("Can only switch on integers and enums, not %s", valueType.Rep()))
+		}
+		seen := make(map[int64]bool)
+		for _, switchCase := range opt.Extra.(ir.SwitchExtra).Cases {
+			for _, value := range switchCase.Values {
+				bailValue := func(message string) {
+					panic(parsing.ErrorFromNode(value.From, message))
+				}
+				if isEnum && value.Enum != enum.Name {
+					bailValue(fmt.Sprintf("Expected a member of enum %s", enum.Name))
+				}
+				if !isEnum && value.Enum != "" {
+					bailValue(fmt.Sprintf("Can't use a member of enum %s as a case for %s", value.Enum, valueType.Rep()))
+				}
+				if !t.IntegerCanHold(valueType, value.Value) {
+					bailValue(fmt.Sprintf("Case value doesn't fit in %s", valueType.Rep()))
+				}
+				if seen[value.Value] {
+					bailValue("Duplicate case")
+				}
+				seen[value.Value] = true
+			}
+		}
 	case ir.IndirectWrite:
 		// This ir is special in that it puts a variable that it doesn't mutate in MutateOperand.
 		// If we start doing more sophisticated analysis we might want to change that.
@@ -241,6 +579,9 @@ func (t *Typer) checkAndInferOpt(env *EnvRecord, opt ir.Inst, typeTable []TypeRe
 		switch record := ptrType.(type) {
 		case StringDataPointer:
 			giveTypeOrVerify(opt.Out(), Pointer{ToWhat: t.Builtins[U8Idx]})
+		case ArrayMemberPointer:
+			opt.Type = ir.Assign
+			giveTypeOrVerify(opt.Out(), record.Member)
 		case Pointer:
 			if isVoidPointer(record) {
 				bail("Indirecting a void pointer")
@@ -257,20 +598,56 @@ func (t *Typer) checkAndInferOpt(env *EnvRecord, opt ir.Inst, typeTable []TypeRe
 		case Array:
 			good = true
 			typeTable[opt.Out()] = Pointer{ToWhat: array.OfWhat}
+		case Slice:
+			good = true
+			typeTable[opt.Out()] = Pointer{ToWhat: array.OfWhat}
 		case Pointer:
-			if array, isArray := array.ToWhat.(Array); isArray {
+			switch pointee := array.ToWhat.(type) {
+			case Array:
+				good = true
+				typeTable[opt.Out()] = Pointer{ToWhat: pointee.OfWhat}
+			case Slice:
 				good = true
-				typeTable[opt.Out()] = Pointer{ToWhat: array.OfWhat}
+				typeTable[opt.Out()] = Pointer{ToWhat: pointee.OfWhat}
 			}
 		}
 		if !good {
 			bail("Array access on non array")
 		}
+	case ir.TakeSlice:
+		extra := opt.Extra.(ir.TakeSliceExtra)
+		var elementType TypeRecord
+		baseType := mustHaveType(opt.In())
+		if pointer, isPointer := baseType.(Pointer); isPointer {
+			if isVoidPointer(pointer) {
+				bail("Slicing a void pointer")
+			}
+			baseType = pointer.ToWhat
+			// slicing a pointer to a single value takes the value and the ones after it
+			elementType = baseType
+		}
+		switch base := baseType.(type) {
+		case Array:
+			elementType = base.OfWhat
+		case Slice:
+			elementType = base.OfWhat
+		case String:
+			elementType = t.Builtins[U8Idx]
+		}
+		if elementType == nil {
+			bail(fmt.Sprintf("Can't slice %s", baseType.Rep()))
+		}
+		for _, vn := range []int{extra.Low, extra.High} {
+			if !t.isInteger(mustHaveType(vn)) {
+				bail("Slice bounds must be integers")
+			}
+		}
+		giveTypeOrVerify(opt.Out(), Slice{OfWhat: elementType})
 	case ir.Add:
-		l, r := resolve(opt)
+		l, r := resolve(*opt)
 		lPointer, lIsPointer := l.(Pointer)
-		if !(lIsPointer && r.IsNumber()) {
-			if !(l.IsNumber() && r.IsNumber()) {
+		if !(lIsPointer && t.isInteger(r)) {
+			if !t.sameKindOfNumber(l, r) {
 				bail(fmt.Sprintf("Can't add to %s with %s", l.Rep(), r.Rep()))
 			}
 		}
@@ -278,17 +655,31 @@ func (t *Typer) checkAndInferOpt(env *EnvRecord, opt ir.Inst, typeTable []TypeRe
 			bail("Pointer arithmethic on void pointer")
 		}
 	case ir.Sub, ir.Mult, ir.Div:
-		l, r := resolve(opt)
+		l, r := resolve(*opt)
 		if !(l.IsNumber() && r.IsNumber()) {
 			bail("Operands must be numbers")
 		}
+		if !t.sameKindOfNumber(l, r) {
+			bail(fmt.Sprintf("Can't mix %s and %s without a cast", l.Rep(), r.Rep()))
+		}
+	case ir.Mod, ir.BitAnd, ir.BitOr, ir.BitXor, ir.ShiftLeft, ir.ShiftRight:
+		l, r := resolve(*opt)
+		if !(t.isInteger(l) && t.isInteger(r)) {
+			bail("Operands must be integers")
+		}
 	case ir.And, ir.Or:
-		l, r := resolve(opt)
+		l, r := resolve(*opt)
 		_, lIsBool := l.(Boolean)
 		_, rIsBool := r.(Boolean)
 		if !lIsBool || !rIsBool {
 			bail("Operands must be booleans")
 		}
+	case ir.BitNot:
+		inT := mustHaveType(opt.In())
+		if !t.isInteger(inT) {
+			bail("The bitwise not operator only works with integers")
+		}
+		giveTypeOrVerify(opt.Out(), inT)
 	case ir.Not:
 		inT := typeTable[opt.In()]
 		_, inIsPtr := inT.(Pointer)
@@ -309,10 +700,8 @@ func (t *Typer) InferAndCheck(env *EnvRecord, toCheck *frontend.OptBlock, procDe
 		typeTable[i] = arg
 	}
 
-	for i, opt := range toCheck.Opts {
-		_ = i
-
-		err := t.checkAndInferOpt(env, opt, typeTable)
+	for i := range toCheck.Opts {
+		err := t.checkAndInferOpt(env, procDecl, &toCheck.Opts[i], typeTable)
 		if err != nil {
 			return nil, err
 		}
@@ -322,6 +711,9 @@ func (t *Typer) InferAndCheck(env *EnvRecord, toCheck *frontend.OptBlock, procDe
 }
 
 func (t *Typer) IsUnsigned(record TypeRecord) bool {
+	if enum, isEnum := record.(*EnumRecord); isEnum {
+		return t.IsUnsigned(enum.Backing)
+	}
 	switch record {
 	case t.Builtins[U8Idx], t.Builtins[U32Idx], t.Builtins[U16Idx], t.Builtins[U64Idx]:
 		return true
@@ -329,10 +721,155 @@ func (t *Typer) IsUnsigned(record TypeRecord) bool {
 	return false
 }
 
-func (t *Typer) typeImmediate(val interface{}) TypeRecord {
+func (t *Typer) IsFloat(record TypeRecord) bool {
+	switch record {
+	case t.Builtins[F32Idx], t.Builtins[F64Idx]:
+		return true
+	}
+	return false
+}
+
+// integers and floats don't mix without a cast. Neither do enums.
+func (t *Typer) sameKindOfNumber(a, b TypeRecord) bool {
+	_, aIsEnum := a.(*EnumRecord)
+	_, bIsEnum := b.(*EnumRecord)
+	return a.IsNumber() && b.IsNumber() && t.IsFloat(a) == t.IsFloat(b) && !aIsEnum && !bIsEnum
+}
+
+func (t *Typer) isInteger(record TypeRecord) bool {
+	_, isEnum := record.(*EnumRecord)
+	return record.IsNumber() && !t.IsFloat(record) && !isEnum
+}
+
+// Integers widen and narrow to each other and bools go to and from integers. Other casts, like the ones
+// between pointers and u64, reinterpret the bytes and need the sizes to match.
+func (t *Typer) castable(from, to TypeRecord) bool {
+	if t.Assignable(to, from) {
+		return true
+	}
+	_, toEnum := to.(*EnumRecord)
+	_, fromEnum := from.(*EnumRecord)
+	if toEnum || fromEnum {
+		return t.isInteger(to) || t.isInteger(from)
+	}
+	if to.IsNumber() && from.IsNumber() {
+		return true
+	}
+	_, toBool := to.(Boolean)
+	_, fromBool := from.(Boolean)
+	if toBool && t.isInteger(from) || fromBool && t.isInteger(to) {
+		return true
+	}
+	return to.Size() == from.Size() && !t.IsFloat(to) && !t.IsFloat(from)
+}
+
+// StructLiteralFields gives the member of record each value in the literal goes into. names are the
+// names in the literal and are empty when the values are in declaration order.
+func StructLiteralFields(record *StructRecord, names []string, literal parsing.StructLiteral) []*StructField {
+	if record.IsUnion && len(literal.Values) > 1 {
+		panic(parsing.ErrorFromNode(literal.Values[1], fmt.Sprintf("Only one member of union %s can be given", record.Name)))
+	}
+	if len(names) == 0 && len(literal.Values) > len(record.MemberOrder) {
+		panic(parsing.ErrorFromNode(literal.Values[len(record.MemberOrder)], fmt.Sprintf("Too many values for struct %s", record.Name)))
+	}
+	fields := make([]*StructField, len(literal.Values))
+	seen := make(map[string]bool)
+	for i := range literal.Values {
+		if len(names) == 0 {
+			fields[i] = record.MemberOrder[i]
+			continue
+		}
+		name := names[i]
+		field, isMember := record.Members[name]
+		if !isMember {
+			panic(parsing.ErrorFromNode(literal.Names[i], fmt.Sprintf("Not a member of struct %s", record.Name)))
+		}
+		if seen[name] {
+			panic(parsing.ErrorFromNode(literal.Names[i], fmt.Sprintf("Member of struct %s given more than once", record.Name)))
+		}
+		seen[name] = true
+		fields[i] = field
+	}
+	return fields
+}
+
+// nested arrays are filled in as if they were flat, the same way indexing them works
+func checkArrayLiteralLength(array Array, literal parsing.ArrayLiteral) {
+	if length := array.Size() / array.OfWhat.Size(); len(literal.Values) > length {
+		panic(parsing.ErrorFromNode(literal.Values[length], fmt.Sprintf("Too many values for %s", array.Rep())))
+	}
+}
+
+// CheckGlobalValue makes sure the initial value of a global can go into a slot of type record.
+// The values in array and struct literals are checked against the element or member they go into.
+func (t *Typer) CheckGlobalValue(env *EnvRecord, record TypeRecord, value interface{}, node parsing.ASTNode) {
+	composite, isComposite := value.(frontend.CompositeValue)
+	if !isComposite {
+		var fits bool
+		switch value := value.(type) {
+		case int64:
+			if t.isInteger(record) && !t.IntegerCanHold(record, value) {
+				panic(parsing.ErrorFromNode(node, fmt.Sprintf("Value doesn't fit in %s", record.Rep())))
+			}
+			fits = t.isInteger(record)
+		case uint64:
+			fits = t.isInteger(record) && record.Size() == 8
+		case uint8:
+			fits = t.isInteger(record)
+		case float64:
+			fits = t.IsFloat(record)
+		default:
+			fits = record == t.TypeImmediate(value)
+		}
+		if !fits {
+			panic(parsing.ErrorFromNode(node, fmt.Sprintf("Type mismatch: using a value of type %s where %s is expected", t.TypeImmediate(value).Rep(), record.Rep())))
+		}
+		return
+	}
+	given := t.TypeRecordFromDecl(composite.Type)
+	if err := t.ResolveUserTypes(env, &given); err != nil {
+		panic(parsing.ErrorFromNode(composite.Literal, err.Error()))
+	}
+	if !reflect.DeepEqual(given, record) {
+		panic(parsing.ErrorFromNode(node, fmt.Sprintf("Type mismatch: using a value of type %s where %s is expected", given.Rep(), record.Rep())))
+	}
+	switch record := record.(type) {
+	case Array:
+		literal := composite.Literal.(parsing.ArrayLiteral)
+		checkArrayLiteralLength(record, literal)
+		for i, element := range composite.Values {
+			t.CheckGlobalValue(env, record.OfWhat, element, literal.Values[i])
+		}
+	case *StructRecord:
+		literal := composite.Literal.(parsing.StructLiteral)
+		for i, field := range StructLiteralFields(record, composite.Names, literal) {
+			t.CheckGlobalValue(env, field.Type, composite.Values[i], literal.Values[i])
+		}
+	default:
+		panic(parsing.ErrorFromNode(composite.Literal, fmt.Sprintf(`"%s" is not a struct`, record.Rep())))
+	}
+}
+
+// IntegerCanHold tells whether value is in the range of the integer type record
+func (t *Typer) IntegerCanHold(record TypeRecord, value int64) bool {
+	bits := uint(record.Size() * 8)
+	if bits >= 64 {
+		return true
+	}
+	if t.IsUnsigned(record) {
+		return value >= 0 && value < int64(1)<<bits
+	}
+	return value >= -(int64(1)<<(bits-1)) && value < int64(1)<<(bits-1)
+}
+
+func (t *Typer) TypeImmediate(val interface{}) TypeRecord {
 	switch val := val.(type) {
 	case int64, uint64, int:
 		return t.Builtins[IntIdx]
+	case float64:
+		return t.Builtins[F64Idx]
+	case uint8:
+		return t.Builtins[U8Idx]
 	case string:
 		return t.Builtins[StringIdx]
 	case bool:
@@ -361,6 +898,8 @@ var builtinTypes map[string]int = map[string]int{
 	"s32":    S32Idx,
 	"u64":    U64Idx,
 	"s64":    S64Idx,
+	"f32":    F32Idx,
+	"f64":    F64Idx,
 }
 
 func (t *Typer) mapToBuiltinType(name string) TypeRecord {
@@ -398,7 +937,7 @@ func RepForListOfTypes(types []TypeRecord, widths []int) string {
 }
 
 func (t *Typer) Assignable(target, value TypeRecord) bool {
-	if reflect.DeepEqual(target, value) || (target.IsNumber() && value.IsNumber()) {
+	if reflect.DeepEqual(target, value) || t.sameKindOfNumber(target, value) {
 		return true
 	}
 	targetAsPointer, targetIsPointer := target.(Pointer)
@@ -413,6 +952,38 @@ func (t *Typer) Assignable(target, value TypeRecord) bool {
 	return false
 }
 
+// Out of the overloads that can take the arguments, the one with the most arguments that are exactly the
+// type of the parameter wins. Returns -1 and the overloads that tie for the win when there isn't one winner.
+func (t *Typer) pickOverload(overloads []ProcRecord, passed []TypeRecord) (int, []int) {
+	bestScore := -1
+	var best []int
+	for i, overload := range overloads {
+		if len(overload.Args) != len(passed) {
+			continue
+		}
+		score := 0
+		for j, arg := range overload.Args {
+			if !t.Assignable(passed[j], arg) {
+				score = -1
+				break
+			}
+			if reflect.DeepEqual(passed[j], arg) {
+				score++
+			}
+		}
+		if score > bestScore {
+			bestScore = score
+			best = []int{i}
+		} else if score == bestScore && score != -1 {
+			best = append(best, i)
+		}
+	}
+	if len(best) != 1 {
+		return -1, best
+	}
+	return best[0], nil
+}
+
 func isVoidPointer(pointer Pointer) bool {
 	_, ok := pointer.ToWhat.(Void)
 	return ok
@@ -437,30 +1008,77 @@ func BuildRecordWithIndirection(base TypeRecord, level int) TypeRecord {
 }
 
 func BuildRecordAccordingToUnresolved(base TypeRecord, unresolved Unresolved) TypeRecord {
+	return buildRecordFromDecl(base, unresolved.Decl)
+}
+
+func buildRecordFromDecl(base TypeRecord, decl parsing.TypeDecl) TypeRecord {
 	var record TypeRecord
-	if unresolved.Decl.Base.Name == "" {
-		record = BuildRecordWithIndirection(base, unresolved.Decl.ArrayBase.LevelOfIndirection)
-		record = BuildArray(record, unresolved.Decl.ArraySizes)
-	} else {
+	switch {
+	case decl.ArrayBase != nil:
+		record = BuildArray(buildRecordFromDecl(base, *decl.ArrayBase), decl.ArraySizes)
+	case decl.SliceOf != nil:
+		record = Slice{OfWhat: buildRecordFromDecl(base, *decl.SliceOf)}
+	default:
 		record = base
 	}
-	return BuildRecordWithIndirection(record, unresolved.Decl.LevelOfIndirection)
+	return BuildRecordWithIndirection(record, decl.LevelOfIndirection)
+}
+
+// ForEachUnresolved finds the user types that are still unresolved inside a proc type.
+// The slots are shared with copies of the proc type, so filling them in resolves the copies too.
+// A record that is unresolved as a whole is its own slot.
+func ForEachUnresolved(record *TypeRecord, cb func(slot *TypeRecord)) {
+	switch r := (*record).(type) {
+	case Unresolved:
+		cb(record)
+	case ProcPointer:
+		for i := range r.Args {
+			ForEachUnresolved(&r.Args[i], cb)
+		}
+		ForEachUnresolved(r.Return, cb)
+	case Pointer:
+		ForEachUnresolved(&r.ToWhat, cb)
+	case Array:
+		ForEachUnresolved(&r.OfWhat, cb)
+	case Slice:
+		ForEachUnresolved(&r.OfWhat, cb)
+	}
 }
 
 func GrabUnresolvedName(unresolved Unresolved) string {
-	name := unresolved.Decl.Base.Name
-	if name == "" {
-		name = unresolved.Decl.ArrayBase.Base.Name
-		if name == "" {
+	return innermostDecl(unresolved.Decl).Base.Name
+}
+
+func innermostDecl(decl parsing.TypeDecl) parsing.TypeDecl {
+	for decl.Base.Name == "" {
+		switch {
+		case decl.ArrayBase != nil:
+			decl = *decl.ArrayBase
+		case decl.SliceOf != nil:
+			decl = *decl.SliceOf
+		default:
 			panic("ice: nested array decl not parsed into proper format")
 		}
 	}
-	return name
+	return decl
 }
 
 func (t *Typer) TypeRecordFromDecl(decl parsing.TypeDecl) TypeRecord {
 	var base TypeRecord
-	if decl.ArrayBase != nil {
+	if decl.Proc != nil {
+		args := make([]TypeRecord, len(decl.Proc.Args))
+		for i, arg := range decl.Proc.Args {
+			args[i] = t.TypeRecordFromDecl(arg)
+		}
+		returnType := t.TypeRecordFromDecl(decl.Proc.Return)
+		base = ProcPointer{Args: args, Return: &returnType}
+	} else if decl.SliceOf != nil {
+		of := t.TypeRecordFromDecl(*decl.SliceOf)
+		if _, ofIsUnresolved := of.(Unresolved); ofIsUnresolved {
+			return Unresolved{Decl: decl}
+		}
+		base = Slice{OfWhat: of}
+	} else if decl.ArrayBase != nil {
 		base = t.TypeRecordFromDecl(*decl.ArrayBase)
 		_, baseIsUnresolved := base.(Unresolved)
 		if baseIsUnresolved {
@@ -490,6 +1108,8 @@ const (
 	S32Idx
 	U64Idx
 	S64Idx
+	F32Idx
+	F64Idx
 )
 
 func NewTyper() *Typer {
@@ -508,6 +1128,8 @@ func NewTyper() *Typer {
 		S32{},
 		U64{},
 		S64{},
+		F32{},
+		F64{},
 	}
 	typer.Builtins[1] = BuildRecordWithIndirection(typer.Builtins[0], 1)
 	return &typer
@@ -519,16 +1141,19 @@ func NewEnvRecord(typer *Typer) *EnvRecord {
 	binTableReturn := BuildRecordWithIndirection(typer.Builtins[IntIdx], 1)
 	u8Ptr := BuildRecordWithIndirection(typer.Builtins[U8Idx], 1)
 	env := EnvRecord{
-		Types: make(map[string]TypeRecord),
-		Procs: map[string]ProcRecord{
-			"exit":      {Return: voidType, Args: []TypeRecord{typer.Builtins[IntIdx]}},
-			"puts":      {Return: voidType, Args: []TypeRecord{typer.Builtins[StringIdx]}},
-			"writes":    {Return: voidType, Args: []TypeRecord{u8Ptr, typer.Builtins[IntIdx]}},
-			"print_int": {Return: voidType, Args: []TypeRecord{typer.Builtins[IntIdx]}},
-			"testbit":   {Return: boolType, Args: []TypeRecord{typer.Builtins[U64Idx], typer.Builtins[IntIdx]}},
-			"binToDecTable": {
+		Types:          make(map[string]TypeRecord),
+		Globals:        make(map[string]TypeRecord),
+		GenericStructs: make(map[string]*GenericStruct),
+		Procs: map[string][]ProcRecord{
+			"exit":        {{Return: voidType, Args: []TypeRecord{typer.Builtins[IntIdx]}}},
+			"puts":        {{Return: voidType, Args: []TypeRecord{typer.Builtins[StringIdx]}}},
+			"writes":      {{Return: voidType, Args: []TypeRecord{u8Ptr, typer.Builtins[IntIdx]}}},
+			"print_int":   {{Return: voidType, Args: []TypeRecord{typer.Builtins[IntIdx]}}},
+			"print_float": {{Return: voidType, Args: []TypeRecord{typer.Builtins[F64Idx]}}},
+			"testbit":     {{Return: boolType, Args: []TypeRecord{typer.Builtins[U64Idx], typer.Builtins[IntIdx]}}},
+			"binToDecTable": {{
 				Return: &binTableReturn,
-			},
+			}},
 		},
 	}
 	for name := range builtinTypes {